@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/app"
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+)
+
+const (
+	// maxBatchItems limits the number of orders in one batch
+	maxBatchItems = 50
+
+	// defaultBatchConcurrency is the number of batch items rendered in parallel
+	defaultBatchConcurrency = 4
+)
+
+// Batch states
+const (
+	BatchCompleted = "completed"
+	BatchPartial   = "partial"
+	BatchFailed    = "failed"
+)
+
+// BatchOrderRequest orders several claims at once
+type BatchOrderRequest struct {
+	Items []BatchOrderItem `json:"items"`
+
+	// AllOrNothing creates no orders unless every item is valid and within
+	// quota, and discards all output if any item fails to render
+	AllOrNothing bool `json:"allOrNothing,omitempty"`
+}
+
+// BatchOrderItem is a single order of a batch
+type BatchOrderItem struct {
+	Template   string                 `json:"template"`
+	Version    string                 `json:"version,omitempty"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// BatchOrderItemResult is the outcome of a single batch item
+type BatchOrderItemResult struct {
+	Index    int    `json:"index"`
+	Template string `json:"template"`
+	Version  string `json:"version,omitempty"`
+	UID      string `json:"uid,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BatchOrderResponse holds per-item results and the rendered output of all
+// successful items as a multi-document YAML stream
+type BatchOrderResponse struct {
+	APIVersion string                 `json:"apiVersion"`
+	Kind       string                 `json:"kind"`
+	Status     string                 `json:"status"`
+	Items      []BatchOrderItemResult `json:"items"`
+	Rendered   string                 `json:"rendered"`
+}
+
+// batchEntry tracks one item while the batch is processed
+type batchEntry struct {
+	tmpl     *claimtemplate.ClaimTemplate
+	params   map[string]interface{}
+	order    *order.Order
+	rendered string
+	result   BatchOrderItemResult
+}
+
+func (e *batchEntry) fail(err error) {
+	e.result.Status = order.StatusFailed
+	e.result.Error = err.Error()
+}
+
+func (e *batchEntry) failed() bool {
+	return e.result.Status == order.StatusFailed
+}
+
+// WithBatchConcurrency sets how many batch items are rendered in parallel
+func WithBatchConcurrency(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.batchConcurrency = n
+		}
+	}
+}
+
+// orderBatch places several orders in one request.
+// Requests with an Idempotency-Key header are processed at most once.
+func (s *Server) orderBatch(w http.ResponseWriter, r *http.Request) {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		s.withIdempotency(w, r, key, s.placeBatchOrder)
+		return
+	}
+	s.placeBatchOrder(w, r)
+}
+
+// placeBatchOrder validates all items first, then creates the orders and
+// renders them concurrently
+func (s *Server) placeBatchOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req BatchOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Items) == 0 {
+		writeError(w, http.StatusBadRequest, "batch has no items")
+		return
+	}
+	if len(req.Items) > maxBatchItems {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("batch has %d items, at most %d are allowed", len(req.Items), maxBatchItems))
+		return
+	}
+
+	requester := requesterFromRequest(r)
+	requestID, _ := r.Context().Value(ctxRequestIDKey).(string)
+	entries := make([]*batchEntry, len(req.Items))
+	for i, item := range req.Items {
+		entries[i] = s.prepareBatchItem(i, item, requester, requestID)
+	}
+
+	if code, ok := s.createBatchOrders(entries, req.AllOrNothing); !ok {
+		writeBatchResponse(w, code, entries, false)
+		return
+	}
+
+	s.renderBatch(r.Context(), entries)
+
+	// All or nothing: one failed item discards the output of the others
+	if req.AllOrNothing && batchHasFailure(entries) {
+		s.abortBatch(entries)
+		writeBatchResponse(w, http.StatusInternalServerError, entries, false)
+		return
+	}
+
+	if !writeBatchResponse(w, http.StatusOK, entries, true) {
+		return
+	}
+	for _, e := range entries {
+		if e.result.Status == order.StatusRendered {
+			event := orderEventFor(e.order)
+			event.Status = "delivered"
+			s.publishOrderEvent(EventOrderDelivered, event)
+		}
+	}
+}
+
+// prepareBatchItem resolves the template and parameters of an item and
+// builds its order without storing it
+func (s *Server) prepareBatchItem(index int, item BatchOrderItem, requester, requestID string) *batchEntry {
+	e := &batchEntry{result: BatchOrderItemResult{Index: index, Template: item.Template, Version: item.Version}}
+
+	tmpl, ok := s.findTemplate(item.Template, item.Version)
+	if !ok {
+		e.fail(errors.New("template not found"))
+		return e
+	}
+	if msg := s.retiredMessage(tmpl); msg != "" {
+		e.fail(errors.New(msg))
+		return e
+	}
+
+	params, err := s.resolveParameters(tmpl, item.Parameters, requester)
+	if err != nil {
+		e.fail(errors.New(app.RedactString(tmpl, item.Parameters, err.Error())))
+		return e
+	}
+	o, err := s.newOrder(tmpl, params, requester, requestID)
+	if err != nil {
+		e.fail(err)
+		return e
+	}
+
+	e.tmpl, e.params, e.order = tmpl, params, o
+	e.result.Version = o.TemplateVersion
+	e.result.Status = o.Status
+	return e
+}
+
+// createBatchOrders checks quotas and stores the orders of all valid items.
+// Items count against quotas together with the items before them. With
+// allOrNothing nothing is stored if any item is invalid; the returned code
+// is then the error status of the response.
+func (s *Server) createBatchOrders(entries []*batchEntry, allOrNothing bool) (int, bool) {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+
+	code := http.StatusBadRequest
+	existing := s.orders.List("")
+	for _, e := range entries {
+		if e.failed() {
+			continue
+		}
+		if err := s.checkQuota(existing, e.order, e.params); err != nil {
+			var exceeded *quota.ExceededError
+			if errors.As(err, &exceeded) {
+				code = http.StatusTooManyRequests
+			}
+			e.fail(err)
+			continue
+		}
+		existing = append(existing, e.order)
+	}
+
+	if allOrNothing && batchHasFailure(entries) {
+		for _, e := range entries {
+			if !e.failed() {
+				e.result.Status = "skipped"
+			}
+		}
+		return code, false
+	}
+
+	for _, e := range entries {
+		if e.failed() {
+			continue
+		}
+		if err := s.createOrder(e.order); err != nil {
+			e.fail(err)
+			continue
+		}
+		e.result.UID, e.result.Name = e.order.UID, e.order.Name
+	}
+	return http.StatusOK, true
+}
+
+// renderBatch renders approved orders with a bounded pool of workers.
+// Orders that require approval stay pending.
+func (s *Server) renderBatch(ctx context.Context, entries []*batchEntry) {
+	sem := make(chan struct{}, s.batchConcurrency)
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		if e.failed() {
+			continue
+		}
+		if e.order.Status == order.StatusPending {
+			s.publishOrderEvent(EventOrderPending, orderEventFor(e.order))
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(e *batchEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			o, rendered, err := s.renderOrder(ctx, e.tmpl, e.order, e.params)
+			e.order = o
+			if err != nil {
+				e.fail(err)
+				return
+			}
+			e.rendered = rendered
+			e.result.Status = order.StatusRendered
+		}(e)
+	}
+	wg.Wait()
+}
+
+// abortBatch marks the stored orders of an all-or-nothing batch as failed
+func (s *Server) abortBatch(entries []*batchEntry) {
+	for _, e := range entries {
+		if e.order == nil || e.result.UID == "" || e.failed() {
+			continue
+		}
+		msg := "batch aborted: another item failed"
+		if updated := s.updateOrder(e.order.UID, func(o *order.Order) {
+			o.Rendered = ""
+			o.Secrets = nil
+			o.Error = msg
+			o.Record(order.StatusFailed, order.ActionFailed, "", msg)
+		}); updated != nil {
+			e.order = updated
+		}
+		e.rendered = ""
+		e.fail(errors.New(msg))
+
+		event := orderEventFor(e.order)
+		event.Status, event.Error = order.StatusFailed, msg
+		s.publishOrderEvent(EventOrderFailed, event)
+	}
+}
+
+func batchHasFailure(entries []*batchEntry) bool {
+	for _, e := range entries {
+		if e.failed() {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBatchResponse writes per-item results and, if withOutput is set,
+// the combined rendered output. It reports whether the response was sent.
+func writeBatchResponse(w http.ResponseWriter, code int, entries []*batchEntry, withOutput bool) bool {
+	resp := BatchOrderResponse{
+		APIVersion: "api.claim-machinery.io/v1alpha1",
+		Kind:       "BatchOrderResponse",
+		Items:      make([]BatchOrderItemResult, len(entries)),
+	}
+
+	var docs []string
+	failed := 0
+	for i, e := range entries {
+		resp.Items[i] = e.result
+		if e.failed() {
+			failed++
+		}
+		if withOutput {
+			docs = append(docs, e.rendered)
+		}
+	}
+	resp.Rendered = app.JoinManifests(docs...)
+
+	switch {
+	case failed == 0 && code == http.StatusOK:
+		resp.Status = BatchCompleted
+	case failed < len(entries) && code == http.StatusOK:
+		resp.Status = BatchPartial
+	default:
+		resp.Status = BatchFailed
+	}
+
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		debugf("batch: write response: %v", err)
+		return false
+	}
+	return true
+}
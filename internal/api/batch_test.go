@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+)
+
+func batchTemplates() []*claimtemplate.ClaimTemplate {
+	return []*claimtemplate.ClaimTemplate{
+		{
+			Metadata: claimtemplate.ClaimTemplateMetadata{Name: "namespace"},
+			Spec: claimtemplate.ClaimTemplateSpec{
+				OrderName:  "{{ .name }}",
+				Parameters: []claimtemplate.Parameter{{Name: "name", Type: "string"}},
+			},
+		},
+		{Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"}},
+		approvalTemplate(),
+	}
+}
+
+func decodeBatch(t *testing.T, body []byte) BatchOrderResponse {
+	t.Helper()
+	var resp BatchOrderResponse
+	require.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+func TestOrderBatch(t *testing.T) {
+	server := newOrderTestServer(t, batchTemplates()...)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders:batch", "alice", `{"items":[
+		{"template":"namespace","parameters":{"name":"team-a"}},
+		{"template":"volumeclaim","parameters":{}},
+		{"template":"vsphere-vm","parameters":{"cpu":4}}
+	]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	resp := decodeBatch(t, rec.Body.Bytes())
+	assert.Equal(t, BatchCompleted, resp.Status)
+	require.Len(t, resp.Items, 3)
+	assert.Equal(t, order.StatusRendered, resp.Items[0].Status)
+	assert.Equal(t, order.StatusRendered, resp.Items[1].Status)
+	assert.Equal(t, order.StatusPending, resp.Items[2].Status, "approval is still required")
+	for i, item := range resp.Items {
+		assert.Equal(t, i, item.Index)
+		assert.NotEmpty(t, item.UID)
+	}
+
+	// Rendered items are combined in request order
+	docs := strings.Split(resp.Rendered, "\n---\n")
+	require.Len(t, docs, 2)
+	assert.True(t, strings.HasPrefix(docs[0], "kind: namespace"))
+	assert.True(t, strings.HasPrefix(docs[1], "kind: volumeclaim"))
+	assert.Len(t, server.orders.List(""), 3)
+}
+
+func TestOrderBatch_Partial(t *testing.T) {
+	server := newOrderTestServer(t, batchTemplates()...)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders:batch", "alice", `{"items":[
+		{"template":"namespace","parameters":{}},
+		{"template":"missing","parameters":{}},
+		{"template":"volumeclaim","parameters":{}}
+	]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	resp := decodeBatch(t, rec.Body.Bytes())
+	assert.Equal(t, BatchPartial, resp.Status)
+	assert.Equal(t, order.StatusFailed, resp.Items[0].Status)
+	assert.Contains(t, resp.Items[0].Error, "orderName")
+	assert.Equal(t, "template not found", resp.Items[1].Error)
+	assert.Equal(t, order.StatusRendered, resp.Items[2].Status)
+	assert.Len(t, server.orders.List(""), 1, "invalid items are not recorded")
+}
+
+func TestOrderBatch_AllOrNothing(t *testing.T) {
+	server := newOrderTestServer(t, batchTemplates()...)
+
+	// Validation errors create no orders at all
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders:batch", "alice", `{"allOrNothing":true,"items":[
+		{"template":"namespace","parameters":{}},
+		{"template":"volumeclaim","parameters":{}}
+	]}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	resp := decodeBatch(t, rec.Body.Bytes())
+	assert.Equal(t, BatchFailed, resp.Status)
+	assert.Equal(t, "skipped", resp.Items[1].Status)
+	assert.Empty(t, server.orders.List(""))
+
+	// A render failure discards the output of the other items
+	server.render = func(tmpl *claimtemplate.ClaimTemplate, params ...map[string]interface{}) (string, error) {
+		if tmpl.Metadata.Name == "volumeclaim" {
+			return "", errors.New("kcl: boom")
+		}
+		return "kind: " + tmpl.Metadata.Name + "\n", nil
+	}
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders:batch", "alice", `{"allOrNothing":true,"items":[
+		{"template":"namespace","parameters":{"name":"team-a"}},
+		{"template":"vsphere-vm","parameters":{"rootPassword":"hunter2"}},
+		{"template":"volumeclaim","parameters":{}}
+	]}`)
+	require.Equal(t, http.StatusInternalServerError, rec.Code, rec.Body.String())
+	resp = decodeBatch(t, rec.Body.Bytes())
+	assert.Equal(t, BatchFailed, resp.Status)
+	assert.Empty(t, resp.Rendered)
+	assert.Contains(t, resp.Items[0].Error, "batch aborted")
+	assert.Contains(t, resp.Items[1].Error, "batch aborted")
+
+	// Aborted orders keep neither output nor secrets
+	orders := server.orders.List("")
+	require.Len(t, orders, 3)
+	for _, o := range orders {
+		assert.Equal(t, order.StatusFailed, o.Status)
+		assert.Empty(t, o.Rendered)
+		assert.Empty(t, o.Secrets)
+	}
+}
+
+func TestOrderBatch_Quota(t *testing.T) {
+	server := newOrderTestServer(t, batchTemplates()...)
+	WithQuotas(&quota.Config{Quotas: []quota.Quota{
+		{Name: "claims", Template: "volumeclaim", MaxOrders: 1, Period: time.Hour},
+	}})(server)
+
+	// Items of the same batch count against the quota
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders:batch", "alice", `{"allOrNothing":true,"items":[
+		{"template":"volumeclaim","parameters":{}},
+		{"template":"volumeclaim","parameters":{}}
+	]}`)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code, rec.Body.String())
+	assert.Empty(t, server.orders.List(""))
+}
+
+func TestOrderBatch_Concurrency(t *testing.T) {
+	server := newOrderTestServer(t, batchTemplates()...)
+	WithBatchConcurrency(2)(server)
+
+	var running, peak int32
+	server.render = func(tmpl *claimtemplate.ClaimTemplate, params ...map[string]interface{}) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return "kind: " + tmpl.Metadata.Name + "\n", nil
+	}
+
+	items := make([]string, 6)
+	for i := range items {
+		items[i] = `{"template":"volumeclaim","parameters":{}}`
+	}
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders:batch", "alice", `{"items":[`+strings.Join(items, ",")+`]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, BatchCompleted, decodeBatch(t, rec.Body.Bytes()).Status)
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+}
+
+func TestOrderBatch_Limits(t *testing.T) {
+	server := newOrderTestServer(t, batchTemplates()...)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders:batch", "alice", `{"items":[]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	items := make([]string, maxBatchItems+1)
+	for i := range items {
+		items[i] = `{"template":"volumeclaim"}`
+	}
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders:batch", "alice", `{"items":[`+strings.Join(items, ",")+`]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
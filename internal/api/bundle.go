@@ -0,0 +1,87 @@
+package api
+
+import (
+	"github.com/stuttgart-things/claim-machinery-api/internal/app"
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+// findTemplate looks up a template by name and optional version
+func (s *Server) findTemplate(name, version string) (*claimtemplate.ClaimTemplate, bool) {
+	if version != "" {
+		return s.templates.GetVersion(name, version)
+	}
+	return s.templates.Get(name)
+}
+
+// requiresApproval reports whether orders of a template need sign-off.
+// A bundle needs approval if any of its members does.
+func (s *Server) requiresApproval(tmpl *claimtemplate.ClaimTemplate) bool {
+	if tmpl.Spec.RequiresApproval {
+		return true
+	}
+	for _, m := range tmpl.Spec.Members {
+		if member, ok := s.findTemplate(m.Template, m.Version); ok && member.Spec.RequiresApproval {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveParameters builds the parameter values of an order. For bundles
+// the member parameters are resolved as well, so broken mappings and
+// missing member templates are reported when the order is placed.
+func (s *Server) resolveParameters(tmpl *claimtemplate.ClaimTemplate, provided map[string]interface{}, requester string) (map[string]interface{}, error) {
+	gctx := app.GenerateContext{Requester: requester}
+	params, err := app.ResolveParameters(tmpl, provided, gctx)
+	if err != nil || !tmpl.IsBundle() {
+		return params, err
+	}
+	if _, err := app.ResolveBundle(tmpl, params, s.findTemplate, gctx); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// orderMembers resolves the members recorded on a bundle order, so that
+// quotas of the member templates count them. Templates have none.
+func (s *Server) orderMembers(tmpl *claimtemplate.ClaimTemplate, params map[string]interface{}, requester string) ([]order.Member, error) {
+	if !tmpl.IsBundle() {
+		return nil, nil
+	}
+	parts, err := app.ResolveBundle(tmpl, params, s.findTemplate, app.GenerateContext{Requester: requester})
+	if err != nil {
+		return nil, err
+	}
+	members := make([]order.Member, len(parts))
+	for i, p := range parts {
+		members[i] = order.Member{
+			Name:            p.Member,
+			Template:        p.Template.Metadata.Name,
+			TemplateVersion: p.Template.VersionKey(),
+			Parameters:      app.RedactParameters(p.Template, p.Parameters),
+		}
+	}
+	return members, nil
+}
+
+// renderTemplate renders a template or, for bundles, all members in order.
+// The returned function masks secret values in the output and in errors.
+func (s *Server) renderTemplate(tmpl *claimtemplate.ClaimTemplate, params map[string]interface{}, requester string) (string, func(string) string, error) {
+	redact := func(text string) string {
+		return app.RedactString(tmpl, params, text)
+	}
+	if !tmpl.IsBundle() {
+		rendered, err := s.render(tmpl, params)
+		return rendered, redact, err
+	}
+
+	parts, err := app.ResolveBundle(tmpl, params, s.findTemplate, app.GenerateContext{Requester: requester})
+	if err != nil {
+		return "", redact, err
+	}
+	rendered, err := app.RenderBundle(parts, s.render)
+	return rendered, func(text string) string {
+		return app.RedactBundle(parts, redact(text))
+	}, err
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+)
+
+func bundleTemplate(members ...claimtemplate.BundleMember) *claimtemplate.ClaimTemplate {
+	return &claimtemplate.ClaimTemplate{
+		Kind:     claimtemplate.KindClaimBundle,
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "team-env"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{{Name: "team", Type: "string"}},
+			Members:    members,
+		},
+	}
+}
+
+func TestOrderBundle(t *testing.T) {
+	server := newOrderTestServer(t,
+		&claimtemplate.ClaimTemplate{
+			Metadata: claimtemplate.ClaimTemplateMetadata{Name: "namespace"},
+			Spec:     claimtemplate.ClaimTemplateSpec{Parameters: []claimtemplate.Parameter{{Name: "name", Type: "string"}}},
+		},
+		&claimtemplate.ClaimTemplate{
+			Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+			Spec: claimtemplate.ClaimTemplateSpec{Parameters: []claimtemplate.Parameter{
+				{Name: "namespace", Type: "string"},
+				{Name: "size", Type: "string", Default: "10Gi"},
+			}},
+		},
+		bundleTemplate(
+			claimtemplate.BundleMember{Name: "ns", Template: "namespace", Parameters: map[string]interface{}{"name": "{{ .team }}-dev"}},
+			claimtemplate.BundleMember{Name: "data", Template: "volumeclaim", Parameters: map[string]interface{}{"namespace": "{{ .members.ns.name }}"}},
+		),
+	)
+
+	// Bundles are listed with regular templates
+	rec := doRequest(server, http.MethodGet, "/api/v1/claim-templates?kind=ClaimBundle", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list claimtemplate.ClaimTemplateList
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, "team-env", list.Items[0].Metadata.Name)
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/team-env/order", "alice", `{"parameters":{"team":"payments"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	docs := strings.Split(resp.Rendered, "\n---\n")
+	require.Len(t, docs, 2)
+	assert.Equal(t, `kind: namespace`+"\n"+`params: {"name":"payments-dev"}`, docs[0])
+	assert.Contains(t, docs[1], `"namespace":"payments-dev"`)
+	assert.Contains(t, docs[1], `"size":"10Gi"`)
+
+	orders := server.orders.List("")
+	require.Len(t, orders, 1)
+	assert.Equal(t, "team-env", orders[0].Template)
+	assert.Equal(t, order.StatusRendered, orders[0].Status)
+}
+
+func TestOrderBundle_Invalid(t *testing.T) {
+	server := newOrderTestServer(t, bundleTemplate(
+		claimtemplate.BundleMember{Name: "ns", Template: "namespace"},
+	))
+
+	// Missing member templates are reported when the order is placed
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/team-env/order", "alice", `{"parameters":{}}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "template namespace not found")
+	assert.Empty(t, server.orders.List(""))
+}
+
+func TestOrderBundle_MemberApproval(t *testing.T) {
+	server := newOrderTestServer(t, approvalTemplate(), bundleTemplate(
+		claimtemplate.BundleMember{Name: "vm", Template: "vsphere-vm"},
+	))
+
+	// A member that requires approval holds the whole bundle
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/team-env/order", "alice", `{"parameters":{}}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Len(t, server.orders.List(order.StatusPending), 1)
+}
+
+func TestOrderBundle_MemberQuota(t *testing.T) {
+	server := newOrderTestServer(t,
+		&claimtemplate.ClaimTemplate{
+			Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+			Spec: claimtemplate.ClaimTemplateSpec{Parameters: []claimtemplate.Parameter{
+				{Name: "size", Type: "string", Default: "10Gi"},
+			}},
+		},
+		bundleTemplate(
+			claimtemplate.BundleMember{Name: "data", Template: "volumeclaim", Parameters: map[string]interface{}{"size": "30Gi"}},
+			claimtemplate.BundleMember{Name: "logs", Template: "volumeclaim", Parameters: map[string]interface{}{"size": "20Gi"}},
+		),
+	)
+	cfg := &quota.Config{Quotas: []quota.Quota{
+		{Name: "storage", Template: "volumeclaim", Period: 24 * time.Hour, Parameter: "size", MaxTotal: "60Gi"},
+	}}
+	require.NoError(t, cfg.Validate())
+	WithQuotas(cfg)(server)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/team-env/order", "alice", `{"parameters":{"team":"payments"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	o := server.orders.List("")[0]
+	require.Len(t, o.Members, 2)
+	assert.Equal(t, order.Member{Name: "data", Template: "volumeclaim", TemplateVersion: "0.0.0",
+		Parameters: map[string]interface{}{"size": "30Gi"}}, o.Members[0])
+
+	// The bundle's members count towards the quota of their template
+	rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{"size":"20Gi"}}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "70Gi size of 60Gi")
+
+	// So do the members of new bundle orders
+	rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{"size":"10Gi"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/team-env/order", "alice", `{"parameters":{"team":"billing"}}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "110Gi size of 60Gi")
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultCacheControl forces clients to revalidate with If-None-Match
+const defaultCacheControl = "no-cache"
+
+// writeCachedJSON writes v as JSON with ETag, Last-Modified and Cache-Control
+// headers and answers conditional requests with 304 Not Modified.
+// If etag is empty it is derived from the encoded body.
+func (s *Server) writeCachedJSON(w http.ResponseWriter, r *http.Request, v interface{}, etag string, modified time.Time) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	body = append(body, '\n')
+
+	if etag == "" {
+		sum := sha256.Sum256(body)
+		etag = hex.EncodeToString(sum[:])
+	}
+	etag = `"` + etag + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", s.cacheControl)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// notModified evaluates If-None-Match (preferred) and If-Modified-Since
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		if since, err := http.ParseTime(ims); err == nil {
+			return !modified.Truncate(time.Second).After(since)
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func TestConditionalGet(t *testing.T) {
+	server := newCatalogTestServer(t)
+
+	for _, path := range []string{"/api/v1/claim-templates", "/api/v1/claim-templates/volumeclaim"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			etag := w.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+			assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+			assert.Equal(t, defaultCacheControl, w.Header().Get("Cache-Control"))
+
+			// Matching ETag
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNotModified, w.Code)
+			assert.Empty(t, w.Body.String())
+
+			// Stale ETag
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-None-Match", `"stale"`)
+			w = httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
+
+func TestConditionalGet_ChangesOnReload(t *testing.T) {
+	server := newCatalogTestServer(t)
+
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/claim-templates/volumeclaim", nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Header().Get("ETag")
+	}
+
+	before := get()
+
+	changes := server.ReloadTemplates([]*claimtemplate.ClaimTemplate{
+		{Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim", Title: "Volume Claim v2"}},
+	})
+	require.Len(t, changes, 3, "one update and two removals")
+
+	assert.NotEqual(t, before, get())
+}
@@ -4,13 +4,17 @@ import (
 	"log"
 	"os"
 	"strings"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/app"
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
 )
 
 // debugEnabled caches the debug mode check at startup
-var debugEnabled = isDebugEnabled()
+var debugEnabled = isEnvEnabled("DEBUG")
 
-func isDebugEnabled() bool {
-	val := strings.ToLower(os.Getenv("DEBUG"))
+// isEnvEnabled reports whether a boolean environment variable is set (1/true/yes)
+func isEnvEnabled(name string) bool {
+	val := strings.ToLower(os.Getenv(name))
 	return val == "1" || val == "true" || val == "yes"
 }
 
@@ -21,13 +25,14 @@ func debugf(format string, args ...interface{}) {
 	}
 }
 
-// debugParams logs parameter information for order requests
-func debugParams(action string, params map[string]interface{}) {
+// debugParams logs parameter information for order requests.
+// Secret parameter values of the template are masked.
+func debugParams(action string, tmpl *claimtemplate.ClaimTemplate, params map[string]interface{}) {
 	if !debugEnabled {
 		return
 	}
 	debugf("%s: %d parameters", action, len(params))
-	for k, v := range params {
+	for k, v := range app.RedactParameters(tmpl, params) {
 		debugf("  %s = %v", k, v)
 	}
 }
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+// DecommissionRequest is the optional body of order deletions
+type DecommissionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// OrderDecommission lists the objects to delete for a decommissioned order
+type OrderDecommission struct {
+	APIVersion      string `json:"apiVersion"`
+	Kind            string `json:"kind"`
+	Order           string `json:"order"`
+	Name            string `json:"name"`
+	Template        string `json:"template"`
+	TemplateVersion string `json:"templateVersion"`
+	Status          string `json:"status"`
+
+	// Objects are listed in deletion order
+	Objects []manifest.ObjectRef `json:"objects"`
+
+	// Manifest holds the objects as minimal YAML documents for kubectl delete -f
+	Manifest string `json:"manifest"`
+}
+
+// decommissionOrder marks an order as decommissioned and returns the objects
+// of its last render that are to be deleted. Deleting them is left to the
+// caller or to subscribers of the order.decommissioned event.
+func (s *Server) decommissionOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	format, err := negotiateOutput(r)
+	if err != nil {
+		writeError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	var req DecommissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// Decommissioning is audited, so the actor must be known
+	actor := requesterFromRequest(r)
+	if actor == "" {
+		writeError(w, http.StatusBadRequest, "requester identity required (X-Requester header)")
+		return
+	}
+
+	code := http.StatusInternalServerError
+	fail := func(c int, msg string) error {
+		code = c
+		return errors.New(msg)
+	}
+
+	var objects []manifest.ObjectRef
+	o, err := s.orders.Update(mux.Vars(r)["id"], func(o *order.Order) error {
+		// Only the requester and approvers may retire an order
+		if o.Requester != actor {
+			if ok, reason := s.identity.canApprove(r, actor); !ok {
+				return fail(http.StatusForbidden, "only the requester of the order or an approver can decommission it: "+reason)
+			}
+		}
+		switch o.Status {
+		case order.StatusRendered, order.StatusFailed:
+		case order.StatusPending:
+			return fail(http.StatusConflict, "order is pending, reject it instead")
+		default:
+			return fail(http.StatusConflict, "order is "+o.Status+" and cannot be decommissioned")
+		}
+
+		docs, err := manifest.Split(appliedRender(o))
+		if err != nil {
+			return fail(http.StatusInternalServerError, "rendered output is not valid YAML: "+err.Error())
+		}
+		objects = manifest.DeletionOrder(docs)
+
+		// Secrets are not needed anymore once the order is gone
+		o.Secrets = nil
+		o.Record(order.StatusDecommissioned, order.ActionDecommissioned, actor, req.Comment)
+		return nil
+	})
+	if errors.Is(err, order.ErrNotFound) {
+		code = http.StatusNotFound
+	}
+	if err != nil {
+		writeError(w, code, err.Error())
+		return
+	}
+
+	deletion, err := manifest.DeletionManifest(objects)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	event := orderEventFor(o)
+	event.Objects = objects
+	s.publishOrderEvent(EventOrderDecommissioned, event)
+
+	if format.name != formatOrder {
+		// Findings of the last render do not apply to the deletion
+		ref := *o
+		ref.Policies = nil
+		if err := writeRendered(w, http.StatusOK, &ref, deletion, format); err != nil {
+			debugf("order %s: write response: %v", o.Name, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(OrderDecommission{
+		APIVersion:      "api.claim-machinery.io/v1alpha1",
+		Kind:            "OrderDecommission",
+		Order:           o.UID,
+		Name:            o.Name,
+		Template:        o.Template,
+		TemplateVersion: o.TemplateVersion,
+		Status:          o.Status,
+		Objects:         objects,
+		Manifest:        deletion,
+	})
+}
+
+// appliedRender returns the output that was last delivered for an order.
+// After a failed update that is the output of an earlier revision.
+func appliedRender(o *order.Order) string {
+	if o.Rendered != "" {
+		return o.Rendered
+	}
+	for i := len(o.Revisions) - 1; i >= 0; i-- {
+		if o.Revisions[i].Rendered != "" {
+			return o.Revisions[i].Rendered
+		}
+	}
+	return ""
+}
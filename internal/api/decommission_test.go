@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+)
+
+func TestDecommissionOrder(t *testing.T) {
+	server := newOutputTestServer(t)
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var placed OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &placed))
+	uid := placed.Metadata["uid"].(string)
+
+	events := server.events.subscribe()
+	defer server.events.unsubscribe(events)
+
+	rec = doRequest(server, http.MethodDelete, "/api/v1/orders/"+uid, "alice", `{"comment":"project ended"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp OrderDecommission
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, order.StatusDecommissioned, resp.Status)
+	assert.Equal(t, []manifest.ObjectRef{
+		{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: "team-a", Name: "data"},
+		{APIVersion: "v1", Kind: "Namespace", Name: "team-a"},
+	}, resp.Objects)
+	assert.Contains(t, resp.Manifest, "kind: PersistentVolumeClaim\nmetadata:\n  name: data\n  namespace: team-a\n---\n")
+
+	select {
+	case e := <-events:
+		assert.Equal(t, EventOrderDecommissioned, e.Type)
+		assert.Equal(t, resp.Objects, e.Data.(OrderEvent).Objects)
+	case <-time.After(time.Second):
+		t.Fatal("no order.decommissioned event")
+	}
+
+	o, err := server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusDecommissioned, o.Status)
+	assert.Empty(t, o.Secrets)
+	last := o.History[len(o.History)-1]
+	assert.Equal(t, order.ActionDecommissioned, last.Action)
+	assert.Equal(t, "alice", last.Actor)
+	assert.Equal(t, "project ended", last.Comment)
+
+	// Decommissioned orders are neither updated nor decommissioned again
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = doRequest(server, http.MethodDelete, "/api/v1/orders/"+uid, "alice", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestDecommissionOrder_YAML(t *testing.T) {
+	server := newOutputTestServer(t)
+	rec := orderWithAccept(server, "", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var placed OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &placed))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/orders/"+placed.Metadata["name"].(string), nil)
+	req.Header.Set("Accept", "application/yaml")
+	req.Header.Set("X-Requester", "alice")
+	rec = httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata:\n  name: data\n  namespace: team-a\n---\n"+
+		"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: team-a\n", rec.Body.String())
+}
+
+func TestDecommissionOrder_FailedUpdate(t *testing.T) {
+	server := newDiffTestServer(t)
+	uid := placeDiffOrder(t, server)
+
+	// The failed update leaves the objects of the first revision applied
+	server.updateOrder(uid, func(o *order.Order) {
+		o.Revise(o.TemplateVersion, o.Parameters, o.Secrets)
+		o.Record(order.StatusFailed, order.ActionFailed, "", "render failed")
+	})
+
+	rec := doRequest(server, http.MethodDelete, "/api/v1/orders/"+uid, "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp OrderDecommission
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []manifest.ObjectRef{
+		{APIVersion: "resources.stuttgart-things.com/v1alpha1", Kind: "VolumeClaim", Name: "data"},
+	}, resp.Objects)
+}
+
+func TestDecommissionOrder_Quota(t *testing.T) {
+	server := newDiffTestServer(t)
+	cfg := &quota.Config{Quotas: []quota.Quota{
+		{Name: "daily", Template: "volumeclaim", Period: 24 * time.Hour, MaxOrders: 1},
+	}}
+	require.NoError(t, cfg.Validate())
+	WithQuotas(cfg)(server)
+	uid := placeDiffOrder(t, server)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{"name":"logs"}}`)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	// Decommissioned orders free their quota
+	rec = doRequest(server, http.MethodDelete, "/api/v1/orders/"+uid, "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{"name":"logs"}}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestDecommissionOrder_Errors(t *testing.T) {
+	server := newOrderTestServer(t, approvalTemplate())
+	uid := placePendingOrder(t, server)
+
+	rec := doRequest(server, http.MethodDelete, "/api/v1/orders/unknown", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/api/v1/orders/"+uid, "alice", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "reject it instead")
+
+	rec = doRequest(server, http.MethodDelete, "/api/v1/orders/"+uid, "alice", `{`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Decommissioning needs an identity for the history
+	rec = doRequest(server, http.MethodDelete, "/api/v1/orders/"+uid, "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	o, err := server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusPending, o.Status)
+}
+
+func TestDecommissionOrder_Permissions(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+	t.Setenv("APPROVERS", "bob")
+	server := newOutputTestServer(t)
+
+	place := func() string {
+		rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{}}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp OrderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Metadata["uid"].(string)
+	}
+	decommission := func(uid, remoteAddr, actor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/orders/"+uid, nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Requester", actor)
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Others than the requester need to be approvers
+	uid := place()
+	rec := decommission(uid, "10.1.2.3:1234", "carol")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "carol is not an approver")
+
+	rec = decommission(uid, "192.0.2.1:1234", "bob")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "trusted proxy")
+
+	o, err := server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusRendered, o.Status)
+
+	rec = decommission(uid, "10.1.2.3:1234", "bob")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// The requester may always decommission their order
+	rec = decommission(place(), "192.0.2.1:1234", "alice")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/stuttgart-things/claim-machinery-api/internal/app"
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+// diffContext is the number of context lines of unified diffs
+const diffContext = 3
+
+// OrderDiffRequest proposes changes to an existing order
+type OrderDiffRequest struct {
+	// Parameters override the order's values; omitted ones are kept
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// Version renders another template version (default: the order's)
+	Version string `json:"version,omitempty"`
+
+	// Manifest is compared instead of the order's stored render, e.g. the
+	// live resource exported from the cluster
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// OrderDiffSummary counts documents by change type
+type OrderDiffSummary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+}
+
+// OrderDiffResponse compares a proposed render with the previous one
+type OrderDiffResponse struct {
+	APIVersion      string `json:"apiVersion"`
+	Kind            string `json:"kind"`
+	Order           string `json:"order"`
+	Name            string `json:"name"`
+	Template        string `json:"template"`
+	TemplateVersion string `json:"templateVersion"`
+
+	// Against is "order" (the stored render) or "manifest"
+	Against   string                  `json:"against"`
+	Changed   bool                    `json:"changed"`
+	Summary   OrderDiffSummary        `json:"summary"`
+	Documents []manifest.DocumentDiff `json:"documents"`
+	Unified   string                  `json:"unified"`
+
+	// Rendered is the proposed output with secrets redacted
+	Rendered string               `json:"rendered"`
+	Policies []order.PolicyResult `json:"policies,omitempty"`
+}
+
+// diffOrder renders an order with new parameters and compares the result
+// with its stored render or a provided manifest. Nothing is ordered.
+func (s *Server) diffOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req OrderDiffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	o, err := s.orders.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	against, baseline := "order", o.Rendered
+	if req.Manifest != "" {
+		against, baseline = "manifest", req.Manifest
+	}
+	if baseline == "" {
+		writeError(w, http.StatusConflict, "order is "+o.Status+" and has no rendered output, provide a manifest to compare against")
+		return
+	}
+	previous, err := manifest.Split(baseline)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, against+" is not valid YAML: "+err.Error())
+		return
+	}
+
+	version := req.Version
+	if version == "" {
+		version = o.TemplateVersion
+	}
+	tmpl, ok := s.templates.GetVersion(o.Template, version)
+	if !ok {
+		code := http.StatusNotFound
+		if req.Version == "" {
+			code = http.StatusConflict
+		}
+		writeError(w, code, "template "+o.Template+"@"+version+" is not available")
+		return
+	}
+
+	provided := o.ParameterValues()
+	for k, v := range req.Parameters {
+		provided[k] = v
+	}
+	params, err := s.resolveParameters(tmpl, provided, o.Requester)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, app.RedactString(tmpl, provided, err.Error()))
+		return
+	}
+
+	rendered, redact, err := s.renderTemplate(tmpl, params, o.Requester)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, redact(err.Error()))
+		return
+	}
+
+	// Post-render runs as if the order was placed again, so injected
+	// order metadata does not show up as a change
+	proposal := *o
+	proposal.TemplateVersion = tmpl.VersionKey()
+	proposal.Parameters = app.RedactParameters(tmpl, params)
+	rendered, findings, err := s.postRender(r.Context(), &proposal, rendered, redact)
+	if err != nil {
+		writeRenderError(w, err)
+		return
+	}
+	rendered = redact(rendered)
+
+	proposed, err := manifest.Split(rendered)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "rendered output is not valid YAML: "+err.Error())
+		return
+	}
+
+	resp := OrderDiffResponse{
+		APIVersion:      "api.claim-machinery.io/v1alpha1",
+		Kind:            "OrderDiff",
+		Order:           o.UID,
+		Name:            o.Name,
+		Template:        o.Template,
+		TemplateVersion: proposal.TemplateVersion,
+		Against:         against,
+		Documents:       manifest.Diff(previous, proposed),
+		Unified:         manifest.Unified(against+"/"+o.Name, "proposed/"+o.Name, baseline, rendered, diffContext),
+		Rendered:        rendered,
+		Policies:        findings,
+	}
+	for _, d := range resp.Documents {
+		switch d.Status {
+		case manifest.ChangeAdded:
+			resp.Summary.Added++
+		case manifest.ChangeRemoved:
+			resp.Summary.Removed++
+		case manifest.ChangeModified:
+			resp.Summary.Modified++
+		default:
+			resp.Summary.Unchanged++
+		}
+	}
+	resp.Changed = resp.Summary.Added+resp.Summary.Removed+resp.Summary.Modified > 0
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+)
+
+func newDiffTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{
+				{Name: "name", Type: "string", Default: "data"},
+				{Name: "storage", Type: "string", Default: "10Gi"},
+			},
+		},
+	})
+	server.render = func(tmpl *claimtemplate.ClaimTemplate, params ...map[string]interface{}) (string, error) {
+		return "apiVersion: resources.stuttgart-things.com/v1alpha1\nkind: VolumeClaim\nmetadata:\n  name: " +
+			params[0]["name"].(string) + "\nspec:\n  storage: " + params[0]["storage"].(string) + "\n", nil
+	}
+	return server
+}
+
+func placeDiffOrder(t *testing.T, server *Server) string {
+	t.Helper()
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Metadata["uid"].(string)
+}
+
+func TestDiffOrder(t *testing.T) {
+	server := newDiffTestServer(t)
+	uid := placeDiffOrder(t, server)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/diff", "alice", `{"parameters":{"storage":"20Gi"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp OrderDiffResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "order", resp.Against)
+	assert.True(t, resp.Changed)
+	assert.Equal(t, OrderDiffSummary{Modified: 1}, resp.Summary)
+	require.Len(t, resp.Documents, 1)
+	assert.Equal(t, []manifest.FieldChange{
+		{Path: "spec.storage", Type: manifest.ChangeModified, Old: "10Gi", New: "20Gi"},
+	}, resp.Documents[0].Changes)
+	assert.Contains(t, resp.Unified, "-  storage: 10Gi\n+  storage: 20Gi\n")
+	assert.Contains(t, resp.Rendered, "storage: 20Gi")
+
+	// Nothing is ordered and the stored render is unchanged
+	orders := server.orders.List("")
+	require.Len(t, orders, 1)
+	assert.Contains(t, orders[0].Rendered, "storage: 10Gi")
+
+	// Unchanged parameters yield no changes
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/diff", "alice", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	resp = OrderDiffResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.False(t, resp.Changed)
+	assert.Empty(t, resp.Unified)
+}
+
+func TestDiffOrder_AgainstManifest(t *testing.T) {
+	server := newDiffTestServer(t)
+	uid := placeDiffOrder(t, server)
+
+	live, err := json.Marshal(map[string]interface{}{
+		"manifest": "apiVersion: resources.stuttgart-things.com/v1alpha1\nkind: VolumeClaim\nmetadata:\n  name: data\nspec:\n  storage: 10Gi\n---\n" +
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: leftover\n",
+		"parameters": map[string]interface{}{"name": "logs"},
+	})
+	require.NoError(t, err)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/diff", "alice", string(live))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp OrderDiffResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "manifest", resp.Against)
+	assert.Equal(t, OrderDiffSummary{Added: 1, Removed: 2}, resp.Summary)
+}
+
+func TestDiffOrder_Errors(t *testing.T) {
+	server := newDiffTestServer(t)
+	uid := placeDiffOrder(t, server)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders/unknown/diff", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/diff", "alice", `{"version":"9.9.9"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/diff", "alice", `{"manifest":"a: [1"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/diff", "alice", `{`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Pending orders have no render to compare against
+	server.templates = claimtemplate.NewRegistry([]*claimtemplate.ClaimTemplate{approvalTemplate()})
+	pending := placePendingOrder(t, server)
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+pending+"/diff", "alice", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
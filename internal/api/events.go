@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+)
+
+// Event types published on the watch stream
+const (
+	EventTemplateAdded       = "template.added"
+	EventTemplateUpdated     = "template.updated"
+	EventTemplateRemoved     = "template.removed"
+	EventOrderRendered       = "order.rendered"
+	EventOrderFailed         = "order.failed"
+	EventOrderDelivered      = "order.delivered"
+	EventOrderPending        = "order.pending"
+	EventOrderApproved       = "order.approved"
+	EventOrderRejected       = "order.rejected"
+	EventOrderUpdated        = "order.updated"
+	EventOrderDecommissioned = "order.decommissioned"
+)
+
+// watchHeartbeat keeps idle SSE connections open through proxies
+const watchHeartbeat = 15 * time.Second
+
+// Event is a catalog or order change delivered to watchers
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// OrderEvent is the payload of order.* events
+type OrderEvent struct {
+	UID             string                 `json:"uid"`
+	Name            string                 `json:"name"`
+	Template        string                 `json:"template"`
+	TemplateVersion string                 `json:"templateVersion"`
+	Status          string                 `json:"status"`
+	Error           string                 `json:"error,omitempty"`
+	Revision        int                    `json:"revision,omitempty"`
+	RequestID       string                 `json:"requestId,omitempty"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+
+	// Objects are the objects to delete, set on order.decommissioned
+	Objects []manifest.ObjectRef `json:"objects,omitempty"`
+}
+
+// eventBroker fans out events to subscribers. Slow subscribers miss events
+// instead of blocking publishers.
+type eventBroker struct {
+	mu     sync.Mutex
+	nextID uint64
+	subs   map[chan Event]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subs: make(map[chan Event]struct{})}
+}
+
+func (b *eventBroker) subscribe() chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, 64)
+	b.subs[ch] = struct{}{}
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subs, ch)
+}
+
+func (b *eventBroker) publish(eventType string, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e := Event{ID: b.nextID, Type: eventType, Time: time.Now(), Data: data}
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			debugf("watch subscriber too slow, dropping event %d", e.ID)
+		}
+	}
+}
+
+// publishTemplateChanges emits one event per registry change
+func (s *Server) publishTemplateChanges(changes []claimtemplate.Change) {
+	for _, c := range changes {
+		switch c.Type {
+		case claimtemplate.ChangeAdded:
+			s.events.publish(EventTemplateAdded, c)
+		case claimtemplate.ChangeUpdated:
+			s.events.publish(EventTemplateUpdated, c)
+		case claimtemplate.ChangeRemoved:
+			s.events.publish(EventTemplateRemoved, c)
+		}
+	}
+}
+
+// publishOrderEvent notifies watchers and webhook subscribers about an order.
+// Parameters must already be redacted.
+func (s *Server) publishOrderEvent(eventType string, event OrderEvent) {
+	s.events.publish(eventType, event)
+	s.webhooks.Dispatch(eventType, event.Name, event)
+}
+
+// watchTemplates streams catalog and order events as Server-Sent Events.
+// The optional "types" query parameter filters by event type prefix,
+// e.g. ?types=template or ?types=order.failed
+func (s *Server) watchTemplates(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	// SSE connections outlive the server write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		debugf("watch: cannot clear write deadline: %v", err)
+	}
+
+	filters := splitList(r.URL.Query().Get("types"))
+
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", (5 * time.Second).Milliseconds())
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case e := <-ch:
+			if !matchesEventFilter(e.Type, filters) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				debugf("watch: marshal event %d: %v", e.ID, err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func matchesEventFilter(eventType string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if eventType == f || strings.HasPrefix(eventType, f+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/webhook"
+)
+
+func TestWatchTemplates(t *testing.T) {
+	server := newCatalogTestServer(t)
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/v1/claim-templates/watch?types=template", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+
+	// Wait for the retry preamble so the subscription is active
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, "retry:"))
+
+	// Order events are filtered out, template events are delivered
+	server.events.publish(EventOrderRendered, OrderEvent{Name: "ignored"})
+	server.ReloadTemplates([]*claimtemplate.ClaimTemplate{
+		{Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim", Title: "changed"}},
+	})
+
+	var events []string
+	for len(events) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if strings.HasPrefix(line, "event: ") {
+			events = append(events, strings.TrimSpace(strings.TrimPrefix(line, "event: ")))
+		}
+	}
+
+	assert.ElementsMatch(t, []string{EventTemplateUpdated, EventTemplateRemoved, EventTemplateRemoved}, events)
+}
+
+func TestMatchesEventFilter(t *testing.T) {
+	assert.True(t, matchesEventFilter(EventOrderFailed, nil))
+	assert.True(t, matchesEventFilter(EventOrderFailed, []string{"order"}))
+	assert.True(t, matchesEventFilter(EventOrderFailed, []string{"order.failed"}))
+	assert.False(t, matchesEventFilter(EventOrderFailed, []string{"template"}))
+	assert.False(t, matchesEventFilter(EventOrderFailed, []string{"ord"}))
+}
+
+func TestOrderClaim_Webhooks(t *testing.T) {
+	received := make(chan webhook.CloudEvent, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e webhook.CloudEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err == nil {
+			received <- e
+		}
+	}))
+	defer receiver.Close()
+
+	dispatcher := webhook.NewDispatcher(webhook.Config{Subscriptions: []webhook.Subscription{
+		{Name: "audit", URL: receiver.URL, Events: []string{"order.*"}},
+	}}, "/test")
+
+	server, err := NewServerWithTemplates([]*claimtemplate.ClaimTemplate{{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "postgresql"},
+		Spec: claimtemplate.ClaimTemplateSpec{Parameters: []claimtemplate.Parameter{
+			{Name: "dbName", Type: "string"},
+			{Name: "password", Type: "string", Secret: true},
+		}},
+	}}, WithWebhooks(dispatcher))
+	require.NoError(t, err)
+	server.render = func(*claimtemplate.ClaimTemplate, ...map[string]interface{}) (string, error) {
+		return "kind: PostgreSQL\n", nil
+	}
+
+	body := `{"parameters":{"dbName":"orders","password":"hunter2"}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/claim-templates/postgresql/order", strings.NewReader(body))
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	types := map[string]webhook.CloudEvent{}
+	for len(types) < 2 {
+		select {
+		case e := <-received:
+			types[e.Type] = e
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for webhooks, got %v", types)
+		}
+	}
+
+	rendered, ok := types["io.claim-machinery.order.rendered"]
+	require.True(t, ok)
+	assert.Contains(t, types, "io.claim-machinery.order.delivered")
+
+	data := rendered.Data.(map[string]interface{})
+	assert.Equal(t, "postgresql", data["template"])
+	assert.Equal(t, "req-42", data["requestId"])
+	params := data["parameters"].(map[string]interface{})
+	assert.Equal(t, "orders", params["dbName"])
+	assert.NotEqual(t, "hunter2", params["password"])
+}
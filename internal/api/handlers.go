@@ -3,11 +3,13 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stuttgart-things/claim-machinery-api/internal/app"
 	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
 )
 
 // OrderRequest represents a claim order request
@@ -21,45 +23,139 @@ type OrderResponse struct {
 	Kind       string                 `json:"kind"`
 	Metadata   map[string]interface{} `json:"metadata"`
 	Rendered   string                 `json:"rendered"`
+	// Policies lists policy warnings for the rendered output
+	Policies []order.PolicyResult `json:"policies,omitempty"`
+}
+
+// ClaimTemplateVersionListResponse lists all versions of a template
+type ClaimTemplateVersionListResponse struct {
+	APIVersion     string                        `json:"apiVersion"`
+	Kind           string                        `json:"kind"`
+	Name           string                        `json:"name"`
+	DefaultVersion string                        `json:"defaultVersion"`
+	LatestVersion  string                        `json:"latestVersion"`
+	Items          []claimtemplate.ClaimTemplate `json:"items"`
 }
 
 // ClaimTemplateListResponse wraps templates for list endpoint
 type ClaimTemplateListResponse struct {
 	APIVersion string                        `json:"apiVersion"`
 	Kind       string                        `json:"kind"`
+	Total      int                           `json:"total"`
+	NextCursor string                        `json:"nextCursor,omitempty"`
 	Items      []claimtemplate.ClaimTemplate `json:"items"`
 }
 
-// listTemplates returns all available claim templates
+// claimTemplateFieldListResponse is returned when fields are selected
+type claimTemplateFieldListResponse struct {
+	APIVersion string                   `json:"apiVersion"`
+	Kind       string                   `json:"kind"`
+	Total      int                      `json:"total"`
+	NextCursor string                   `json:"nextCursor,omitempty"`
+	Items      []map[string]interface{} `json:"items"`
+}
+
+// listTemplates returns the default version of all claim templates,
+// filtered, sorted and paginated according to the query string
 func (s *Server) listTemplates(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	query, err := parseTemplateQuery(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	page, total, next := query.apply(s.templates.List())
+
+	// Field selection returns partial objects
+	if len(query.fields) > 0 {
+		response := claimTemplateFieldListResponse{
+			APIVersion: "api.claim-machinery.io/v1alpha1",
+			Kind:       "ClaimTemplateList",
+			Total:      total,
+			NextCursor: next,
+			Items:      make([]map[string]interface{}, 0, len(page)),
+		}
+		for _, tmpl := range page {
+			item, err := selectFields(tmpl, query.fields)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+			response.Items = append(response.Items, item)
+		}
+
+		s.writeCachedJSON(w, r, response, "", s.templates.Updated())
+		return
+	}
+
 	// Build response
 	response := ClaimTemplateListResponse{
 		APIVersion: "api.claim-machinery.io/v1alpha1",
 		Kind:       "ClaimTemplateList",
-		Items:      make([]claimtemplate.ClaimTemplate, 0),
+		Total:      total,
+		NextCursor: next,
+		Items:      make([]claimtemplate.ClaimTemplate, 0, len(page)),
+	}
+	for _, tmpl := range page {
+		response.Items = append(response.Items, *tmpl)
+	}
+
+	s.writeCachedJSON(w, r, response, "", s.templates.Updated())
+}
+
+// listTemplateVersions returns all versions of a claim template, newest first
+func (s *Server) listTemplateVersions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := mux.Vars(r)["name"]
+	versions := s.templates.Versions(name)
+	if len(versions) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "template not found",
+		})
+		return
 	}
 
-	// Add all templates to response
-	for _, tmpl := range s.templates {
+	def, _ := s.templates.Get(name)
+	response := ClaimTemplateVersionListResponse{
+		APIVersion:     "api.claim-machinery.io/v1alpha1",
+		Kind:           "ClaimTemplateVersionList",
+		Name:           name,
+		DefaultVersion: def.VersionKey(),
+		LatestVersion:  versions[0].VersionKey(),
+		Items:          make([]claimtemplate.ClaimTemplate, 0, len(versions)),
+	}
+	for _, tmpl := range versions {
 		response.Items = append(response.Items, *tmpl)
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	s.writeCachedJSON(w, r, response, "", s.templates.Updated())
+}
+
+// lookupTemplate resolves the template addressed by the route variables.
+// Without a version the default version is returned.
+func (s *Server) lookupTemplate(vars map[string]string) (*claimtemplate.ClaimTemplate, bool) {
+	if version, ok := vars["version"]; ok {
+		return s.templates.GetVersion(vars["name"], version)
+	}
+	return s.templates.Get(vars["name"])
 }
 
 // getTemplate returns a specific claim template by name
 func (s *Server) getTemplate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Extract template name from URL
-	vars := mux.Vars(r)
-	name := vars["name"]
-
-	// Look up template
-	tmpl, exists := s.templates[name]
+	// Look up template by name (and optional version) from URL
+	tmpl, exists := s.lookupTemplate(mux.Vars(r))
 	if !exists {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -68,20 +164,32 @@ func (s *Server) getTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tmpl)
+	s.writeCachedJSON(w, r, tmpl, tmpl.Hash(), s.templates.ModifiedAt(tmpl.Metadata.Name, tmpl.VersionKey()))
 }
 
-// orderClaim renders a claim template with provided parameters
+// orderClaim renders a claim template with provided parameters.
+// Requests with an Idempotency-Key header are processed at most once.
 func (s *Server) orderClaim(w http.ResponseWriter, r *http.Request) {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		s.withIdempotency(w, r, key, s.placeClaimOrder)
+		return
+	}
+	s.placeClaimOrder(w, r)
+}
+
+// placeClaimOrder creates an order and renders it unless it needs approval
+func (s *Server) placeClaimOrder(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	// Extract template name from URL
-	vars := mux.Vars(r)
-	name := vars["name"]
+	// Refuse unsupported output formats before anything is ordered
+	format, err := negotiateOutput(r)
+	if err != nil {
+		writeError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
 
-	// Look up template
-	tmpl, exists := s.templates[name]
+	// Look up template by name (and optional version) from URL
+	tmpl, exists := s.lookupTemplate(mux.Vars(r))
 	if !exists {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -90,6 +198,18 @@ func (s *Server) orderClaim(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Signal deprecation and refuse retired templates if configured
+	if tmpl.IsDeprecated() {
+		setDeprecationHeaders(w, tmpl)
+		if msg := s.retiredMessage(tmpl); msg != "" {
+			w.WriteHeader(http.StatusGone)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": msg,
+			})
+			return
+		}
+	}
+
 	// Parse request body
 	var req OrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -101,38 +221,82 @@ func (s *Server) orderClaim(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Debug: log received parameters
-	debugParams("Received from request", req.Parameters)
+	debugParams("Received from request", tmpl, req.Parameters)
 
-	// Build parameter values (merge request params with defaults)
-	params := app.BuildParameterValues(tmpl)
-	for key, value := range req.Parameters {
-		params[key] = value
+	// Build parameter values (defaults, request params, generated values)
+	requester := requesterFromRequest(r)
+	params, err := s.resolveParameters(tmpl, req.Parameters, requester)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": app.RedactString(tmpl, req.Parameters, err.Error()),
+		})
+		return
 	}
 
 	// Debug: log merged parameters
-	debugParams("After merge", params)
+	debugParams("After merge", tmpl, params)
+
+	requestID, _ := r.Context().Value(ctxRequestIDKey).(string)
+	o, err := s.newOrder(tmpl, params, requester, requestID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.placeOrder(w, o, params) {
+		return
+	}
+
+	// Templates that require approval are held until an approver signs off
+	if o.Status == order.StatusPending {
+		s.publishOrderEvent(EventOrderPending, orderEventFor(o))
+
+		w.Header().Set("Location", "/api/v1/orders/"+o.UID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(newOrderResponse(o, ""))
+		return
+	}
 
 	// Render template with custom parameters
-	rendered, err := app.RenderTemplate(tmpl, params)
+	o, rendered, err := s.renderOrder(r.Context(), tmpl, o, params)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": err.Error(),
-		})
+		writeRenderError(w, err)
 		return
 	}
 
 	// Return success response
-	response := OrderResponse{
-		APIVersion: "api.claim-machinery.io/v1alpha1",
-		Kind:       "OrderResponse",
-		Metadata: map[string]interface{}{
-			"name":      name + "-order-" + time.Now().Format("20060102150405"),
-			"timestamp": time.Now().Format(time.RFC3339),
-		},
-		Rendered: rendered,
+	s.deliverOrder(w, http.StatusOK, o, rendered, format)
+}
+
+// retiredMessage explains why a template past its sunset date cannot be
+// ordered; it is empty if orders are allowed
+func (s *Server) retiredMessage(tmpl *claimtemplate.ClaimTemplate) string {
+	if !s.enforceSunset || !tmpl.IsSunset(time.Now()) {
+		return ""
+	}
+	msg := "template " + tmpl.Metadata.Name + " was retired on " + tmpl.Metadata.Sunset
+	if tmpl.Metadata.ReplacedBy != "" {
+		msg += ", use " + tmpl.Metadata.ReplacedBy + " instead"
 	}
+	return msg
+}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+// setDeprecationHeaders adds Deprecation, Sunset and successor Link headers
+func setDeprecationHeaders(w http.ResponseWriter, tmpl *claimtemplate.ClaimTemplate) {
+	w.Header().Set("Deprecation", "true")
+	if ts, ok, err := tmpl.SunsetTime(); err == nil && ok {
+		w.Header().Set("Sunset", ts.UTC().Format(http.TimeFormat))
+	}
+	if tmpl.Metadata.ReplacedBy != "" {
+		w.Header().Set("Link", `</api/v1/claim-templates/`+url.PathEscape(tmpl.Metadata.ReplacedBy)+`>; rel="successor-version"`)
+	}
+}
+
+// requesterFromRequest identifies who placed a request.
+// Upstream proxies (e.g. Backstage) are expected to set X-Requester.
+func requesterFromRequest(r *http.Request) string {
+	if v := r.Header.Get("X-Requester"); v != "" {
+		return v
+	}
+	return r.Header.Get("X-Forwarded-User")
 }
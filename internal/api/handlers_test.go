@@ -9,6 +9,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
 )
 
 func TestHealthCheck(t *testing.T) {
@@ -178,3 +180,76 @@ func TestOrderClaim_InvalidBody(t *testing.T) {
 	// Assertions
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
+
+func TestTemplateVersions(t *testing.T) {
+	// Create server with two versions of the same template
+	server, err := NewServerWithTemplates([]*claimtemplate.ClaimTemplate{
+		{Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim", Version: "1.0.0"}},
+		{Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim", Version: "2.0.0"}},
+	})
+	require.NoError(t, err)
+
+	// List versions
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/claim-templates/volumeclaim/versions", nil)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list ClaimTemplateVersionListResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	assert.Equal(t, "2.0.0", list.DefaultVersion)
+	assert.Equal(t, "2.0.0", list.LatestVersion)
+	assert.Len(t, list.Items, 2)
+
+	// Get specific version
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/claim-templates/volumeclaim/versions/1.0.0", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var tmpl claimtemplate.ClaimTemplate
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&tmpl))
+	assert.Equal(t, "1.0.0", tmpl.Metadata.Version)
+
+	// Unknown version
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/claim-templates/volumeclaim/versions/3.0.0", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOrderClaim_SunsetTemplate(t *testing.T) {
+	server, err := NewServerWithTemplates([]*claimtemplate.ClaimTemplate{
+		{Metadata: claimtemplate.ClaimTemplateMetadata{
+			Name:       "volumeclaim",
+			Lifecycle:  claimtemplate.LifecycleDeprecated,
+			ReplacedBy: "volumeclaim-v2",
+			Sunset:     "2020-01-01",
+		}},
+	})
+	require.NoError(t, err)
+	server.enforceSunset = true
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/api/v1/claim-templates/volumeclaim/order",
+		bytes.NewReader([]byte(`{}`)),
+	)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Wed, 01 Jan 2020 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Contains(t, w.Header().Get("Link"), "volumeclaim-v2")
+	assert.Contains(t, w.Body.String(), "use volumeclaim-v2 instead")
+}
+
+func TestSetDeprecationHeaders_EscapesSuccessor(t *testing.T) {
+	w := httptest.NewRecorder()
+	setDeprecationHeaders(w, &claimtemplate.ClaimTemplate{Metadata: claimtemplate.ClaimTemplateMetadata{
+		Lifecycle:  claimtemplate.LifecycleDeprecated,
+		ReplacedBy: "volume claim>; rel=evil",
+	}})
+	assert.Equal(t, `</api/v1/claim-templates/volume%20claim%3E%3B%20rel=evil>; rel="successor-version"`, w.Header().Get("Link"))
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultIdempotencyTTL is how long responses are kept for replay
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// defaultIdempotencyKeys bounds the keys remembered at a time
+const defaultIdempotencyKeys = 10000
+
+// idempotencyCache remembers responses of requests sent with an
+// Idempotency-Key header. Entries are kept in memory only because rendered
+// output may contain secrets.
+type idempotencyCache struct {
+	ttl time.Duration
+	// max is the number of keys kept; the oldest are evicted first
+	max int
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	done        bool
+	created     time.Time
+	expires     time.Time
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+func newIdempotencyCache(ttl time.Duration, max int) *idempotencyCache {
+	return &idempotencyCache{ttl: ttl, max: max, entries: make(map[string]*idempotencyEntry)}
+}
+
+// begin looks up a key. It returns the finished entry for replays, or nil
+// if the caller should process the request and then call finish or abort.
+// A key reused for a different request or while the first is still in
+// flight yields a conflict message.
+func (c *idempotencyCache) begin(key, fingerprint string) (*idempotencyEntry, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range c.entries {
+		if e.done && now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	e, ok := c.entries[key]
+	if !ok {
+		if c.max > 0 && len(c.entries) >= c.max {
+			c.evict()
+		}
+		c.entries[key] = &idempotencyEntry{fingerprint: fingerprint, created: now}
+		return nil, ""
+	}
+	if e.fingerprint != fingerprint {
+		return nil, "Idempotency-Key was already used for a different request"
+	}
+	if !e.done {
+		return nil, "a request with this Idempotency-Key is still in progress"
+	}
+	return e, ""
+}
+
+// evict drops the oldest stored response, or the oldest request in flight
+// if none is stored; callers hold the lock
+func (c *idempotencyCache) evict() {
+	var oldest string
+	var oldestEntry *idempotencyEntry
+	for k, e := range c.entries {
+		if oldestEntry == nil || (e.done && !oldestEntry.done) ||
+			(e.done == oldestEntry.done && e.created.Before(oldestEntry.created)) {
+			oldest, oldestEntry = k, e
+		}
+	}
+	delete(c.entries, oldest)
+}
+
+func (c *idempotencyCache) finish(key string, status int, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.done = true
+		e.expires = time.Now().Add(c.ttl)
+		e.status, e.header, e.body = status, header, body
+	}
+}
+
+// abort forgets a key so the request can be retried
+func (c *idempotencyCache) abort(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// withIdempotency runs next once per Idempotency-Key and replays the stored
+// response for retries with the same body. Server errors, conflicts and
+// rate limit responses are not stored so that retries can succeed.
+func (s *Server) withIdempotency(w http.ResponseWriter, r *http.Request, key string, next http.HandlerFunc) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Keys are scoped per requester
+	scoped := requesterFromRequest(r) + "\x00" + key
+	entry, conflict := s.idempotency.begin(scoped, requestFingerprint(r, body))
+	if conflict != "" {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusConflict, conflict)
+		return
+	}
+	if entry != nil {
+		for k, v := range entry.header {
+			// The replay keeps its own request ID for correlation
+			if k != "X-Request-Id" {
+				w.Header()[k] = v
+			}
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(entry.status)
+		w.Write(entry.body)
+		return
+	}
+
+	cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		if rec := recover(); rec != nil {
+			s.idempotency.abort(scoped)
+			panic(rec)
+		}
+		if cw.status >= 500 || cw.status == http.StatusConflict || cw.status == http.StatusTooManyRequests {
+			s.idempotency.abort(scoped)
+			return
+		}
+		s.idempotency.finish(scoped, cw.status, w.Header().Clone(), cw.body.Bytes())
+	}()
+	next(cw, r)
+}
+
+// requestFingerprint hashes method, path, the negotiated output format and
+// the JSON body in canonical form, so retries with reordered keys still
+// match but retries asking for another format do not
+func requestFingerprint(r *http.Request, body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if canonical, err := json.Marshal(v); err == nil {
+			body = canonical
+		}
+	}
+
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	if format, err := negotiateOutput(r); err == nil {
+		io.WriteString(h, "format "+format.name+" "+format.archive+"\n")
+	} else {
+		io.WriteString(h, "accept "+r.Header.Get("Accept")+" "+r.URL.RawQuery+"\n")
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// captureWriter records the status and body written by a handler
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	c.status = code
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func orderWithKey(server *Server, key, requester, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	req.Header.Set("X-Requester", requester)
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOrderClaim_IdempotencyKey(t *testing.T) {
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+	})
+
+	first := orderWithKey(server, "key-1", "alice", `{"parameters":{"size":"10Gi","name":"data"}}`)
+	require.Equal(t, http.StatusOK, first.Code)
+
+	// Same body with reordered keys replays the original response
+	retry := orderWithKey(server, "key-1", "alice", `{"parameters":{"name":"data","size":"10Gi"}}`)
+	require.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Len(t, server.orders.List(""), 1)
+
+	// A different body with the same key is rejected
+	mismatch := orderWithKey(server, "key-1", "alice", `{"parameters":{"size":"20Gi"}}`)
+	assert.Equal(t, http.StatusConflict, mismatch.Code)
+
+	// Keys are scoped per requester
+	other := orderWithKey(server, "key-1", "bob", `{"parameters":{"size":"20Gi"}}`)
+	assert.Equal(t, http.StatusOK, other.Code)
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+	assert.Len(t, server.orders.List(""), 2)
+}
+
+func TestOrderClaim_IdempotencyKeyRetriesErrors(t *testing.T) {
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+	})
+	render := server.render
+	server.render = func(*claimtemplate.ClaimTemplate, ...map[string]interface{}) (string, error) {
+		return "", assert.AnError
+	}
+
+	rec := orderWithKey(server, "key-1", "alice", `{"parameters":{}}`)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	// Server errors are not stored, the retry is processed again
+	server.render = render
+	rec = orderWithKey(server, "key-1", "alice", `{"parameters":{}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotencyCache_InFlight(t *testing.T) {
+	c := newIdempotencyCache(defaultIdempotencyTTL, defaultIdempotencyKeys)
+
+	entry, conflict := c.begin("k", "a")
+	assert.Nil(t, entry)
+	assert.Empty(t, conflict)
+
+	_, conflict = c.begin("k", "a")
+	assert.Contains(t, conflict, "in progress")
+
+	c.finish("k", http.StatusOK, http.Header{}, []byte("ok"))
+	entry, conflict = c.begin("k", "a")
+	require.NotNil(t, entry)
+	assert.Empty(t, conflict)
+	assert.Equal(t, "ok", string(entry.body))
+}
+
+func TestIdempotencyCache_Bounded(t *testing.T) {
+	c := newIdempotencyCache(defaultIdempotencyTTL, 2)
+
+	c.begin("a", "a")
+	c.finish("a", http.StatusOK, http.Header{}, []byte("a"))
+	c.begin("b", "b")
+	c.begin("c", "c")
+
+	// The stored response goes before requests in flight
+	assert.Len(t, c.entries, 2)
+	assert.NotContains(t, c.entries, "a")
+	c.begin("d", "d")
+	assert.Len(t, c.entries, 2)
+	assert.NotContains(t, c.entries, "b")
+}
+
+func TestOrderClaim_IdempotencyKeyFormat(t *testing.T) {
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+	})
+
+	send := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", strings.NewReader(`{"parameters":{}}`))
+		req.Header.Set("Idempotency-Key", "key-1")
+		req.Header.Set("X-Requester", "alice")
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("application/yaml")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = send("text/yaml")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+
+	// Another output format is a different request
+	rec = send("application/json")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// identityConfig decides which request headers the server trusts.
+// Approver identities (X-Requester, X-Forwarded-User, X-Forwarded-Groups)
+// and X-Forwarded-For are only accepted from trusted proxies, e.g. an
+// authenticating ingress or Backstage.
+type identityConfig struct {
+	// trustedProxies are the networks of proxies that authenticate users
+	trustedProxies []*net.IPNet
+
+	// approvers and approverGroups may approve and reject orders. Without
+	// either, approval is advisory: any caller naming an identity other
+	// than the requester can decide.
+	approvers      map[string]bool
+	approverGroups map[string]bool
+}
+
+// identityFromEnv reads TRUSTED_PROXIES, APPROVERS and APPROVER_GROUPS
+func identityFromEnv() identityConfig {
+	cfg := identityConfig{
+		approvers:      listSet(os.Getenv("APPROVERS")),
+		approverGroups: listSet(os.Getenv("APPROVER_GROUPS")),
+	}
+	for _, entry := range splitList(os.Getenv("TRUSTED_PROXIES")) {
+		network, err := parseNetwork(entry)
+		if err != nil {
+			log.Printf("⚠️  invalid TRUSTED_PROXIES entry %q: %v (skipping)", entry, err)
+			continue
+		}
+		cfg.trustedProxies = append(cfg.trustedProxies, network)
+	}
+	if cfg.approvalRestricted() && len(cfg.trustedProxies) == 0 {
+		log.Printf("⚠️  APPROVERS is set without TRUSTED_PROXIES, no request can approve orders")
+	}
+	return cfg
+}
+
+// parseNetwork accepts a CIDR or a single IP address
+func parseNetwork(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: s}
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	return network, err
+}
+
+func listSet(s string) map[string]bool {
+	items := splitList(s)
+	if len(items) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// approvalRestricted reports whether approvers are configured
+func (c identityConfig) approvalRestricted() bool {
+	return len(c.approvers) > 0 || len(c.approverGroups) > 0
+}
+
+// fromTrustedProxy reports whether the request was sent by a trusted proxy
+func (c identityConfig) fromTrustedProxy(r *http.Request) bool {
+	ip := net.ParseIP(remoteHost(r))
+	return ip != nil && c.containsIP(ip)
+}
+
+// clientIP returns the address of the client. Behind trusted proxies it is
+// the last X-Forwarded-For entry that is not a trusted proxy itself.
+func (c identityConfig) clientIP(r *http.Request) string {
+	host := remoteHost(r)
+	if !c.fromTrustedProxy(r) {
+		return host
+	}
+	hops := splitList(strings.Join(r.Header.Values("X-Forwarded-For"), ","))
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			break
+		}
+		if !c.containsIP(ip) {
+			return ip.String()
+		}
+	}
+	return host
+}
+
+func (c identityConfig) containsIP(ip net.IP) bool {
+	for _, network := range c.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// canApprove checks whether the caller may decide on orders. It returns the
+// reason if not.
+func (c identityConfig) canApprove(r *http.Request, actor string) (bool, string) {
+	if !c.approvalRestricted() {
+		return true, ""
+	}
+	if !c.fromTrustedProxy(r) {
+		return false, "approver identity must be set by a trusted proxy"
+	}
+	if c.approvers[actor] {
+		return true, ""
+	}
+	for _, group := range splitList(r.Header.Get("X-Forwarded-Groups")) {
+		if c.approverGroups[group] {
+			return true, ""
+		}
+	}
+	return false, actor + " is not an approver"
+}
+
+// remoteHost strips the port from the remote address
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
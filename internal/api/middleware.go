@@ -33,6 +33,11 @@ func (rw *responseRecorder) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController (flush, deadlines)
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // context key type to avoid collisions
 type ctxKey string
 
@@ -102,8 +107,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
-		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, X-Requester, X-API-Key, Idempotency-Key, If-None-Match, If-Modified-Since")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Deprecation, Sunset, Link, ETag, Last-Modified, Retry-After, Location, Idempotent-Replayed, Content-Location, Content-Disposition, X-Order-Name, Warning")
 
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stuttgart-things/claim-machinery-api/internal/app"
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/policy"
+	"github.com/stuttgart-things/claim-machinery-api/internal/schema"
+)
+
+// maxNameSuffix bounds the attempts to find a free order name
+const maxNameSuffix = 100
+
+// ApprovalRequest is the body of approve and reject requests
+type ApprovalRequest struct {
+	Comment string `json:"comment"`
+}
+
+// OrderListResponse wraps orders for the list endpoints
+type OrderListResponse struct {
+	APIVersion string         `json:"apiVersion"`
+	Kind       string         `json:"kind"`
+	Items      []*order.Order `json:"items"`
+}
+
+// listOrders returns stored orders, newest first; ?status= filters by state
+func (s *Server) listOrders(w http.ResponseWriter, r *http.Request) {
+	s.writeOrderList(w, s.orders.List(r.URL.Query().Get("status")))
+}
+
+// listApprovals returns orders waiting for approval
+func (s *Server) listApprovals(w http.ResponseWriter, r *http.Request) {
+	s.writeOrderList(w, s.orders.List(order.StatusPending))
+}
+
+func (s *Server) writeOrderList(w http.ResponseWriter, orders []*order.Order) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(OrderListResponse{
+		APIVersion: "api.claim-machinery.io/v1alpha1",
+		Kind:       "OrderList",
+		Items:      orders,
+	})
+}
+
+// getOrder returns an order with its audit trail
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	o, err := s.orders.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(o)
+}
+
+// approveOrder signs off a pending order, then renders and delivers it
+func (s *Server) approveOrder(w http.ResponseWriter, r *http.Request) {
+	s.decideOrder(w, r, true)
+}
+
+// rejectOrder closes a pending order without rendering it
+func (s *Server) rejectOrder(w http.ResponseWriter, r *http.Request) {
+	s.decideOrder(w, r, false)
+}
+
+func (s *Server) decideOrder(w http.ResponseWriter, r *http.Request, approve bool) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req ApprovalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// Approval delivers the rendered order, so the format is checked first
+	format := outputFormat{name: formatOrder}
+	if approve {
+		var err error
+		if format, err = negotiateOutput(r); err != nil {
+			writeError(w, http.StatusNotAcceptable, err.Error())
+			return
+		}
+	}
+
+	// Decisions are audited, so the approver must be known
+	actor := requesterFromRequest(r)
+	if actor == "" {
+		writeError(w, http.StatusBadRequest, "approver identity required (X-Requester header)")
+		return
+	}
+	// Without APPROVERS the identity is taken from the client and the
+	// decision is advisory only
+	if ok, reason := s.identity.canApprove(r, actor); !ok {
+		writeError(w, http.StatusForbidden, reason)
+		return
+	}
+
+	code := http.StatusInternalServerError
+	fail := func(c int, msg string) error {
+		code = c
+		return errors.New(msg)
+	}
+
+	var tmpl *claimtemplate.ClaimTemplate
+	o, err := s.orders.Update(mux.Vars(r)["id"], func(o *order.Order) error {
+		if o.Status != order.StatusPending {
+			return fail(http.StatusConflict, "order is "+o.Status+", not pending approval")
+		}
+		if o.Requester == actor {
+			return fail(http.StatusForbidden, "requesters cannot approve or reject their own orders")
+		}
+
+		if !approve {
+			o.Secrets = nil
+			o.Record(order.StatusRejected, order.ActionRejected, actor, req.Comment)
+			return nil
+		}
+
+		var ok bool
+		tmpl, ok = s.templates.GetVersion(o.Template, o.TemplateVersion)
+		if !ok {
+			return fail(http.StatusConflict, "template "+o.Template+"@"+o.TemplateVersion+" is no longer available")
+		}
+		// Secret values live in memory only and are lost on restart
+		for name := range app.SecretValues(tmpl, o.Parameters) {
+			if _, ok := o.Secrets[name]; !ok {
+				return fail(http.StatusConflict, "secret parameter values are no longer available, the order must be placed again")
+			}
+		}
+		o.Record(order.StatusApproved, order.ActionApproved, actor, req.Comment)
+		return nil
+	})
+	if errors.Is(err, order.ErrNotFound) {
+		code = http.StatusNotFound
+	}
+	if err != nil {
+		writeError(w, code, err.Error())
+		return
+	}
+
+	event := orderEventFor(o)
+	if !approve {
+		s.publishOrderEvent(EventOrderRejected, event)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(o)
+		return
+	}
+	s.publishOrderEvent(EventOrderApproved, event)
+
+	o, rendered, err := s.renderOrder(r.Context(), tmpl, o, o.ParameterValues())
+	if err != nil {
+		writeRenderError(w, err)
+		return
+	}
+	s.deliverOrder(w, http.StatusOK, o, rendered, format)
+}
+
+// newOrder builds an order from resolved parameters. Every order gets a
+// unique ID; the human readable name follows the naming strategy or the
+// template's spec.orderName pattern. Orders for templates that require
+// approval (or bundles with such a member) start as pending, all others
+// as approved.
+func (s *Server) newOrder(tmpl *claimtemplate.ClaimTemplate, params map[string]interface{}, requester, requestID string) (*order.Order, error) {
+	uid, err := app.NewULID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	name, err := app.OrderName(tmpl, params, s.naming, uid, now)
+	if err != nil {
+		return nil, errors.New(app.RedactString(tmpl, params, err.Error()))
+	}
+	members, err := s.orderMembers(tmpl, params, requester)
+	if err != nil {
+		return nil, errors.New(app.RedactString(tmpl, params, err.Error()))
+	}
+
+	o := &order.Order{
+		UID:             uid,
+		Name:            name,
+		Template:        tmpl.Metadata.Name,
+		TemplateVersion: tmpl.VersionKey(),
+		Requester:       requester,
+		RequestID:       requestID,
+		Parameters:      app.RedactParameters(tmpl, params),
+		Members:         members,
+		Revision:        1,
+		CreatedAt:       now,
+	}
+	if s.requiresApproval(tmpl) {
+		// Secret values are only kept until the order is decided
+		o.Secrets = app.SecretValues(tmpl, params)
+		o.Record(order.StatusPending, order.ActionCreated, requester, "")
+	} else {
+		o.Record(order.StatusApproved, order.ActionCreated, requester, "")
+	}
+	return o, nil
+}
+
+// createOrder stores a new order. UIDs are unique, but names from
+// timestamps or name patterns may repeat and get a numeric suffix.
+func (s *Server) createOrder(o *order.Order) error {
+	base := o.Name
+	for i := 2; i <= maxNameSuffix; i++ {
+		err := s.orders.Create(o)
+		if !errors.Is(err, order.ErrExists) {
+			return err
+		}
+		o.Name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return fmt.Errorf("%w: %s", order.ErrExists, base)
+}
+
+// renderOrder renders an order, records the outcome in its audit trail and
+// notifies subscribers. Secret values are dropped from the stored order
+// either way. Returned errors are redacted.
+func (s *Server) renderOrder(ctx context.Context, tmpl *claimtemplate.ClaimTemplate, o *order.Order, params map[string]interface{}) (*order.Order, string, error) {
+	rendered, redact, err := s.renderTemplate(tmpl, params, o.Requester)
+	var findings []order.PolicyResult
+	if err == nil {
+		rendered, findings, err = s.postRender(ctx, o, rendered, redact)
+	}
+	if err != nil {
+		msg := redact(err.Error())
+		s.updateOrder(o.UID, func(o *order.Order) {
+			o.Secrets = nil
+			o.Error = msg
+			o.Policies = findings
+			o.Record(order.StatusFailed, order.ActionFailed, "", msg)
+		})
+
+		event := orderEventFor(o)
+		event.Status, event.Error = order.StatusFailed, msg
+		s.publishOrderEvent(EventOrderFailed, event)
+		// Schema violations and policy denials keep their type so they can
+		// be listed in the response
+		var (
+			invalid *schema.ValidationError
+			denied  *policy.DeniedError
+		)
+		if errors.As(err, &invalid) || errors.As(err, &denied) {
+			return o, "", err
+		}
+		return o, "", errors.New(msg)
+	}
+
+	if updated := s.updateOrder(o.UID, func(o *order.Order) {
+		o.Secrets = nil
+		o.Rendered = redact(rendered)
+		o.Policies = findings
+		o.Record(order.StatusRendered, order.ActionRendered, "", policySummary(findings))
+	}); updated != nil {
+		o = updated
+	}
+
+	event := orderEventFor(o)
+	event.Status = order.StatusRendered
+	s.publishOrderEvent(EventOrderRendered, event)
+	return o, rendered, nil
+}
+
+// deliverOrder writes the rendered order in the negotiated format and emits
+// order.delivered once it was sent
+func (s *Server) deliverOrder(w http.ResponseWriter, code int, o *order.Order, rendered string, format outputFormat) {
+	if err := writeRendered(w, code, o, rendered, format); err != nil {
+		debugf("order %s: write response: %v", o.Name, err)
+		return
+	}
+
+	event := orderEventFor(o)
+	event.Status = "delivered"
+	s.publishOrderEvent(EventOrderDelivered, event)
+}
+
+// updateOrder applies a change that cannot be rejected; store errors are logged
+func (s *Server) updateOrder(id string, fn func(*order.Order)) *order.Order {
+	o, err := s.orders.Update(id, func(o *order.Order) error {
+		fn(o)
+		return nil
+	})
+	if err != nil {
+		log.Printf("⚠️  order %s: update failed: %v", id, err)
+		return nil
+	}
+	return o
+}
+
+func newOrderResponse(o *order.Order, rendered string) OrderResponse {
+	return OrderResponse{
+		APIVersion: "api.claim-machinery.io/v1alpha1",
+		Kind:       "OrderResponse",
+		Metadata: map[string]interface{}{
+			"uid":             o.UID,
+			"name":            o.Name,
+			"timestamp":       o.CreatedAt.Format(time.RFC3339),
+			"template":        o.Template,
+			"templateVersion": o.TemplateVersion,
+			"status":          o.Status,
+			"revision":        o.Revision,
+		},
+		Rendered: rendered,
+		Policies: o.Policies,
+	}
+}
+
+func orderEventFor(o *order.Order) OrderEvent {
+	return OrderEvent{
+		UID:             o.UID,
+		Name:            o.Name,
+		Template:        o.Template,
+		TemplateVersion: o.TemplateVersion,
+		Status:          o.Status,
+		Revision:        o.Revision,
+		RequestID:       o.RequestID,
+		Parameters:      o.Parameters,
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": msg,
+	})
+}
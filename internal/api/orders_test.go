@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+// newOrderTestServer creates a server whose renderer echoes the parameters
+// instead of calling the KCL CLI
+func newOrderTestServer(t *testing.T, templates ...*claimtemplate.ClaimTemplate) *Server {
+	t.Helper()
+
+	server, err := NewServerWithTemplates(templates)
+	require.NoError(t, err)
+	server.render = func(tmpl *claimtemplate.ClaimTemplate, params ...map[string]interface{}) (string, error) {
+		b, err := json.Marshal(params[0])
+		return "kind: " + tmpl.Metadata.Name + "\nparams: " + string(b) + "\n", err
+	}
+	return server
+}
+
+func approvalTemplate() *claimtemplate.ClaimTemplate {
+	return &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "vsphere-vm"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			RequiresApproval: true,
+			Parameters: []claimtemplate.Parameter{
+				{Name: "cpu", Type: "number", Default: 2},
+				{Name: "rootPassword", Type: "string", Secret: true},
+			},
+		},
+	}
+}
+
+func doRequest(server *Server, method, path, requester, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if requester != "" {
+		req.Header.Set("X-Requester", requester)
+	}
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func placePendingOrder(t *testing.T, server *Server) string {
+	t.Helper()
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/vsphere-vm/order", "alice",
+		`{"parameters":{"cpu":8,"rootPassword":"hunter2"}}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, order.StatusPending, resp.Metadata["status"])
+	assert.Empty(t, resp.Rendered)
+
+	uid := resp.Metadata["uid"].(string)
+	assert.Equal(t, "/api/v1/orders/"+uid, rec.Header().Get("Location"))
+	return uid
+}
+
+func TestOrderClaim_RecordsOrder(t *testing.T) {
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+	})
+
+	first := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{}}`)
+	second := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{}}`)
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Equal(t, http.StatusOK, second.Code)
+
+	orders := server.orders.List("")
+	require.Len(t, orders, 2)
+	assert.NotEqual(t, orders[0].Name, orders[1].Name)
+	assert.NotEqual(t, orders[0].UID, orders[1].UID)
+	assert.True(t, strings.HasPrefix(orders[0].Name, "volumeclaim-order-"))
+
+	// Orders are addressable by UID and by name
+	for _, id := range []string{orders[0].UID, orders[0].Name} {
+		rec := doRequest(server, http.MethodGet, "/api/v1/orders/"+id, "", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Equal(t, order.StatusRendered, orders[0].Status)
+	assert.Equal(t, "alice", orders[0].Requester)
+	require.Len(t, orders[0].History, 2)
+	assert.Equal(t, order.ActionCreated, orders[0].History[0].Action)
+	assert.Equal(t, order.ActionRendered, orders[0].History[1].Action)
+}
+
+func TestOrderClaim_NamePattern(t *testing.T) {
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "postgresql"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			OrderName:  "{{ .namespace }}-db",
+			Parameters: []claimtemplate.Parameter{{Name: "namespace", Type: "string"}},
+		},
+	})
+
+	names := make([]string, 2)
+	for i := range names {
+		rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/postgresql/order", "alice", `{"parameters":{"namespace":"team-a"}}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp OrderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		names[i] = resp.Metadata["name"].(string)
+		assert.NotEmpty(t, resp.Metadata["uid"])
+	}
+
+	// Repeated pattern names get a suffix
+	assert.Equal(t, []string{"team-a-db", "team-a-db-2"}, names)
+}
+
+func TestApproval_Approve(t *testing.T) {
+	server := newOrderTestServer(t, approvalTemplate())
+	id := placePendingOrder(t, server)
+
+	rec := doRequest(server, http.MethodGet, "/api/v1/approvals", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list OrderListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, id, list.Items[0].UID)
+	assert.NotContains(t, rec.Body.String(), "hunter2")
+
+	// Requesters cannot sign off their own orders
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+id+"/approve", "alice", `{}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Approvers must identify themselves
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+id+"/approve", "", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+id+"/approve", "bob", `{"comment":"budget ok"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, order.StatusRendered, resp.Metadata["status"])
+	assert.Contains(t, resp.Rendered, "hunter2", "approval delivers the full rendered output")
+
+	// The stored order carries the audit trail without secrets
+	rec = doRequest(server, http.MethodGet, "/api/v1/orders/"+id, "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "hunter2")
+	var stored order.Order
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stored))
+	require.Len(t, stored.History, 3)
+	assert.Equal(t, order.ActionApproved, stored.History[1].Action)
+	assert.Equal(t, "bob", stored.History[1].Actor)
+	assert.Equal(t, "budget ok", stored.History[1].Comment)
+
+	// Decisions are final
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+id+"/reject", "carol", `{}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestApproval_Reject(t *testing.T) {
+	server := newOrderTestServer(t, approvalTemplate())
+	id := placePendingOrder(t, server)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders/"+id+"/reject", "bob", `{"comment":"too large"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var o order.Order
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &o))
+	assert.Equal(t, order.StatusRejected, o.Status)
+	assert.Empty(t, o.Rendered)
+	assert.Equal(t, "too large", o.History[len(o.History)-1].Comment)
+
+	rec = doRequest(server, http.MethodGet, "/api/v1/approvals", "", "")
+	assert.Contains(t, rec.Body.String(), `"items":[]`)
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/missing/approve", "bob", `{}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestApproval_Approvers(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+	t.Setenv("APPROVERS", "bob")
+	t.Setenv("APPROVER_GROUPS", "platform")
+	server := newOrderTestServer(t, approvalTemplate())
+	id := placePendingOrder(t, server)
+
+	decide := func(remoteAddr, requester, groups string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/orders/"+id+"/reject", strings.NewReader(`{}`))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Requester", requester)
+		if groups != "" {
+			req.Header.Set("X-Forwarded-Groups", groups)
+		}
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Identities sent by clients directly are not trusted
+	rec := decide("192.0.2.1:1234", "bob", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "trusted proxy")
+
+	// Only configured approvers and groups may decide
+	rec = decide("10.1.2.3:1234", "carol", "dev")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "carol is not an approver")
+
+	rec = decide("10.1.2.3:1234", "carol", "dev, platform")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	id = placePendingOrder(t, server)
+	rec = decide("10.1.2.3:1234", "bob", "")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestApproval_SecretsDropped(t *testing.T) {
+	server := newOrderTestServer(t, approvalTemplate(), &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "postgresql"},
+		Spec: claimtemplate.ClaimTemplateSpec{Parameters: []claimtemplate.Parameter{
+			{Name: "password", Type: "string", Secret: true},
+		}},
+	})
+
+	// Orders rendered right away never keep secret values
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/postgresql/order", "alice", `{"parameters":{"password":"hunter2"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	o, err := server.orders.Get(resp.Metadata["uid"].(string))
+	require.NoError(t, err)
+	assert.Empty(t, o.Secrets)
+
+	// Pending orders keep them until they are decided
+	id := placePendingOrder(t, server)
+	o, err = server.orders.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", o.Secrets["rootPassword"])
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+id+"/reject", "bob", `{}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	o, err = server.orders.Get(id)
+	require.NoError(t, err)
+	assert.Empty(t, o.Secrets)
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+// Output formats of rendered orders
+const (
+	// formatOrder is the OrderResponse with the rendered YAML as a string
+	formatOrder = "order"
+	// formatYAML is the raw multi-document YAML
+	formatYAML = "yaml"
+	// formatJSON is a JSON array of the parsed manifests
+	formatJSON = "json"
+	// formatKustomize is an archive with one file per manifest and a kustomization.yaml
+	formatKustomize = "kustomize"
+	// formatManifests is an archive with one file per manifest
+	formatManifests = "manifests"
+)
+
+// orderMediaType explicitly selects the OrderResponse
+const orderMediaType = "application/vnd.claim-machinery.order+json"
+
+// outputFormat is the negotiated representation of a rendered order
+type outputFormat struct {
+	name    string
+	archive string
+}
+
+// mediaTypes maps Accept media types to output formats
+var mediaTypes = map[string]outputFormat{
+	orderMediaType:       {name: formatOrder},
+	"application/yaml":   {name: formatYAML},
+	"application/x-yaml": {name: formatYAML},
+	"text/yaml":          {name: formatYAML},
+	"application/json":   {name: formatJSON},
+	"application/x-tar":  {name: formatKustomize, archive: manifest.ArchiveTar},
+	"application/zip":    {name: formatKustomize, archive: manifest.ArchiveZip},
+	"*/*":                {name: formatOrder},
+	"application/*":      {name: formatOrder},
+}
+
+// negotiateOutput picks the output format from the format and archive
+// query parameters or, without them, from the Accept header. Requests
+// without a preference get the OrderResponse.
+func negotiateOutput(r *http.Request) (outputFormat, error) {
+	query := r.URL.Query()
+	if name := query.Get("format"); name != "" {
+		f := outputFormat{name: name}
+		switch name {
+		case formatOrder, formatYAML, formatJSON:
+		case formatKustomize, formatManifests:
+			f.archive = query.Get("archive")
+			if f.archive == "" {
+				f.archive = manifest.ArchiveTar
+			}
+			if f.archive != manifest.ArchiveTar && f.archive != manifest.ArchiveZip {
+				return f, fmt.Errorf("invalid archive %q: use tar or zip", f.archive)
+			}
+		default:
+			return f, fmt.Errorf("invalid format %q: use order, yaml, json, kustomize or manifests", name)
+		}
+		return f, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return outputFormat{name: formatOrder}, nil
+	}
+	for _, mediaType := range acceptedMediaTypes(accept) {
+		if f, ok := mediaTypes[mediaType]; ok {
+			return f, nil
+		}
+	}
+	return outputFormat{}, fmt.Errorf("none of the accepted media types is supported: use %s, application/yaml, application/json, application/x-tar or application/zip", orderMediaType)
+}
+
+// acceptedMediaTypes returns the media types of an Accept header ordered
+// by preference; types with q=0 are dropped
+func acceptedMediaTypes(header string) []string {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var list []accepted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if mediaType != "" && q > 0 {
+			list = append(list, accepted{mediaType, q})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].q > list[j].q })
+
+	out := make([]string, len(list))
+	for i, a := range list {
+		out[i] = a.mediaType
+	}
+	return out
+}
+
+// writeRendered writes the rendered output of an order in the requested format.
+// Formats other than the OrderResponse carry the order in Content-Location
+// and X-Order-Name headers.
+func writeRendered(w http.ResponseWriter, code int, o *order.Order, rendered string, format outputFormat) error {
+	setPolicyWarnings(w, o.Policies)
+	if format.name == formatOrder {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		return json.NewEncoder(w).Encode(newOrderResponse(o, rendered))
+	}
+
+	var (
+		body        []byte
+		contentType string
+	)
+	switch format.name {
+	case formatYAML:
+		body, contentType = []byte(rendered), "application/yaml"
+	default:
+		docs, err := manifest.Split(rendered)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "rendered output is not valid YAML: "+err.Error())
+			return err
+		}
+		if body, contentType, err = encodeManifests(docs, o.Name, format); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Location", "/api/v1/orders/"+o.UID)
+	w.Header().Set("X-Order-Name", o.Name)
+	if format.archive != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+o.Name+"."+format.archive+`"`)
+	}
+	w.WriteHeader(code)
+	_, err := w.Write(body)
+	return err
+}
+
+// encodeManifests encodes parsed manifests as a JSON array or an archive
+func encodeManifests(docs []manifest.Document, dir string, format outputFormat) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if format.name == formatJSON {
+		objects := make([]map[string]interface{}, len(docs))
+		for i, d := range docs {
+			objects[i] = d.Object
+		}
+		if err := json.NewEncoder(&buf).Encode(objects); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "application/json", nil
+	}
+
+	files, err := manifest.Files(docs, format.name == formatKustomize)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := manifest.WriteArchive(&buf, format.archive, dir, files); err != nil {
+		return nil, "", err
+	}
+	if format.archive == manifest.ArchiveZip {
+		return buf.Bytes(), "application/zip", nil
+	}
+	return buf.Bytes(), "application/x-tar", nil
+}
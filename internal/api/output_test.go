@@ -0,0 +1,122 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func newOutputTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+	})
+	server.render = func(tmpl *claimtemplate.ClaimTemplate, params ...map[string]interface{}) (string, error) {
+		return "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: team-a\n---\n" +
+			"apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata:\n  name: data\n  namespace: team-a\n", nil
+	}
+	return server
+}
+
+func orderWithAccept(server *Server, accept, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/claim-templates/volumeclaim/order"+query, strings.NewReader(`{"parameters":{}}`))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOrderOutput_Formats(t *testing.T) {
+	server := newOutputTestServer(t)
+
+	// Without a preference the OrderResponse is returned
+	for _, accept := range []string{"", "*/*", orderMediaType} {
+		rec := orderWithAccept(server, accept, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp OrderResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Contains(t, resp.Rendered, "kind: Namespace")
+	}
+
+	rec := orderWithAccept(server, "application/yaml", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "apiVersion: v1\nkind: Namespace"))
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Location"), "/api/v1/orders/"))
+	assert.NotEmpty(t, rec.Header().Get("X-Order-Name"))
+
+	rec = orderWithAccept(server, "application/json", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var objects []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &objects))
+	require.Len(t, objects, 2)
+	assert.Equal(t, "PersistentVolumeClaim", objects[1]["kind"])
+
+	// The query parameter takes precedence over Accept
+	rec = orderWithAccept(server, "application/json", "?format=yaml")
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+}
+
+func TestOrderOutput_Archives(t *testing.T) {
+	server := newOutputTestServer(t)
+
+	rec := orderWithAccept(server, "application/x-tar", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	name := rec.Header().Get("X-Order-Name")
+	assert.Equal(t, `attachment; filename="`+name+`.tar"`, rec.Header().Get("Content-Disposition"))
+
+	var files []string
+	tr := tar.NewReader(rec.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		files = append(files, hdr.Name)
+	}
+	assert.Equal(t, []string{
+		name + "/00-namespace-team-a.yaml",
+		name + "/01-persistentvolumeclaim-data.yaml",
+		name + "/kustomization.yaml",
+	}, files)
+
+	// Plain manifests come without a kustomization.yaml
+	rec = orderWithAccept(server, "", "?format=manifests&archive=zip")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+}
+
+func TestOrderOutput_NotAcceptable(t *testing.T) {
+	server := newOutputTestServer(t)
+
+	rec := orderWithAccept(server, "text/html", "")
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+
+	rec = orderWithAccept(server, "", "?format=helm")
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+
+	// Nothing is ordered for unsupported formats
+	assert.Empty(t, server.orders.List(""))
+
+	// Preferences are honoured by quality
+	assert.Equal(t, []string{"application/yaml", "application/json"},
+		acceptedMediaTypes("application/json;q=0.5, application/yaml, text/html;q=0"))
+}
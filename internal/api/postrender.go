@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/mutate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/policy"
+	"github.com/stuttgart-things/claim-machinery-api/internal/schema"
+)
+
+// WithPolicies evaluates policies against rendered output before it is
+// delivered
+func WithPolicies(e *policy.Engine) Option {
+	return func(s *Server) {
+		s.policies = e
+	}
+}
+
+// WithMutations adds labels, annotations and namespaces to rendered output
+// before it is validated and delivered
+func WithMutations(m *mutate.Mutator) Option {
+	return func(s *Server) {
+		s.mutator = m
+	}
+}
+
+// WithSchemaValidator checks rendered output against CRD schemas before it
+// is delivered
+func WithSchemaValidator(v *schema.Validator) Option {
+	return func(s *Server) {
+		s.schemas = v
+	}
+}
+
+// postRender runs the post-render stage on rendered output: mutations are
+// applied first, then the result is validated against the configured CRD
+// schemas and policies. Findings are redacted like the output itself; deny
+// findings fail the order, warnings are returned with it.
+func (s *Server) postRender(ctx context.Context, o *order.Order, rendered string, redact func(string) string) (string, []order.PolicyResult, error) {
+	if s.mutator != nil {
+		mutated, err := s.mutator.Apply(o, rendered)
+		if err != nil {
+			return "", nil, fmt.Errorf("mutate rendered output: %w", err)
+		}
+		rendered = mutated
+	}
+	if s.schemas == nil && s.policies == nil {
+		return rendered, nil, nil
+	}
+	docs, err := manifest.Split(rendered)
+	if err != nil {
+		return "", nil, fmt.Errorf("rendered output is not valid YAML: %w", err)
+	}
+
+	if s.schemas != nil {
+		if violations := s.schemas.Validate(docs); len(violations) > 0 {
+			for i := range violations {
+				violations[i].Message = redact(violations[i].Message)
+			}
+			return "", nil, &schema.ValidationError{Violations: violations}
+		}
+	}
+
+	if s.policies == nil {
+		return rendered, nil, nil
+	}
+	results := s.policies.Evaluate(ctx, o, docs)
+	for i := range results {
+		results[i].Message = redact(results[i].Message)
+	}
+	if policy.Denied(results) {
+		return "", results, &policy.DeniedError{Results: results}
+	}
+	return rendered, results, nil
+}
+
+// writeRenderError answers a failed render: schema violations are
+// unprocessable, policy denials forbidden, everything else is a 500
+func writeRenderError(w http.ResponseWriter, err error) {
+	var (
+		invalid *schema.ValidationError
+		denied  *policy.DeniedError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      invalid.Error(),
+			"violations": invalid.Violations,
+		})
+	case errors.As(err, &denied):
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    denied.Error(),
+			"policies": denied.Results,
+		})
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// policySummary is the audit comment for policy warnings
+func policySummary(results []order.PolicyResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		names = append(names, r.Policy)
+	}
+	return "policy warnings: " + strings.Join(names, ", ")
+}
+
+// setPolicyWarnings adds a Warning header per policy warning so that
+// clients of raw formats see them too
+func setPolicyWarnings(w http.ResponseWriter, results []order.PolicyResult) {
+	for _, r := range results {
+		if r.Action == policy.ActionWarn {
+			w.Header().Add("Warning", "299 - "+strconv.Quote(r.Policy+": "+r.Message))
+		}
+	}
+}
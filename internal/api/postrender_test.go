@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/mutate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/policy"
+	"github.com/stuttgart-things/claim-machinery-api/internal/schema"
+)
+
+const testVolumeClaimCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: volumeclaims.resources.stuttgart-things.com
+spec:
+  group: resources.stuttgart-things.com
+  names:
+    kind: VolumeClaim
+  versions:
+    - name: v1alpha1
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [storage]
+              properties:
+                storage:
+                  type: string
+                  pattern: '^[0-9]+Gi$'
+`
+
+func newValidationTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	validator, err := schema.NewValidator([]byte(testVolumeClaimCRD))
+	require.NoError(t, err)
+
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{{Name: "storage", Type: "string"}},
+		},
+	})
+	server.schemas = validator
+	server.render = func(tmpl *claimtemplate.ClaimTemplate, params ...map[string]interface{}) (string, error) {
+		return "apiVersion: resources.stuttgart-things.com/v1alpha1\nkind: VolumeClaim\nmetadata:\n  name: data\n" +
+			"spec:\n  storage: " + params[0]["storage"].(string) + "\n", nil
+	}
+	return server
+}
+
+func TestOrderClaim_SchemaValid(t *testing.T) {
+	server := newValidationTestServer(t)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice",
+		`{"parameters":{"storage":"10Gi"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestOrderClaim_SchemaViolation(t *testing.T) {
+	server := newValidationTestServer(t)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice",
+		`{"parameters":{"storage":"lots"}}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Error      string             `json:"error"`
+		Violations []schema.Violation `json:"violations"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Violations, 1)
+	assert.Equal(t, "VolumeClaim", resp.Violations[0].Kind)
+	assert.Equal(t, "data", resp.Violations[0].Name)
+	assert.Equal(t, "spec.storage", resp.Violations[0].Field)
+
+	// The order is recorded as failed with the violations
+	orders := server.orders.List("")
+	require.Len(t, orders, 1)
+	assert.Equal(t, order.StatusFailed, orders[0].Status)
+	assert.Contains(t, orders[0].Error, "spec.storage")
+}
+
+func newPolicyTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	cfg := &policy.Config{Policies: []policy.Policy{
+		{
+			Name:       "dev-volume-size",
+			Match:      policy.Match{Kinds: []string{"VolumeClaim"}},
+			Expression: `order.parameters.environment != "dev" || quantity(object.spec.storage) <= quantity("500Gi")`,
+			Message:    "volumes in dev are limited to 500Gi",
+		},
+		{
+			Name:       "owner-label",
+			Action:     policy.ActionWarn,
+			Expression: `has(object.metadata.labels) && has(object.metadata.labels.owner)`,
+			Message:    "labels.owner is required",
+		},
+	}}
+	require.NoError(t, cfg.Validate())
+
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{
+				{Name: "storage", Type: "string"},
+				{Name: "environment", Type: "string"},
+			},
+		},
+	})
+	server.policies = policy.NewEngine(cfg)
+	server.render = func(tmpl *claimtemplate.ClaimTemplate, params ...map[string]interface{}) (string, error) {
+		return "apiVersion: resources.stuttgart-things.com/v1alpha1\nkind: VolumeClaim\nmetadata:\n  name: data\n" +
+			"spec:\n  storage: " + params[0]["storage"].(string) + "\n", nil
+	}
+	return server
+}
+
+func TestOrderClaim_PolicyWarning(t *testing.T) {
+	server := newPolicyTestServer(t)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice",
+		`{"parameters":{"storage":"600Gi","environment":"prod"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `299 - "owner-label: labels.owner is required"`, rec.Header().Get("Warning"))
+
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Policies, 1)
+	assert.Equal(t, "owner-label", resp.Policies[0].Policy)
+	assert.Equal(t, policy.ActionWarn, resp.Policies[0].Action)
+
+	// Warnings are recorded with the order
+	o, err := server.orders.Get(resp.Metadata["uid"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusRendered, o.Status)
+	assert.Equal(t, resp.Policies, o.Policies)
+	assert.Equal(t, "policy warnings: owner-label", o.History[len(o.History)-1].Comment)
+}
+
+func TestOrderClaim_PolicyDenied(t *testing.T) {
+	server := newPolicyTestServer(t)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice",
+		`{"parameters":{"storage":"600Gi","environment":"dev"}}`)
+	require.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Error    string               `json:"error"`
+		Policies []order.PolicyResult `json:"policies"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "denied by policy: dev-volume-size: VolumeClaim data: volumes in dev are limited to 500Gi", resp.Error)
+	require.Len(t, resp.Policies, 2)
+
+	orders := server.orders.List("")
+	require.Len(t, orders, 1)
+	assert.Equal(t, order.StatusFailed, orders[0].Status)
+	assert.Len(t, orders[0].Policies, 2)
+}
+
+func TestOrderClaim_Mutations(t *testing.T) {
+	cfg := &mutate.Config{Mutations: []mutate.Mutation{{
+		Name:        "ownership",
+		Labels:      map[string]string{"owner": "{{ .order.requester }}"},
+		Annotations: map[string]string{"claim-machinery.io/order-id": "{{ .order.uid }}"},
+		Namespace:   "team-{{ .order.requester }}",
+	}}}
+	require.NoError(t, cfg.Validate())
+
+	// The owner label added by the mutation satisfies the policy
+	server := newPolicyTestServer(t)
+	server.mutator = mutate.NewMutator(cfg)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice",
+		`{"parameters":{"storage":"10Gi","environment":"prod"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Warning"))
+
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Policies)
+	uid := resp.Metadata["uid"].(string)
+	assert.Contains(t, resp.Rendered, "  namespace: team-alice\n")
+	assert.Contains(t, resp.Rendered, "    owner: alice\n")
+	assert.Contains(t, resp.Rendered, "    claim-machinery.io/order-id: "+uid+"\n")
+
+	o, err := server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Equal(t, resp.Rendered, o.Rendered)
+}
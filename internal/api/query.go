@@ -0,0 +1,262 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// templateQuery holds the filter, sort and pagination options of a list request
+type templateQuery struct {
+	tags      []string
+	specType  string
+	kind      string
+	labels    map[string]string
+	lifecycle string
+	category  string
+	search    string
+	sortBy    string
+	desc      bool
+	fields    []string
+	limit     int // 0 returns all matches
+	offset    int
+}
+
+// parseTemplateQuery reads list options from the query string:
+//
+//	tags=a,b              templates carrying all tags
+//	type=volumeclaim      spec.type
+//	kind=ClaimBundle      kind (ClaimTemplate or ClaimBundle)
+//	labelSelector=k=v,... metadata.labels
+//	lifecycle=deprecated  metadata.lifecycle
+//	category=team-a       metadata.category, including subcategories
+//	q=text                case-insensitive search over name, title and description
+//	sort=name|-title|...  sort field (name, title, type, version), "-" for descending
+//	fields=metadata.name  comma-separated field selection
+//	limit=20&cursor=...   pagination; without either all matches are returned
+func parseTemplateQuery(values url.Values) (*templateQuery, error) {
+	q := &templateQuery{
+		tags:      splitList(values.Get("tags")),
+		specType:  values.Get("type"),
+		kind:      values.Get("kind"),
+		lifecycle: values.Get("lifecycle"),
+		category:  strings.Trim(values.Get("category"), "/"),
+		search:    strings.ToLower(strings.TrimSpace(values.Get("q"))),
+		sortBy:    "name",
+		fields:    splitList(values.Get("fields")),
+	}
+
+	if selector := values.Get("labelSelector"); selector != "" {
+		q.labels = make(map[string]string)
+		for _, term := range splitList(selector) {
+			key, value, ok := strings.Cut(term, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid labelSelector term %q: expected key=value", term)
+			}
+			q.labels[key] = value
+		}
+	}
+
+	if sortBy := values.Get("sort"); sortBy != "" {
+		q.desc = strings.HasPrefix(sortBy, "-")
+		q.sortBy = strings.TrimPrefix(sortBy, "-")
+		switch q.sortBy {
+		case "name", "title", "type", "version":
+		default:
+			return nil, fmt.Errorf("invalid sort field %q: use name, title, type or version", q.sortBy)
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxListLimit {
+			return nil, fmt.Errorf("invalid limit %q: must be between 1 and %d", limit, maxListLimit)
+		}
+		q.limit = n
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		offset, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.offset = offset
+		if q.limit == 0 {
+			q.limit = defaultListLimit
+		}
+	}
+
+	return q, nil
+}
+
+// matches reports whether a template passes all filters
+func (q *templateQuery) matches(t *claimtemplate.ClaimTemplate) bool {
+	if q.specType != "" && t.Spec.Type != q.specType {
+		return false
+	}
+	if q.kind != "" && t.Kind != q.kind {
+		return false
+	}
+	if q.lifecycle != "" && t.Metadata.Lifecycle != q.lifecycle {
+		return false
+	}
+	if q.category != "" && t.Metadata.Category != q.category && !strings.HasPrefix(t.Metadata.Category, q.category+"/") {
+		return false
+	}
+	for _, tag := range q.tags {
+		if !containsString(t.Metadata.Tags, tag) {
+			return false
+		}
+	}
+	for k, v := range q.labels {
+		if t.Metadata.Labels[k] != v {
+			return false
+		}
+	}
+	if q.search != "" {
+		text := strings.ToLower(t.Metadata.Name + " " + t.Metadata.Title + " " + t.Metadata.Description)
+		if !strings.Contains(text, q.search) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply filters, sorts and paginates templates. It returns the page, the
+// total number of matches and the cursor of the next page (empty on the last page).
+func (q *templateQuery) apply(templates []*claimtemplate.ClaimTemplate) ([]*claimtemplate.ClaimTemplate, int, string) {
+	matched := make([]*claimtemplate.ClaimTemplate, 0, len(templates))
+	for _, t := range templates {
+		if q.matches(t) {
+			matched = append(matched, t)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		c := compareBy(q.sortBy, matched[i], matched[j])
+		if c == 0 {
+			// Tie-breaker keeps the order stable across requests
+			c = strings.Compare(matched[i].Metadata.Name, matched[j].Metadata.Name)
+		}
+		if q.desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	total := len(matched)
+	if q.offset >= total {
+		return []*claimtemplate.ClaimTemplate{}, total, ""
+	}
+
+	end := q.offset + q.limit
+	next := ""
+	if q.limit > 0 && end < total {
+		next = encodeCursor(end)
+	} else {
+		end = total
+	}
+	return matched[q.offset:end], total, next
+}
+
+func compareBy(field string, a, b *claimtemplate.ClaimTemplate) int {
+	switch field {
+	case "title":
+		return strings.Compare(strings.ToLower(a.Metadata.Title), strings.ToLower(b.Metadata.Title))
+	case "type":
+		return strings.Compare(a.Spec.Type, b.Spec.Type)
+	case "version":
+		return claimtemplate.CompareVersions(a.VersionKey(), b.VersionKey())
+	default:
+		return strings.Compare(a.Metadata.Name, b.Metadata.Name)
+	}
+}
+
+// selectFields reduces a template to the requested dotted JSON paths
+func selectFields(t *claimtemplate.ClaimTemplate, fields []string) (map[string]interface{}, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var full map[string]interface{}
+	if err := json.Unmarshal(b, &full); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]interface{})
+	for _, field := range fields {
+		parts := strings.Split(field, ".")
+
+		var value interface{} = full
+		for _, part := range parts {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = m[part]
+		}
+		if value == nil {
+			continue
+		}
+
+		target := out
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := target[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = value
+	}
+	return out, nil
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	offset, err := strconv.Atoi(string(b))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return offset, nil
+}
+
+// splitList splits a comma-separated query value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
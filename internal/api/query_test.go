@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func newCatalogTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	server, err := NewServerWithTemplates([]*claimtemplate.ClaimTemplate{
+		{
+			Metadata: claimtemplate.ClaimTemplateMetadata{
+				Name: "volumeclaim", Title: "Volume Claim", Description: "Persistent storage",
+				Tags: []string{"storage", "crossplane"}, Category: "platform/storage",
+			},
+			Spec: claimtemplate.ClaimTemplateSpec{Type: "volumeclaim"},
+		},
+		{
+			Metadata: claimtemplate.ClaimTemplateMetadata{
+				Name: "postgresql", Title: "PostgreSQL", Description: "Managed database",
+				Tags: []string{"database", "crossplane"}, Labels: map[string]string{"category": "database"},
+				Category: "platform",
+			},
+			Spec: claimtemplate.ClaimTemplateSpec{Type: "database"},
+		},
+		{
+			Metadata: claimtemplate.ClaimTemplateMetadata{
+				Name: "harborproject", Title: "Harbor Project", Description: "Container registry project",
+				Tags: []string{"registry"}, Lifecycle: claimtemplate.LifecycleDeprecated,
+			},
+			Spec: claimtemplate.ClaimTemplateSpec{Type: "harbor"},
+		},
+	})
+	require.NoError(t, err)
+	return server
+}
+
+func listTemplateNames(t *testing.T, server *Server, query string) ClaimTemplateListResponse {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/claim-templates"+query, nil)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp ClaimTemplateListResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	return resp
+}
+
+func names(resp ClaimTemplateListResponse) []string {
+	out := make([]string, 0, len(resp.Items))
+	for _, item := range resp.Items {
+		out = append(out, item.Metadata.Name)
+	}
+	return out
+}
+
+func TestListTemplates_Filters(t *testing.T) {
+	server := newCatalogTestServer(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "default sort by name", query: "", want: []string{"harborproject", "postgresql", "volumeclaim"}},
+		{name: "descending", query: "?sort=-name", want: []string{"volumeclaim", "postgresql", "harborproject"}},
+		{name: "sort by title", query: "?sort=title", want: []string{"harborproject", "postgresql", "volumeclaim"}},
+		{name: "tags", query: "?tags=crossplane,storage", want: []string{"volumeclaim"}},
+		{name: "type", query: "?type=database", want: []string{"postgresql"}},
+		{name: "labels", query: "?labelSelector=category=database", want: []string{"postgresql"}},
+		{name: "lifecycle", query: "?lifecycle=deprecated", want: []string{"harborproject"}},
+		{name: "category with subcategories", query: "?category=platform", want: []string{"postgresql", "volumeclaim"}},
+		{name: "subcategory", query: "?category=platform/storage/", want: []string{"volumeclaim"}},
+		{name: "category prefix is not a subcategory", query: "?category=plat", want: []string{}},
+		{name: "search", query: "?q=STORAGE", want: []string{"volumeclaim"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := listTemplateNames(t, server, tt.query)
+			assert.Equal(t, tt.want, names(resp))
+			assert.Equal(t, len(tt.want), resp.Total)
+		})
+	}
+}
+
+func TestListTemplates_Pagination(t *testing.T) {
+	server := newCatalogTestServer(t)
+
+	first := listTemplateNames(t, server, "?limit=2")
+	assert.Equal(t, []string{"harborproject", "postgresql"}, names(first))
+	assert.Equal(t, 3, first.Total)
+	require.NotEmpty(t, first.NextCursor)
+
+	second := listTemplateNames(t, server, "?limit=2&cursor="+first.NextCursor)
+	assert.Equal(t, []string{"volumeclaim"}, names(second))
+	assert.Empty(t, second.NextCursor)
+}
+
+func TestTemplateQuery_DefaultLimit(t *testing.T) {
+	templates := make([]*claimtemplate.ClaimTemplate, defaultListLimit+5)
+	for i := range templates {
+		templates[i] = &claimtemplate.ClaimTemplate{Metadata: claimtemplate.ClaimTemplateMetadata{Name: fmt.Sprintf("t%03d", i)}}
+	}
+
+	// Without limit and cursor all templates are returned
+	q, err := parseTemplateQuery(url.Values{})
+	require.NoError(t, err)
+	page, total, next := q.apply(templates)
+	assert.Len(t, page, len(templates))
+	assert.Equal(t, len(templates), total)
+	assert.Empty(t, next)
+
+	// A cursor alone pages with the default limit
+	q, err = parseTemplateQuery(url.Values{"cursor": {encodeCursor(1)}})
+	require.NoError(t, err)
+	page, _, next = q.apply(templates)
+	assert.Len(t, page, defaultListLimit)
+	assert.Equal(t, "t001", page[0].Metadata.Name)
+	assert.NotEmpty(t, next)
+}
+
+func TestListTemplates_FieldSelection(t *testing.T) {
+	server := newCatalogTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/claim-templates?fields=metadata.name,spec.type&type=database", nil)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp claimTemplateFieldListResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "postgresql"},
+		"spec":     map[string]interface{}{"type": "database"},
+	}, resp.Items[0])
+}
+
+func TestListTemplates_InvalidQuery(t *testing.T) {
+	server := newCatalogTestServer(t)
+
+	for _, query := range []string{"?limit=0", "?sort=size", "?cursor=!!", "?labelSelector=broken"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/claim-templates"+query, nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
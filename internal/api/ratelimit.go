@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+)
+
+// WithQuotas enables per-client rate limiting of write requests and order quotas
+func WithQuotas(cfg *quota.Config) Option {
+	return func(s *Server) {
+		if cfg.RateLimit != nil {
+			s.limiter = quota.NewLimiter(*cfg.RateLimit)
+		}
+		s.quotas = cfg.Quotas
+	}
+}
+
+// rateLimitMiddleware throttles write requests with a token bucket per client.
+// Reads (catalog, watch, orders) are not limited.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, wait := s.limiter.Allow(s.clientKey(r))
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rate, burst := s.limiter.Limit()
+		retryAfter := retryAfterSeconds(wait)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":             "rate limit exceeded",
+			"requestsPerSecond": rate,
+			"burst":             burst,
+			"retryAfter":        retryAfter,
+		})
+	})
+}
+
+// placeOrder checks quotas and stores a new order. Check and create are
+// serialized so concurrent orders cannot overshoot a quota. On failure the
+// error response has been written.
+func (s *Server) placeOrder(w http.ResponseWriter, o *order.Order, params map[string]interface{}) bool {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+
+	if err := s.checkQuota(s.orders.List(""), o, params); err != nil {
+		writeQuotaError(w, err)
+		return false
+	}
+	if err := s.createOrder(o); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
+
+// checkQuota checks a new order against the configured quotas, counting
+// the given existing orders. Bundle orders are checked with their members.
+// Callers hold quotaMu.
+func (s *Server) checkQuota(existing []*order.Order, o *order.Order, params map[string]interface{}) error {
+	if len(s.quotas) == 0 {
+		return nil
+	}
+	return quota.Check(s.quotas, existing, quota.Request{
+		Template:   o.Template,
+		User:       o.Requester,
+		Parameters: params,
+		Members:    o.Members,
+	}, time.Now())
+}
+
+// writeQuotaError answers an exceeded quota with 429 and the current usage;
+// invalid quota parameters are a bad request
+func writeQuotaError(w http.ResponseWriter, err error) {
+	var exceeded *quota.ExceededError
+	if !errors.As(err, &exceeded) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if exceeded.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(exceeded.RetryAfter)))
+	}
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": exceeded.Error(),
+		"limit": exceeded.Limit,
+		"usage": exceeded.Usage,
+	})
+}
+
+// clientKey identifies the client for rate limiting by its IP address.
+// Headers such as X-API-Key or X-Requester are not verified by the server,
+// so clients could rotate them to get fresh buckets.
+func (s *Server) clientKey(r *http.Request) string {
+	return "ip:" + s.identity.clientIP(r)
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
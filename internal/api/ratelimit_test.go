@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1")
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+	})
+	WithQuotas(&quota.Config{RateLimit: &quota.RateLimit{RequestsPerSecond: 0.01, Burst: 2}})(server)
+
+	order := func(remoteAddr string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", strings.NewReader(`{"parameters":{}}`))
+		req.RemoteAddr = remoteAddr
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := order("192.0.2.1:1234", "X-Requester", "alice")
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	rec := order("192.0.2.1:1234", "X-Requester", "alice")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Unverified identity headers do not get a fresh bucket
+	rec = order("192.0.2.1:1234", "X-Requester", "bob", "X-API-Key", "random")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	rec = order("192.0.2.1:1234", "X-Forwarded-For", "198.51.100.7")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	// Other clients, also behind a trusted proxy, and reads are not throttled
+	rec = order("192.0.2.2:1234", "X-Requester", "alice")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = order("10.0.0.1:1234", "X-Forwarded-For", "192.0.2.1, 198.51.100.7")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = doRequest(server, http.MethodGet, "/api/v1/claim-templates", "alice", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestOrderQuota(t *testing.T) {
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+		Spec: claimtemplate.ClaimTemplateSpec{Parameters: []claimtemplate.Parameter{
+			{Name: "storage", Type: "string", Default: "10Gi"},
+		}},
+	})
+	cfg := &quota.Config{Quotas: []quota.Quota{
+		{Name: "daily", Template: "volumeclaim", Period: 24 * time.Hour, MaxOrders: 3},
+		{Name: "storage", Template: "volumeclaim", Period: 24 * time.Hour, Parameter: "storage", MaxTotal: "50Gi"},
+	}}
+	require.NoError(t, cfg.Validate())
+	WithQuotas(cfg)(server)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{"storage":"40Gi"}}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{"storage":"20Gi"}}`)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	var body struct {
+		Error string      `json:"error"`
+		Usage quota.Usage `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "storage", body.Usage.Quota)
+	assert.Equal(t, "60Gi", body.Usage.Total)
+	assert.Equal(t, "alice", body.Usage.User)
+
+	for i := 0; i < 2; i++ {
+		rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{"storage":"1Gi"}}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{"storage":"1Gi"}}`)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), "quota daily exceeded")
+
+	// Quotas are per user by default
+	rec = doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "bob", `{"parameters":{}}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestOrderQuota_Retention(t *testing.T) {
+	server := newOrderTestServer(t, &claimtemplate.ClaimTemplate{Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"}})
+	cfg := &quota.Config{Quotas: []quota.Quota{
+		{Name: "daily", Template: "volumeclaim", Period: 24 * time.Hour, MaxOrders: 2},
+	}}
+	require.NoError(t, cfg.Validate())
+	WithQuotas(cfg)(server)
+	server.orders.SetRetention(order.Retention{MaxOrders: 1})
+
+	// Orders counted by quotas outlive the retention limit
+	for i := 0; i < 2; i++ {
+		rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{}}`)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+	assert.Len(t, server.orders.List(""), 2)
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/order", "alice", `{"parameters":{}}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
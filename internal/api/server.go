@@ -7,38 +7,131 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stuttgart-things/claim-machinery-api/internal/app"
 	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/mutate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/policy"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+	"github.com/stuttgart-things/claim-machinery-api/internal/schema"
 	"github.com/stuttgart-things/claim-machinery-api/internal/version"
+	"github.com/stuttgart-things/claim-machinery-api/internal/webhook"
 )
 
 // Server represents the HTTP API server
 type Server struct {
 	router    *mux.Router
 	http      *http.Server
-	templates map[string]*claimtemplate.ClaimTemplate
+	templates *claimtemplate.Registry
+
+	// enforceSunset refuses orders for deprecated templates past their sunset date
+	enforceSunset bool
+
+	// cacheControl is sent with template responses
+	cacheControl string
+
+	// events fans out catalog and order changes to watchers
+	events *eventBroker
+
+	// orders records placed orders and their audit trail
+	orders *order.Store
+
+	// naming is the order naming strategy (ulid, uuid or timestamp)
+	naming string
+
+	// identity holds trusted proxies and the approvers of orders
+	identity identityConfig
+
+	// limiter throttles write requests per client (optional)
+	limiter *quota.Limiter
+
+	// quotas limit orders per template and user; quotaMu serializes
+	// quota checks with order creation
+	quotas  []quota.Quota
+	quotaMu sync.Mutex
+
+	// idempotency replays responses of retried orders
+	idempotency *idempotencyCache
+
+	// webhooks delivers order events to external subscribers (optional)
+	webhooks *webhook.Dispatcher
+
+	// batchConcurrency is the number of batch items rendered in parallel
+	batchConcurrency int
+
+	// mutator adds labels, annotations and namespaces to rendered output (optional)
+	mutator *mutate.Mutator
+
+	// schemas validates rendered output against CRD schemas (optional)
+	schemas *schema.Validator
+
+	// policies check rendered output and the order context (optional)
+	policies *policy.Engine
+
+	// render turns a template and resolved parameters into manifests
+	render func(*claimtemplate.ClaimTemplate, ...map[string]interface{}) (string, error)
+}
+
+// Option configures optional server features
+type Option func(*Server)
+
+// WithOrderStore persists orders in the given store instead of memory
+func WithOrderStore(store *order.Store) Option {
+	return func(s *Server) {
+		s.orders = store
+	}
+}
+
+// WithOrderNaming sets the order naming strategy (ulid, uuid or timestamp)
+func WithOrderNaming(strategy string) Option {
+	return func(s *Server) {
+		s.naming = strategy
+	}
+}
+
+// WithWebhooks sends order lifecycle events to the dispatcher's subscriptions
+func WithWebhooks(d *webhook.Dispatcher) Option {
+	return func(s *Server) {
+		s.webhooks = d
+	}
 }
 
 // NewServer creates and initializes a new HTTP server
-func NewServer(templatesDir string) (*Server, error) {
+func NewServer(templatesDir string, opts ...Option) (*Server, error) {
 	// Load templates on server startup
-	templates, err := app.LoadAllTemplates(templatesDir)
+	templates, err := app.LoadAllTemplates(templatesDir, app.DirOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to load templates: %w", err)
 	}
 
-	// Build template map for quick lookup
-	templateMap := make(map[string]*claimtemplate.ClaimTemplate)
-	for i, t := range templates {
-		templateMap[t.Metadata.Name] = templates[i]
-	}
-
 	s := &Server{
-		router:    mux.NewRouter(),
-		templates: templateMap,
+		router:        mux.NewRouter(),
+		templates:     claimtemplate.NewRegistry(templates),
+		enforceSunset: isEnvEnabled("ENFORCE_SUNSET"),
+		cacheControl:  envOrDefault("TEMPLATE_CACHE_CONTROL", defaultCacheControl),
+		events:        newEventBroker(),
+		orders:        newMemoryOrderStore(),
+		naming:        envOrDefault("ORDER_NAME_STRATEGY", app.NamingULID),
+		identity:      identityFromEnv(),
+		idempotency:   newIdempotencyCache(envDuration("IDEMPOTENCY_TTL", defaultIdempotencyTTL), envInt("IDEMPOTENCY_MAX_KEYS", defaultIdempotencyKeys)),
+		render:        app.RenderTemplate,
+
+		batchConcurrency: envInt("BATCH_CONCURRENCY", defaultBatchConcurrency),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// Retention never drops orders that quotas still count
+	s.orders.SetKeep(func(o *order.Order, now time.Time) bool {
+		return quota.Counts(s.quotas, o, now)
+	})
+	if !app.ValidNamingStrategy(s.naming) {
+		return nil, fmt.Errorf("invalid order naming strategy %q: use ulid, uuid or timestamp", s.naming)
 	}
 
 	// Register routes
@@ -61,16 +154,30 @@ func NewServer(templatesDir string) (*Server, error) {
 
 // NewServerWithTemplates creates a server from an explicit list of templates.
 // This is useful when combining multiple sources (e.g., directory + profile file).
-func NewServerWithTemplates(templates []*claimtemplate.ClaimTemplate) (*Server, error) {
-	// Build template map for quick lookup
-	templateMap := make(map[string]*claimtemplate.ClaimTemplate)
-	for i, t := range templates {
-		templateMap[t.Metadata.Name] = templates[i]
-	}
-
+func NewServerWithTemplates(templates []*claimtemplate.ClaimTemplate, opts ...Option) (*Server, error) {
 	s := &Server{
-		router:    mux.NewRouter(),
-		templates: templateMap,
+		router:        mux.NewRouter(),
+		templates:     claimtemplate.NewRegistry(templates),
+		enforceSunset: isEnvEnabled("ENFORCE_SUNSET"),
+		cacheControl:  envOrDefault("TEMPLATE_CACHE_CONTROL", defaultCacheControl),
+		events:        newEventBroker(),
+		orders:        newMemoryOrderStore(),
+		naming:        envOrDefault("ORDER_NAME_STRATEGY", app.NamingULID),
+		identity:      identityFromEnv(),
+		idempotency:   newIdempotencyCache(envDuration("IDEMPOTENCY_TTL", defaultIdempotencyTTL), envInt("IDEMPOTENCY_MAX_KEYS", defaultIdempotencyKeys)),
+		render:        app.RenderTemplate,
+
+		batchConcurrency: envInt("BATCH_CONCURRENCY", defaultBatchConcurrency),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// Retention never drops orders that quotas still count
+	s.orders.SetKeep(func(o *order.Order, now time.Time) bool {
+		return quota.Counts(s.quotas, o, now)
+	})
+	if !app.ValidNamingStrategy(s.naming) {
+		return nil, fmt.Errorf("invalid order naming strategy %q: use ulid, uuid or timestamp", s.naming)
 	}
 
 	// Register routes
@@ -95,6 +202,64 @@ func NewServerWithTemplates(templates []*claimtemplate.ClaimTemplate) (*Server,
 	return s, nil
 }
 
+// defaultOrderRetention bounds the orders kept by the in-memory store
+const defaultOrderRetention = 10000
+
+// newMemoryOrderStore creates the default in-memory order store. It keeps
+// ORDER_RETENTION_MAX orders, optionally only for ORDER_RETENTION_TTL.
+func newMemoryOrderStore() *order.Store {
+	store, _ := order.NewStore("")
+	store.SetRetention(order.Retention{
+		MaxOrders: envInt("ORDER_RETENTION_MAX", defaultOrderRetention),
+		MaxAge:    envDuration("ORDER_RETENTION_TTL", 0),
+	})
+	return store
+}
+
+// envOrDefault returns the value of an environment variable or a default
+func envOrDefault(name, def string) string {
+	if val := os.Getenv(name); val != "" {
+		return val
+	}
+	return def
+}
+
+// envDuration returns a duration environment variable (e.g. 12h) or a default
+func envDuration(name string, def time.Duration) time.Duration {
+	val := os.Getenv(name)
+	if val == "" {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		log.Printf("⚠️  invalid %s %q, using %s", name, val, def)
+		return def
+	}
+	return d
+}
+
+// envInt returns a positive integer environment variable or a default
+func envInt(name string, def int) int {
+	val := os.Getenv(name)
+	if val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		log.Printf("⚠️  invalid %s %q, using %d", name, val, def)
+		return def
+	}
+	return n
+}
+
+// ReloadTemplates replaces the served templates, notifies watchers and
+// returns what changed
+func (s *Server) ReloadTemplates(templates []*claimtemplate.ClaimTemplate) []claimtemplate.Change {
+	changes := s.templates.Replace(templates)
+	s.publishTemplateChanges(changes)
+	return changes
+}
+
 // registerRoutes sets up all API routes
 func (s *Server) registerRoutes() {
 	// Health check endpoint
@@ -109,8 +274,21 @@ func (s *Server) registerRoutes() {
 
 	// API endpoints
 	s.router.HandleFunc("/api/v1/claim-templates", s.listTemplates).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/v1/claim-templates/watch", s.watchTemplates).Methods(http.MethodGet)
 	s.router.HandleFunc("/api/v1/claim-templates/{name}", s.getTemplate).Methods(http.MethodGet)
 	s.router.HandleFunc("/api/v1/claim-templates/{name}/order", s.orderClaim).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/v1/claim-templates/{name}/versions", s.listTemplateVersions).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/v1/claim-templates/{name}/versions/{version}", s.getTemplate).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/v1/claim-templates/{name}/versions/{version}/order", s.orderClaim).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/v1/orders", s.listOrders).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/v1/orders:batch", s.orderBatch).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/v1/orders/{id}", s.getOrder).Methods(http.MethodGet)
+	s.router.HandleFunc("/api/v1/orders/{id}", s.updateClaimOrder).Methods(http.MethodPut)
+	s.router.HandleFunc("/api/v1/orders/{id}", s.decommissionOrder).Methods(http.MethodDelete)
+	s.router.HandleFunc("/api/v1/orders/{id}/approve", s.approveOrder).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/v1/orders/{id}/reject", s.rejectOrder).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/v1/orders/{id}/diff", s.diffOrder).Methods(http.MethodPost)
+	s.router.HandleFunc("/api/v1/approvals", s.listApprovals).Methods(http.MethodGet)
 
 	// Optional test-only routes (enable with ENABLE_TEST_ROUTES=1)
 	if os.Getenv("ENABLE_TEST_ROUTES") == "1" || os.Getenv("ENABLE_TEST_ROUTES") == "true" {
@@ -121,11 +299,12 @@ func (s *Server) registerRoutes() {
 // applyMiddleware applies middleware to all routes
 func (s *Server) applyMiddleware() {
 	// Middleware werden in Registrierungsreihenfolge ausgeführt.
-	// Reihenfolge: errorHandler -> cors -> requestID -> logging
+	// Reihenfolge: errorHandler -> cors -> requestID -> logging -> rateLimit
 	s.router.Use(errorHandlerMiddleware)
 	s.router.Use(corsMiddleware)
 	s.router.Use(requestIDMiddleware)
 	s.router.Use(loggingMiddleware)
+	s.router.Use(s.rateLimitMiddleware)
 }
 
 // Start starts the HTTP server
@@ -140,7 +319,11 @@ func (s *Server) Start() error {
 // Stop gracefully stops the HTTP server
 func (s *Server) Stop(ctx context.Context) error {
 	log.Println("⏹️  Shutting down HTTP server...")
-	return s.http.Shutdown(ctx)
+	err := s.http.Shutdown(ctx)
+	if werr := s.webhooks.Close(ctx); werr != nil && err == nil {
+		err = fmt.Errorf("webhook delivery: %w", werr)
+	}
+	return err
 }
 
 // healthCheck returns server health status
@@ -161,8 +344,19 @@ func (s *Server) rootInfo(w http.ResponseWriter, r *http.Request) {
 				"/health",
 				"/version",
 				"/api/v1/claim-templates",
+				"/api/v1/claim-templates/watch",
 				"/api/v1/claim-templates/{name}",
 				"/api/v1/claim-templates/{name}/order",
+				"/api/v1/claim-templates/{name}/versions",
+				"/api/v1/claim-templates/{name}/versions/{version}",
+				"/api/v1/claim-templates/{name}/versions/{version}/order",
+				"/api/v1/orders",
+				"/api/v1/orders:batch",
+				"/api/v1/orders/{id}",
+				"/api/v1/orders/{id}/approve",
+				"/api/v1/orders/{id}/reject",
+				"/api/v1/orders/{id}/diff",
+				"/api/v1/approvals",
 				"/openapi.yaml",
 				"/docs"
 			]
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/stuttgart-things/claim-machinery-api/internal/app"
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+// OrderUpdateRequest changes the parameters or template version of an order
+type OrderUpdateRequest struct {
+	// Parameters override the order's values; omitted ones are kept
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// Version moves the order to another template version (default: the order's)
+	Version string `json:"version,omitempty"`
+
+	// Comment is recorded in the order's history
+	Comment string `json:"comment,omitempty"`
+}
+
+// updateClaimOrder re-renders an order with changed parameters and delivers
+// it again. The order keeps its UID and name, so the rendered files replace
+// the previous ones; the superseded revision is kept in the order.
+func (s *Server) updateClaimOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Refuse unsupported output formats before anything is changed
+	format, err := negotiateOutput(r)
+	if err != nil {
+		writeError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	var req OrderUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// Updates are audited, and only the requester may change an order
+	actor := requesterFromRequest(r)
+	if actor == "" {
+		writeError(w, http.StatusBadRequest, "requester identity required (X-Requester header)")
+		return
+	}
+
+	current, err := s.orders.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if current.Requester != actor {
+		writeError(w, http.StatusForbidden, "only the requester of the order can update it")
+		return
+	}
+
+	version := req.Version
+	if version == "" {
+		version = current.TemplateVersion
+	}
+	tmpl, ok := s.templates.GetVersion(current.Template, version)
+	if !ok {
+		code := http.StatusNotFound
+		if req.Version == "" {
+			code = http.StatusConflict
+		}
+		writeError(w, code, "template "+current.Template+"@"+version+" is not available")
+		return
+	}
+
+	// Signal deprecation and refuse retired templates if configured
+	if tmpl.IsDeprecated() {
+		setDeprecationHeaders(w, tmpl)
+		if msg := s.retiredMessage(tmpl); msg != "" {
+			writeError(w, http.StatusGone, msg)
+			return
+		}
+	}
+
+	// Secret values are dropped once an order is rendered, so they have to
+	// be provided again
+	for name := range app.SecretValues(tmpl, current.Parameters) {
+		if _, provided := req.Parameters[name]; !provided {
+			writeError(w, http.StatusConflict, "secret parameter "+name+" is no longer available, provide it with the update")
+			return
+		}
+	}
+
+	provided := current.ParameterValues()
+	for k, v := range req.Parameters {
+		provided[k] = v
+	}
+	params, err := s.resolveParameters(tmpl, provided, current.Requester)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, app.RedactString(tmpl, provided, err.Error()))
+		return
+	}
+	debugParams("After merge", tmpl, params)
+
+	o, ok := s.reviseOrder(w, current.UID, tmpl, params, actor, req.Comment)
+	if !ok {
+		return
+	}
+	s.publishOrderEvent(EventOrderUpdated, orderEventFor(o))
+
+	// Updates of templates that require approval are held as well
+	if o.Status == order.StatusPending {
+		s.publishOrderEvent(EventOrderPending, orderEventFor(o))
+
+		w.Header().Set("Location", "/api/v1/orders/"+o.UID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(newOrderResponse(o, ""))
+		return
+	}
+
+	o, rendered, err := s.renderOrder(r.Context(), tmpl, o, params)
+	if err != nil {
+		writeRenderError(w, err)
+		return
+	}
+	s.deliverOrder(w, http.StatusOK, o, rendered, format)
+}
+
+// reviseOrder starts a new revision of a rendered or failed order. The
+// order is checked against quotas in place of its previous revision. On
+// failure the error response has been written.
+func (s *Server) reviseOrder(w http.ResponseWriter, id string, tmpl *claimtemplate.ClaimTemplate, params map[string]interface{}, actor, comment string) (*order.Order, bool) {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+
+	var others []*order.Order
+	for _, o := range s.orders.List("") {
+		if o.UID != id {
+			others = append(others, o)
+		}
+	}
+
+	code := http.StatusInternalServerError
+	var quotaErr error
+	o, err := s.orders.Update(id, func(o *order.Order) error {
+		if o.Status != order.StatusRendered && o.Status != order.StatusFailed {
+			code = http.StatusConflict
+			return errors.New("order is " + o.Status + ", only rendered or failed orders can be updated")
+		}
+		members, err := s.orderMembers(tmpl, params, o.Requester)
+		if err != nil {
+			code = http.StatusBadRequest
+			return errors.New(app.RedactString(tmpl, params, err.Error()))
+		}
+		o.Members = members
+		if quotaErr = s.checkQuota(others, o, params); quotaErr != nil {
+			return quotaErr
+		}
+
+		// Secret values are only kept while the update waits for approval
+		status, secrets := order.StatusApproved, map[string]interface{}(nil)
+		if s.requiresApproval(tmpl) {
+			status, secrets = order.StatusPending, app.SecretValues(tmpl, params)
+		}
+		o.Revise(tmpl.VersionKey(), app.RedactParameters(tmpl, params), secrets)
+		o.Record(status, order.ActionUpdated, actor, comment)
+		return nil
+	})
+	switch {
+	case quotaErr != nil:
+		writeQuotaError(w, quotaErr)
+		return nil, false
+	case errors.Is(err, order.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	case err != nil:
+		writeError(w, code, err.Error())
+		return nil, false
+	}
+	return o, true
+}
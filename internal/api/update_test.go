@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+)
+
+func updateWithAccept(server *Server, id, body, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/orders/"+id, strings.NewReader(body))
+	req.Header.Set("Accept", accept)
+	req.Header.Set("X-Requester", "alice")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUpdateOrder(t *testing.T) {
+	server := newDiffTestServer(t)
+	uid := placeDiffOrder(t, server)
+	before, err := server.orders.Get(uid)
+	require.NoError(t, err)
+
+	rec := doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"parameters":{"storage":"20Gi"},"comment":"resize"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, before.Name, resp.Metadata["name"])
+	assert.Equal(t, float64(2), resp.Metadata["revision"])
+	assert.Contains(t, resp.Rendered, "name: data\nspec:\n  storage: 20Gi")
+
+	o, err := server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusRendered, o.Status)
+	assert.Equal(t, 2, o.Revision)
+	assert.Equal(t, "20Gi", o.Parameters["storage"])
+	require.Len(t, o.Revisions, 1)
+	assert.Equal(t, 1, o.Revisions[0].Revision)
+	assert.Equal(t, "10Gi", o.Revisions[0].Parameters["storage"])
+	assert.Contains(t, o.Revisions[0].Rendered, "storage: 10Gi")
+
+	updated := o.History[len(o.History)-2]
+	assert.Equal(t, order.ActionUpdated, updated.Action)
+	assert.Equal(t, "alice", updated.Actor)
+	assert.Equal(t, "resize", updated.Comment)
+	assert.Equal(t, order.ActionRendered, o.History[len(o.History)-1].Action)
+
+	// Updates are delivered in the negotiated format
+	req := `{"parameters":{"storage":"30Gi"}}`
+	rec = updateWithAccept(server, before.Name, req, "application/yaml")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, before.Name, rec.Header().Get("X-Order-Name"))
+	assert.Contains(t, rec.Body.String(), "storage: 30Gi")
+}
+
+func TestUpdateOrder_Version(t *testing.T) {
+	v1 := &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim", Version: "1.0.0"},
+		Spec: claimtemplate.ClaimTemplateSpec{Parameters: []claimtemplate.Parameter{
+			{Name: "storage", Type: "string", Default: "10Gi"},
+		}},
+	}
+	v2 := &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim", Version: "2.0.0"},
+		Spec: claimtemplate.ClaimTemplateSpec{Parameters: []claimtemplate.Parameter{
+			{Name: "storage", Type: "string", Default: "10Gi"},
+			{Name: "storageClass", Type: "string", Default: "fast"},
+		}},
+	}
+	server := newOrderTestServer(t, v1, v2)
+
+	rec := doRequest(server, http.MethodPost, "/api/v1/claim-templates/volumeclaim/versions/1.0.0/order", "alice", `{"parameters":{"storage":"5Gi"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	uid := resp.Metadata["uid"].(string)
+
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"version":"2.0.0"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	o, err := server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", o.TemplateVersion)
+	assert.Equal(t, map[string]interface{}{"storage": "5Gi", "storageClass": "fast"}, o.Parameters)
+	require.Len(t, o.Revisions, 1)
+	assert.Equal(t, "1.0.0", o.Revisions[0].TemplateVersion)
+
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"version":"3.0.0"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestUpdateOrder_Approval(t *testing.T) {
+	server := newOrderTestServer(t, approvalTemplate())
+	uid := placePendingOrder(t, server)
+
+	// Pending orders are not updated, they are decided first
+	rec := doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"parameters":{"cpu":4}}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/approve", "bob", `{}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// Secrets are dropped once the order is rendered
+	o, err := server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Empty(t, o.Secrets)
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"parameters":{"cpu":4}}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"parameters":{"cpu":4,"rootPassword":"hunter3"}}`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Equal(t, "/api/v1/orders/"+uid, rec.Header().Get("Location"))
+
+	o, err = server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Equal(t, order.StatusPending, o.Status)
+	assert.Empty(t, o.Rendered)
+
+	// The secret of the update is rendered once it is approved
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/approve", "bob", `{}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp OrderResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Rendered, `"cpu":4`)
+	assert.Contains(t, resp.Rendered, `"rootPassword":"hunter3"`)
+	assert.Equal(t, float64(2), resp.Metadata["revision"])
+}
+
+func TestUpdateOrder_Quota(t *testing.T) {
+	server := newDiffTestServer(t)
+	cfg := &quota.Config{Quotas: []quota.Quota{
+		{Name: "storage", Template: "volumeclaim", Period: 24 * time.Hour, Parameter: "storage", MaxTotal: "50Gi"},
+	}}
+	require.NoError(t, cfg.Validate())
+	WithQuotas(cfg)(server)
+	uid := placeDiffOrder(t, server)
+
+	// The order replaces its previous revision instead of adding to it
+	rec := doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"parameters":{"storage":"50Gi"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"parameters":{"storage":"60Gi"}}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	o, err := server.orders.Get(uid)
+	require.NoError(t, err)
+	assert.Equal(t, 2, o.Revision)
+	assert.Equal(t, "50Gi", o.Parameters["storage"])
+}
+
+func TestUpdateOrder_Errors(t *testing.T) {
+	server := newOrderTestServer(t, approvalTemplate())
+	uid := placePendingOrder(t, server)
+	rec := doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/approve", "bob", `{}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/unknown", "alice", `{}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Updates need the identity of the requester
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "bob", `{"parameters":{"cpu":4,"rootPassword":"s3cret"}}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = updateWithAccept(server, uid, `{}`, "text/html")
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+
+	// Secrets are not kept after rendering and must be provided again
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"parameters":{"cpu":4}}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "rootPassword")
+
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{"parameters":{"cpu":4,"rootPassword":"s3cret"}}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	// Rejected updates stay rejected
+	rec = doRequest(server, http.MethodPost, "/api/v1/orders/"+uid+"/reject", "bob", `{}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = doRequest(server, http.MethodPut, "/api/v1/orders/"+uid, "alice", `{}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// TemplateLookup finds a claim template by name and version; an empty
+// version selects the default version
+type TemplateLookup func(name, version string) (*claimtemplate.ClaimTemplate, bool)
+
+// BundlePart is a member of a bundle with its resolved parameters
+type BundlePart struct {
+	Member     string
+	Template   *claimtemplate.ClaimTemplate
+	Parameters map[string]interface{}
+}
+
+// ResolveBundle maps the bundle parameters onto the parameters of each
+// member. Member parameter values that are strings are Go templates over
+// the bundle parameters, .template and .members.<name>.<parameter>, which
+// holds the resolved parameters (including generated values) of the
+// members before. Members are resolved in declaration order.
+func ResolveBundle(b *claimtemplate.ClaimTemplate, params map[string]interface{}, lookup TemplateLookup, gctx GenerateContext) ([]BundlePart, error) {
+	if err := b.ValidateBundle(); err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]interface{}, len(b.Spec.Members))
+	data := make(map[string]interface{}, len(params)+2)
+	for k, v := range params {
+		data[k] = v
+	}
+	data["template"] = b.Metadata.Name
+	data["members"] = members
+
+	parts := make([]BundlePart, 0, len(b.Spec.Members))
+	for _, m := range b.Spec.Members {
+		t, ok := lookup(m.Template, m.Version)
+		if !ok {
+			ref := m.Template
+			if m.Version != "" {
+				ref += "@" + m.Version
+			}
+			return nil, fmt.Errorf("bundle member %s: template %s not found", m.Name, ref)
+		}
+		if t.IsBundle() {
+			return nil, fmt.Errorf("bundle member %s: bundles cannot be nested", m.Name)
+		}
+
+		provided := make(map[string]interface{}, len(m.Parameters))
+		for name, value := range m.Parameters {
+			text, ok := value.(string)
+			if !ok || !strings.Contains(text, "{{") {
+				provided[name] = value
+				continue
+			}
+			out, err := evaluateTemplate(m.Name+"."+name, text, data)
+			if err != nil {
+				return nil, fmt.Errorf("bundle member %s: parameter %s: %w", m.Name, name, err)
+			}
+			converted, err := convertGenerated(parameterType(t, name), out)
+			if err != nil {
+				return nil, fmt.Errorf("bundle member %s: parameter %s: %w", m.Name, name, err)
+			}
+			provided[name] = converted
+		}
+
+		resolved, err := ResolveParameters(t, provided, gctx)
+		if err != nil {
+			return nil, fmt.Errorf("bundle member %s: %w", m.Name, err)
+		}
+		members[m.Name] = resolved
+		parts = append(parts, BundlePart{Member: m.Name, Template: t, Parameters: resolved})
+	}
+	return parts, nil
+}
+
+// RenderBundle renders the members of a bundle in order and joins their
+// manifests into one multi-document YAML stream
+func RenderBundle(parts []BundlePart, render func(*claimtemplate.ClaimTemplate, ...map[string]interface{}) (string, error)) (string, error) {
+	docs := make([]string, 0, len(parts))
+	for _, p := range parts {
+		out, err := render(p.Template, p.Parameters)
+		if err != nil {
+			return "", fmt.Errorf("bundle member %s: %w", p.Member, err)
+		}
+		docs = append(docs, out)
+	}
+	return JoinManifests(docs...), nil
+}
+
+// RedactBundle masks the secret values of all bundle members in text
+func RedactBundle(parts []BundlePart, text string) string {
+	for _, p := range parts {
+		text = RedactString(p.Template, p.Parameters, text)
+	}
+	return text
+}
+
+// JoinManifests combines YAML streams into one multi-document stream.
+// Empty streams are skipped.
+func JoinManifests(docs ...string) string {
+	var parts []string
+	for _, d := range docs {
+		d = strings.Trim(strings.TrimPrefix(strings.TrimSpace(d), "---"), "\n")
+		if d != "" {
+			parts = append(parts, d)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "\n---\n") + "\n"
+}
+
+// parameterType returns the declared type of a template parameter
+func parameterType(t *claimtemplate.ClaimTemplate, name string) string {
+	for _, p := range t.Spec.Parameters {
+		if p.Name == name {
+			return p.Type
+		}
+	}
+	return "string"
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func bundleTestLookup() TemplateLookup {
+	templates := map[string]*claimtemplate.ClaimTemplate{
+		"namespace": {
+			Metadata: claimtemplate.ClaimTemplateMetadata{Name: "namespace"},
+			Spec: claimtemplate.ClaimTemplateSpec{
+				Parameters: []claimtemplate.Parameter{
+					{Name: "name", Type: "string", Generate: "{{ .template }}-{{ randAlpha 5 }}"},
+				},
+			},
+		},
+		"postgresql": {
+			Metadata: claimtemplate.ClaimTemplateMetadata{Name: "postgresql"},
+			Spec: claimtemplate.ClaimTemplateSpec{
+				Parameters: []claimtemplate.Parameter{
+					{Name: "namespace", Type: "string"},
+					{Name: "storageGB", Type: "number", Default: 10},
+					{Name: "password", Type: "password"},
+				},
+			},
+		},
+	}
+	return func(name, version string) (*claimtemplate.ClaimTemplate, bool) {
+		t, ok := templates[name]
+		return t, ok
+	}
+}
+
+func testBundle(members ...claimtemplate.BundleMember) *claimtemplate.ClaimTemplate {
+	return &claimtemplate.ClaimTemplate{
+		Kind:     claimtemplate.KindClaimBundle,
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "team-env"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{{Name: "size", Type: "number"}},
+			Members:    members,
+		},
+	}
+}
+
+func TestResolveBundle(t *testing.T) {
+	bundle := testBundle(
+		claimtemplate.BundleMember{Name: "ns", Template: "namespace"},
+		claimtemplate.BundleMember{Name: "db", Template: "postgresql", Parameters: map[string]interface{}{
+			"namespace": "{{ .members.ns.name }}",
+			"storageGB": "{{ .size }}",
+			"password":  "fixed",
+		}},
+	)
+
+	parts, err := ResolveBundle(bundle, map[string]interface{}{"size": 50}, bundleTestLookup(), GenerateContext{})
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+
+	// The generated namespace name feeds the database member
+	assert.Regexp(t, regexp.MustCompile(`^namespace-[a-z]{5}$`), parts[0].Parameters["name"])
+	assert.Equal(t, parts[0].Parameters["name"], parts[1].Parameters["namespace"])
+	assert.Equal(t, 50, parts[1].Parameters["storageGB"], "values are converted to the member parameter type")
+	assert.Equal(t, "fixed", parts[1].Parameters["password"])
+}
+
+func TestResolveBundle_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		bundle  *claimtemplate.ClaimTemplate
+		wantErr string
+	}{
+		{name: "no members", bundle: testBundle(), wantErr: "no members"},
+		{
+			name:    "unknown template",
+			bundle:  testBundle(claimtemplate.BundleMember{Name: "x", Template: "missing", Version: "1.0.0"}),
+			wantErr: "template missing@1.0.0 not found",
+		},
+		{
+			name: "later member",
+			bundle: testBundle(
+				claimtemplate.BundleMember{Name: "db", Template: "postgresql", Parameters: map[string]interface{}{"namespace": "{{ .members.ns.name }}"}},
+				claimtemplate.BundleMember{Name: "ns", Template: "namespace"},
+			),
+			wantErr: "bundle member db: parameter namespace",
+		},
+		{
+			name: "duplicate member",
+			bundle: testBundle(
+				claimtemplate.BundleMember{Name: "ns", Template: "namespace"},
+				claimtemplate.BundleMember{Name: "ns", Template: "namespace"},
+			),
+			wantErr: "duplicate member ns",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ResolveBundle(tt.bundle, map[string]interface{}{}, bundleTestLookup(), GenerateContext{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestJoinManifests(t *testing.T) {
+	out := JoinManifests("a: 1\n", "", "---\nb: 2\n---\nc: 3\n")
+	assert.Equal(t, "a: 1\n---\nb: 2\n---\nc: 3\n", out)
+	assert.Empty(t, JoinManifests())
+}
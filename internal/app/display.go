@@ -16,11 +16,15 @@ func PrintTemplateSummary(t *claimtemplate.ClaimTemplate) {
 	fmt.Printf("Parameters:  %d\n", len(t.Spec.Parameters))
 
 	for _, p := range t.Spec.Parameters {
+		def := p.Default
+		if p.IsSecret() && def != nil {
+			def = MaskedValue
+		}
 		fmt.Printf("  - %s (%s) required=%v default=%v\n",
 			p.Name,
 			p.Type,
 			p.Required,
-			p.Default,
+			def,
 		)
 	}
 }
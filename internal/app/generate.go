@@ -0,0 +1,173 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+const (
+	alphaChars    = "abcdefghijklmnopqrstuvwxyz"
+	numericChars  = "0123456789"
+	symbolChars   = "!#%+-.:=?@_~"
+	passwordChars = alphaChars + "ABCDEFGHIJKLMNOPQRSTUVWXYZ" + numericChars + symbolChars
+)
+
+// GenerateContext carries request-scoped values available to generated parameters
+type GenerateContext struct {
+	// Requester identifies who placed the order (empty if unknown)
+	Requester string
+}
+
+// ResolveParameters builds the final parameter values for an order.
+// Template defaults are overridden by provided values; parameters with a
+// generate expression that the caller left empty are generated server-side.
+// Generated parameters are evaluated in declaration order, so later
+// expressions can reference earlier results via .params.
+func ResolveParameters(t *claimtemplate.ClaimTemplate, provided map[string]interface{}, gctx GenerateContext) (map[string]interface{}, error) {
+	params := BuildParameterValues(t)
+	for key, value := range provided {
+		params[key] = value
+	}
+
+	for _, p := range t.Spec.Parameters {
+		if p.Generate == "" || !isEmptyValue(provided[p.Name]) {
+			continue
+		}
+
+		value, err := generateValue(t, p, params, gctx)
+		if err != nil {
+			return nil, fmt.Errorf("generate parameter %s: %w", p.Name, err)
+		}
+		params[p.Name] = value
+	}
+
+	return params, nil
+}
+
+// generateValue evaluates the generate expression of a single parameter.
+// Missing keys do not fail: optional parameters without a value are empty
+// strings, so expressions can test them with {{ if .params.x }}.
+func generateValue(t *claimtemplate.ClaimTemplate, p claimtemplate.Parameter, params map[string]interface{}, gctx GenerateContext) (interface{}, error) {
+	values := make(map[string]interface{}, len(t.Spec.Parameters))
+	for _, declared := range t.Spec.Parameters {
+		values[declared.Name] = ""
+	}
+	for k, v := range params {
+		values[k] = v
+	}
+
+	out, err := executeTemplate(p.Name, p.Generate, "missingkey=zero", map[string]interface{}{
+		"template":  t.Metadata.Name,
+		"requester": gctx.Requester,
+		"params":    values,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return convertGenerated(p.Type, out)
+}
+
+// evaluateTemplate executes a Go template with the generate functions.
+// Missing keys are an error, since names of orders and Secrets must not
+// silently contain empty parts.
+func evaluateTemplate(name, text string, data map[string]interface{}) (string, error) {
+	return executeTemplate(name, text, "missingkey=error", data)
+}
+
+// executeTemplate executes a Go template with the generate functions and
+// the given missingkey option
+func executeTemplate(name, text, missingKey string, data map[string]interface{}) (string, error) {
+	tpl, err := template.New(name).Funcs(generateFuncs()).Option(missingKey).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// convertGenerated converts the rendered template output to the parameter type
+func convertGenerated(paramType string, value string) (interface{}, error) {
+	switch paramType {
+	case "boolean":
+		return strconv.ParseBool(value)
+	case "number", "integer":
+		if i, err := strconv.Atoi(value); err == nil {
+			return i, nil
+		}
+		return strconv.ParseFloat(value, 64)
+	default:
+		return value, nil
+	}
+}
+
+// generateFuncs returns the functions available in generate expressions
+func generateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"randAlpha":    func(n int) (string, error) { return randomString(alphaChars, n) },
+		"randAlphaNum": func(n int) (string, error) { return randomString(alphaChars+numericChars, n) },
+		"randNumeric":  func(n int) (string, error) { return randomString(numericChars, n) },
+		"password":     func(n int) (string, error) { return randomString(passwordChars, n) },
+		"uuid":         NewUUID,
+		"lower":        strings.ToLower,
+		"upper":        strings.ToUpper,
+		"trunc": func(n int, s string) string {
+			if len(s) > n {
+				return s[:n]
+			}
+			return s
+		},
+		"now": func(layout string) string { return time.Now().UTC().Format(layout) },
+	}
+}
+
+// randomString returns n characters drawn from charset using crypto/rand
+func randomString(charset string, n int) (string, error) {
+	if n <= 0 {
+		return "", fmt.Errorf("length must be positive, got %d", n)
+	}
+
+	limit := big.NewInt(int64(len(charset)))
+	out := make([]byte, n)
+	for i := range out {
+		idx, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		out[i] = charset[idx.Int64()]
+	}
+	return string(out), nil
+}
+
+// NewUUID returns a random (version 4) UUID
+func NewUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// isEmptyValue reports whether a provided parameter value counts as unset
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && s == ""
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func generateTestTemplate() *claimtemplate.ClaimTemplate {
+	return &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{
+				{Name: "namespace", Type: "string", Default: "default"},
+				{Name: "name", Type: "string", Default: "static", Generate: "{{ .template }}-{{ randAlpha 5 }}"},
+				{Name: "owner", Type: "string", Generate: "{{ .requester }}"},
+				{Name: "adminPassword", Type: "password", Generate: "{{ password 24 }}"},
+				{Name: "volume", Type: "string", Generate: "{{ .params.name }}-data"},
+				{Name: "replicas", Type: "number", Generate: "3"},
+			},
+		},
+	}
+}
+
+func TestResolveParameters(t *testing.T) {
+	tmpl := generateTestTemplate()
+
+	params, err := ResolveParameters(tmpl, map[string]interface{}{"namespace": "prod"}, GenerateContext{Requester: "jane"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "prod", params["namespace"])
+	assert.Regexp(t, regexp.MustCompile(`^volumeclaim-[a-z]{5}$`), params["name"])
+	assert.Equal(t, "jane", params["owner"])
+	assert.Len(t, params["adminPassword"], 24)
+	assert.Equal(t, params["name"].(string)+"-data", params["volume"])
+	assert.Equal(t, 3, params["replicas"])
+}
+
+func TestResolveParameters_ProvidedValueWins(t *testing.T) {
+	tmpl := generateTestTemplate()
+
+	params, err := ResolveParameters(tmpl, map[string]interface{}{"name": "my-claim"}, GenerateContext{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "my-claim", params["name"])
+	assert.Equal(t, "my-claim-data", params["volume"])
+}
+
+func TestResolveParameters_InvalidExpression(t *testing.T) {
+	tmpl := &claimtemplate.ClaimTemplate{
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{
+				{Name: "name", Type: "string", Generate: "{{ randAlpha 0 }}"},
+			},
+		},
+	}
+
+	_, err := ResolveParameters(tmpl, nil, GenerateContext{})
+	assert.Error(t, err)
+}
+
+func TestNewUUID(t *testing.T) {
+	id, err := NewUUID()
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+}
+
+func TestRedactParameters(t *testing.T) {
+	tmpl := generateTestTemplate()
+	params := map[string]interface{}{"namespace": "prod", "adminPassword": "s3cret"}
+
+	redacted := RedactParameters(tmpl, params)
+
+	assert.Equal(t, "prod", redacted["namespace"])
+	assert.Equal(t, MaskedValue, redacted["adminPassword"])
+	assert.Equal(t, "s3cret", params["adminPassword"], "input must not be modified")
+}
+
+func TestResolveParameters_OptionalParameters(t *testing.T) {
+	tmpl := &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{
+				{Name: "suffix", Type: "string"},
+				{Name: "name", Type: "string", Generate: "{{ .template }}{{ if .params.suffix }}-{{ .params.suffix }}{{ end }}"},
+				{Name: "label", Type: "string", Generate: "[{{ .params.suffix }}]"},
+			},
+		},
+	}
+
+	// Optional parameters without a value do not fail generate expressions
+	params, err := ResolveParameters(tmpl, nil, GenerateContext{})
+	require.NoError(t, err)
+	assert.Equal(t, "volumeclaim", params["name"])
+	assert.Equal(t, "[]", params["label"])
+
+	params, err = ResolveParameters(tmpl, map[string]interface{}{"suffix": "data"}, GenerateContext{})
+	require.NoError(t, err)
+	assert.Equal(t, "volumeclaim-data", params["name"])
+}
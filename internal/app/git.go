@@ -0,0 +1,278 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// gitTimeout bounds fetching a Git source
+const gitTimeout = 2 * time.Minute
+
+// failedSyncFile records, in the .git directory of a checkout, the remote
+// commit the last sync failed to fetch, so that it is not reported as a
+// change again
+const failedSyncFile = "claim-machinery-failed-sync"
+
+// commitSHA matches full commit SHAs, which never move
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// GitSource is a profile entry loading templates from a Git repository
+type GitSource struct {
+	URL string `yaml:"url"`
+
+	// Ref is a branch, tag or commit SHA (default: the remote HEAD)
+	Ref string `yaml:"ref,omitempty"`
+
+	// Paths are globs relative to the repository root; ** matches any
+	// number of directories (default: all YAML files)
+	Paths []string `yaml:"paths,omitempty"`
+}
+
+func (s GitSource) ref() string {
+	if s.Ref == "" {
+		return "HEAD"
+	}
+	return s.Ref
+}
+
+func (s GitSource) paths() []string {
+	if len(s.Paths) == 0 {
+		return allYAMLFiles
+	}
+	return s.Paths
+}
+
+// GitCacheDir returns the directory Git sources are checked out to:
+// $GIT_CACHE_DIR or claim-machinery-api/git in the user cache directory
+func GitCacheDir() string {
+	if dir := os.Getenv("GIT_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "claim-machinery-api", "git")
+}
+
+// checkoutDir is the cache directory of a source; each URL and ref gets
+// its own checkout
+func (s GitSource) checkoutDir(cacheDir string) string {
+	sum := sha256.Sum256([]byte(s.URL + "#" + s.ref()))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:8]))
+}
+
+// LoadGitTemplates fetches the ref of a Git source into the cache and loads
+// the claim templates of all files matching its paths. If the fetch fails,
+// the last checkout in the cache is used and the remote commit is
+// remembered until a fetch succeeds. Each source is the URL with the
+// commit SHA and file, e.g. https://github.com/org/repo.git@<sha>#templates/volumeclaim.yaml.
+func LoadGitTemplates(ctx context.Context, cacheDir string, src GitSource) ([]*claimtemplate.ClaimTemplate, []string, error) {
+	if src.URL == "" {
+		return nil, nil, errors.New("git source without url")
+	}
+	dir := src.checkoutDir(cacheDir)
+	sha, err := syncGit(ctx, dir, src)
+	if err != nil {
+		cached, cacheErr := git(ctx, dir, "rev-parse", "--verify", "-q", "HEAD")
+		if cacheErr != nil || cached == "" {
+			return nil, nil, fmt.Errorf("sync %s@%s: %w", src.URL, src.ref(), err)
+		}
+		log.Printf("⚠️  failed to sync %s@%s: %v (using cached checkout %s)", src.URL, src.ref(), err, cached)
+		sha = cached
+		recordFailedSync(ctx, dir, src)
+	} else {
+		os.Remove(filepath.Join(dir, ".git", failedSyncFile))
+	}
+
+	var names []string
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if isYAMLFile(rel) && matchAnyGlob(src.paths(), rel) {
+			names = append(names, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(names)
+
+	var (
+		out     []*claimtemplate.ClaimTemplate
+		sources []string
+	)
+	for _, name := range names {
+		tmpls, err := claimtemplate.LoadClaimTemplates(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			log.Printf("⚠️  failed to load template %s from %s: %v (skipping)", name, src.URL, err)
+			continue
+		}
+		for _, tmpl := range tmpls {
+			if !isClaimTemplate(tmpl) {
+				continue
+			}
+			tmpl.Metadata.Origin = &claimtemplate.Origin{
+				Type:     "git",
+				URL:      src.URL,
+				Ref:      src.ref(),
+				Revision: sha,
+				Path:     name,
+			}
+			out = append(out, tmpl)
+			sources = append(sources, src.URL+"@"+sha+"#"+name)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil, fmt.Errorf("no claim templates in %s@%s matching %s", src.URL, sha, strings.Join(src.paths(), ", "))
+	}
+	return out, sources, nil
+}
+
+// GitSourcesChanged reports whether the ref of any source points to another
+// commit than its checkout. Sources pinned to a commit SHA are skipped, as
+// are remote commits the last sync failed to fetch.
+func GitSourcesChanged(ctx context.Context, cacheDir string, sources []GitSource) (bool, error) {
+	for _, src := range sources {
+		if commitSHA.MatchString(src.Ref) {
+			continue
+		}
+		dir := src.checkoutDir(cacheDir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+			// Not checked out yet
+			return true, nil
+		}
+		local, err := git(ctx, dir, "rev-parse", "HEAD")
+		if err != nil {
+			return false, err
+		}
+		remote, err := remoteCommit(ctx, src)
+		if err != nil {
+			return false, fmt.Errorf("check %s@%s: %w", src.URL, src.ref(), err)
+		}
+		if remote != local && remote != failedSync(dir) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordFailedSync remembers the remote commit of a source whose fetch
+// failed
+func recordFailedSync(ctx context.Context, dir string, src GitSource) {
+	path := filepath.Join(dir, ".git", failedSyncFile)
+	remote, err := remoteCommit(ctx, src)
+	if err != nil {
+		os.Remove(path)
+		return
+	}
+	if err := os.WriteFile(path, []byte(remote+"\n"), 0o600); err != nil {
+		log.Printf("⚠️  failed to record sync failure of %s@%s: %v", src.URL, src.ref(), err)
+	}
+}
+
+// failedSync returns the remote commit the last sync of a checkout failed
+// to fetch, if any
+func failedSync(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, ".git", failedSyncFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// syncGit fetches a shallow copy of the ref into dir and checks it out. It
+// returns the commit SHA.
+func syncGit(ctx context.Context, dir string, src GitSource) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		if err := os.RemoveAll(dir); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return "", err
+		}
+		if _, err := git(ctx, dir, "init", "-q"); err != nil {
+			return "", err
+		}
+		if _, err := git(ctx, dir, "remote", "add", "--", "origin", src.URL); err != nil {
+			return "", err
+		}
+	}
+	if _, err := git(ctx, dir, "fetch", "-q", "--depth", "1", "--", "origin", src.ref()); err != nil {
+		return "", err
+	}
+	if _, err := git(ctx, dir, "checkout", "-q", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	return git(ctx, dir, "rev-parse", "HEAD")
+}
+
+// remoteCommit returns the commit a ref points to on the remote; tags are
+// peeled to their commit. ls-remote matches patterns by their last path
+// components, so only the ref itself, refs/tags/<ref> and refs/heads/<ref>
+// are accepted, in the order git fetch resolves them.
+func remoteCommit(ctx context.Context, src GitSource) (string, error) {
+	ref := src.ref()
+	out, err := git(ctx, "", "ls-remote", "--", src.URL, ref, ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+	shas := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			shas[fields[1]] = fields[0]
+		}
+	}
+	for _, name := range []string{ref, "refs/tags/" + ref, "refs/heads/" + ref} {
+		if sha, ok := shas[name+"^{}"]; ok {
+			return sha, nil
+		}
+		if sha, ok := shas[name]; ok {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("ref %s not found", ref)
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
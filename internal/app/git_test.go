@@ -0,0 +1,279 @@
+package app
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// gitRepo is a bare repository with a work tree to commit to
+type gitRepo struct {
+	t    *testing.T
+	url  string
+	work string
+}
+
+func newGitRepo(t *testing.T) *gitRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	r := &gitRepo{t: t, url: filepath.Join(dir, "templates.git"), work: filepath.Join(dir, "work")}
+	r.run(dir, "init", "-q", "--bare", "-b", "main", r.url)
+	r.run(dir, "init", "-q", "-b", "main", r.work)
+	r.run(r.work, "remote", "add", "origin", r.url)
+	return r
+}
+
+func (r *gitRepo) run(dir string, args ...string) string {
+	r.t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(r.t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files, commits and pushes them and returns the commit SHA
+func (r *gitRepo) commit(files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		p := filepath.Join(r.work, filepath.FromSlash(name))
+		require.NoError(r.t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(r.t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	r.run(r.work, "add", "-A")
+	r.run(r.work, "commit", "-q", "-m", "update templates")
+	r.run(r.work, "push", "-q", "origin", "main")
+	return r.run(r.work, "rev-parse", "HEAD")
+}
+
+func TestLoadGitTemplates(t *testing.T) {
+	repo := newGitRepo(t)
+	sha := repo.commit(map[string]string{
+		"templates/volumeclaim.yaml":        templateYAML("volumeclaim", "1.0.0"),
+		"templates/infra/harborproject.yml": templateYAML("harborproject", "1.0.0"),
+		"examples/vspherevm.yaml":           templateYAML("vspherevm", "1.0.0"),
+		"templates/kcl.mod.yaml":            "package:\n  name: claims\n",
+		"README.md":                         "# templates\n",
+	})
+	cache := t.TempDir()
+	ctx := context.Background()
+
+	// Defaults: remote HEAD, all YAML files
+	tmpls, sources, err := LoadGitTemplates(ctx, cache, GitSource{URL: repo.url})
+	require.NoError(t, err)
+	require.Len(t, tmpls, 3)
+	assert.Equal(t, "vspherevm", tmpls[0].Metadata.Name)
+	assert.Equal(t, []string{
+		repo.url + "@" + sha + "#examples/vspherevm.yaml",
+		repo.url + "@" + sha + "#templates/infra/harborproject.yml",
+		repo.url + "@" + sha + "#templates/volumeclaim.yaml",
+	}, sources)
+
+	// Path globs
+	src := GitSource{URL: repo.url, Ref: "main", Paths: []string{"templates/**"}}
+	tmpls, _, err = LoadGitTemplates(ctx, cache, src)
+	require.NoError(t, err)
+	require.Len(t, tmpls, 2)
+	assert.Equal(t, "harborproject", tmpls[0].Metadata.Name)
+	assert.Equal(t, &claimtemplate.Origin{
+		Type:     "git",
+		URL:      repo.url,
+		Ref:      "main",
+		Revision: sha,
+		Path:     "templates/volumeclaim.yaml",
+	}, tmpls[1].Metadata.Origin)
+
+	// Tags and pinned commits
+	repo.run(repo.work, "tag", "-a", "-m", "release", "v1.0.0")
+	repo.run(repo.work, "push", "-q", "origin", "v1.0.0")
+	next := repo.commit(map[string]string{"templates/volumeclaim.yaml": templateYAML("volumeclaim", "1.1.0")})
+
+	tmpls, _, err = LoadGitTemplates(ctx, cache, GitSource{URL: repo.url, Ref: "v1.0.0", Paths: []string{"templates/*.yaml"}})
+	require.NoError(t, err)
+	require.Len(t, tmpls, 1)
+	assert.Equal(t, "1.0.0", tmpls[0].VersionKey())
+	assert.Equal(t, sha, tmpls[0].Metadata.Origin.Revision)
+
+	tmpls, _, err = LoadGitTemplates(ctx, cache, GitSource{URL: repo.url, Ref: next, Paths: []string{"templates/*.yaml"}})
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", tmpls[0].VersionKey())
+
+	// Existing checkouts are updated
+	tmpls, _, err = LoadGitTemplates(ctx, cache, src)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", tmpls[1].VersionKey())
+	assert.Equal(t, next, tmpls[1].Metadata.Origin.Revision)
+
+	// No matching templates, unknown refs
+	_, _, err = LoadGitTemplates(ctx, cache, GitSource{URL: repo.url, Paths: []string{"docs/**"}})
+	assert.Error(t, err)
+	_, _, err = LoadGitTemplates(ctx, cache, GitSource{URL: repo.url, Ref: "missing"})
+	assert.Error(t, err)
+}
+
+func TestGitSourcesChanged(t *testing.T) {
+	repo := newGitRepo(t)
+	sha := repo.commit(map[string]string{"volumeclaim.yaml": templateYAML("volumeclaim", "1.0.0")})
+	cache := t.TempDir()
+	ctx := context.Background()
+	sources := []GitSource{{URL: repo.url, Ref: "main"}, {URL: repo.url, Ref: sha}}
+
+	// Not checked out yet
+	changed, err := GitSourcesChanged(ctx, cache, sources[:1])
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	for _, src := range sources {
+		_, _, err := LoadGitTemplates(ctx, cache, src)
+		require.NoError(t, err)
+	}
+	changed, err = GitSourcesChanged(ctx, cache, sources)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	repo.commit(map[string]string{"volumeclaim.yaml": templateYAML("volumeclaim", "1.1.0")})
+	changed, err = GitSourcesChanged(ctx, cache, sources)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// Pinned commits never change
+	changed, err = GitSourcesChanged(ctx, cache, sources[1:])
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestRemoteCommit(t *testing.T) {
+	repo := newGitRepo(t)
+	first := repo.commit(map[string]string{"volumeclaim.yaml": templateYAML("volumeclaim", "1.0.0")})
+	repo.run(repo.work, "tag", "-a", "-m", "release", "v1.0.0")
+	repo.run(repo.work, "push", "-q", "origin", "v1.0.0")
+
+	// Branches whose last component is the ref do not match it
+	repo.run(repo.work, "checkout", "-q", "-b", "feature/main")
+	feature := repo.commit(map[string]string{"volumeclaim.yaml": templateYAML("volumeclaim", "2.0.0")})
+	repo.run(repo.work, "push", "-q", "origin", "feature/main")
+	require.NotEqual(t, first, feature)
+
+	ctx := context.Background()
+	for ref, want := range map[string]string{
+		"main":                    first,
+		"refs/heads/main":         first,
+		"v1.0.0":                  first,
+		"feature/main":            feature,
+		"refs/heads/feature/main": feature,
+	} {
+		sha, err := remoteCommit(ctx, GitSource{URL: repo.url, Ref: ref})
+		require.NoError(t, err, ref)
+		assert.Equal(t, want, sha, ref)
+	}
+	_, err := remoteCommit(ctx, GitSource{URL: repo.url, Ref: "heads/main"})
+	assert.Error(t, err)
+
+	// Arguments are not taken as options
+	_, err = remoteCommit(ctx, GitSource{URL: "--upload-pack=touch pwned", Ref: "main"})
+	assert.Error(t, err)
+}
+
+func TestLoadGitTemplates_CachedCheckout(t *testing.T) {
+	repo := newGitRepo(t)
+	sha := repo.commit(map[string]string{"volumeclaim.yaml": templateYAML("volumeclaim", "1.0.0")})
+	cache := t.TempDir()
+	ctx := context.Background()
+	src := GitSource{URL: repo.url, Ref: "main"}
+
+	_, _, err := LoadGitTemplates(ctx, cache, src)
+	require.NoError(t, err)
+
+	// The remote is gone, the last checkout is served
+	require.NoError(t, os.RemoveAll(repo.url))
+	tmpls, sources, err := LoadGitTemplates(ctx, cache, src)
+	require.NoError(t, err)
+	require.Len(t, tmpls, 1)
+	assert.Equal(t, sha, tmpls[0].Metadata.Origin.Revision)
+	assert.Equal(t, []string{repo.url + "@" + sha + "#volumeclaim.yaml"}, sources)
+
+	// Without a checkout the error is returned
+	_, _, err = LoadGitTemplates(ctx, t.TempDir(), src)
+	assert.Error(t, err)
+}
+
+func TestGitSourcesChanged_FailedSync(t *testing.T) {
+	repo := newGitRepo(t)
+	repo.commit(map[string]string{"volumeclaim.yaml": templateYAML("volumeclaim", "1.0.0")})
+	cache := t.TempDir()
+	ctx := context.Background()
+	src := GitSource{URL: repo.url, Ref: "main"}
+
+	_, _, err := LoadGitTemplates(ctx, cache, src)
+	require.NoError(t, err)
+
+	// The remote moves on, but fetching into the checkout fails
+	dir := src.checkoutDir(cache)
+	repo.run(dir, "remote", "set-url", "origin", filepath.Join(t.TempDir(), "missing.git"))
+	repo.commit(map[string]string{"volumeclaim.yaml": templateYAML("volumeclaim", "1.1.0")})
+	changed, err := GitSourcesChanged(ctx, cache, []GitSource{src})
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// After the failed reload the same commit is no change anymore
+	_, _, err = LoadGitTemplates(ctx, cache, src)
+	require.NoError(t, err)
+	changed, err = GitSourcesChanged(ctx, cache, []GitSource{src})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// A newer commit is, and a successful sync forgets the failure
+	repo.commit(map[string]string{"volumeclaim.yaml": templateYAML("volumeclaim", "1.2.0")})
+	changed, err = GitSourcesChanged(ctx, cache, []GitSource{src})
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	repo.run(dir, "remote", "set-url", "origin", repo.url)
+	tmpls, _, err := LoadGitTemplates(ctx, cache, src)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.0", tmpls[0].Metadata.Version)
+	assert.NoFileExists(t, filepath.Join(dir, ".git", failedSyncFile))
+}
+
+func TestLoadTemplatesFromProfile_Git(t *testing.T) {
+	repo := newGitRepo(t)
+	sha := repo.commit(map[string]string{"templates/volumeclaim.yaml": templateYAML("volumeclaim", "1.0.0")})
+	t.Setenv("GIT_CACHE_DIR", t.TempDir())
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.yaml")
+	require.NoError(t, os.WriteFile(local, []byte(templateYAML("local", "0.1.0")), 0o644))
+	profile := filepath.Join(dir, "profile.yaml")
+	require.NoError(t, os.WriteFile(profile, []byte("templates:\n"+
+		"  - "+local+"\n"+
+		"git:\n"+
+		"  - url: "+repo.url+"\n"+
+		"    ref: main\n"+
+		"    paths:\n"+
+		"      - templates/*.yaml\n"+
+		"  - url: "+filepath.Join(dir, "missing.git")+"\n"), 0o644))
+
+	tmpls, sources, err := LoadTemplatesFromProfile(profile)
+	require.NoError(t, err)
+	require.Len(t, tmpls, 2)
+	assert.Equal(t, "local", tmpls[0].Metadata.Name)
+	assert.Equal(t, "volumeclaim", tmpls[1].Metadata.Name)
+	assert.Equal(t, []string{local, repo.url + "@" + sha + "#templates/volumeclaim.yaml"}, sources)
+
+	gitSources, err := ProfileGitSources(profile)
+	require.NoError(t, err)
+	require.Len(t, gitSources, 2)
+	assert.Equal(t, []string{"templates/*.yaml"}, gitSources[0].Paths)
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.yaml", "volumeclaim.yaml", true},
+		{"*.yaml", "templates/volumeclaim.yaml", false},
+		{"templates/*.yaml", "templates/volumeclaim.yaml", true},
+		{"templates/*.yaml", "templates/infra/vm.yaml", false},
+		{"**/*.yaml", "volumeclaim.yaml", true},
+		{"**/*.yaml", "templates/infra/vm.yaml", true},
+		{"templates/**", "templates/infra/vm.yaml", true},
+		{"templates/**", "examples/vm.yaml", false},
+		{"templates/**/vm.yaml", "templates/vm.yaml", true},
+		{"templates/**/vm.yaml", "templates/a/b/vm.yaml", true},
+		{"templates/**/vm.yaml", "templates/a/b/db.yaml", false},
+		{"[", "[", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.name), "%s ~ %s", tt.pattern, tt.name)
+	}
+}
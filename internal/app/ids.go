@@ -0,0 +1,71 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// crockford is the ULID base32 alphabet
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var ulidState struct {
+	sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+// NewULID returns a ULID: a 48-bit millisecond timestamp followed by 80
+// random bits. IDs sort by creation time and are unique across replicas;
+// within one process IDs of the same millisecond are strictly increasing.
+func NewULID() (string, error) {
+	return newULID(time.Now())
+}
+
+func newULID(now time.Time) (string, error) {
+	ulidState.Lock()
+	defer ulidState.Unlock()
+
+	ms := uint64(now.UnixMilli())
+	if ms == ulidState.lastMs {
+		// Increment the previous entropy to stay monotonic
+		for i := len(ulidState.entropy) - 1; i >= 0; i-- {
+			ulidState.entropy[i]++
+			if ulidState.entropy[i] != 0 {
+				break
+			}
+		}
+	} else {
+		if _, err := rand.Read(ulidState.entropy[:]); err != nil {
+			return "", err
+		}
+		ulidState.lastMs = ms
+	}
+
+	var b [16]byte
+	binary.BigEndian.PutUint16(b[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(ms))
+	copy(b[6:], ulidState.entropy[:])
+
+	return encodeCrockford(b), nil
+}
+
+// encodeCrockford encodes 128 bits as 26 base32 characters
+func encodeCrockford(b [16]byte) string {
+	out := make([]byte, 26)
+	// 130 bits of output, the first two are padding
+	var acc uint64
+	bits := 2
+	pos := 0
+	for _, v := range b {
+		acc = acc<<8 | uint64(v)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockford[(acc>>uint(bits))&0x1f]
+			pos++
+		}
+	}
+	return string(out)
+}
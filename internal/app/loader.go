@@ -2,45 +2,97 @@ package app
 
 import (
 	"fmt"
+	"io/fs"
 	"log"
-	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
 )
 
-// LoadAllTemplates scans the templates directory and loads all YAML files
-func LoadAllTemplates(dir string) ([]*claimtemplate.ClaimTemplate, error) {
+// DirOptions select the files loaded from a templates directory
+type DirOptions struct {
+	// Include are globs relative to the directory; ** matches any number
+	// of directories (default: all YAML files)
+	Include []string
+
+	// Exclude are globs of files and directories to skip
+	Exclude []string
+}
+
+func (o DirOptions) include() []string {
+	if len(o.Include) == 0 {
+		return allYAMLFiles
+	}
+	return o.Include
+}
+
+// LoadAllTemplates walks the templates directory and its subdirectories and
+// loads all matching YAML files. A file may hold several templates separated
+// by "---". Templates without a category get the subdirectory of their file,
+// e.g. team-a/databases. Hidden directories such as .git are skipped.
+func LoadAllTemplates(dir string, opts DirOptions) ([]*claimtemplate.ClaimTemplate, error) {
 	var templates []*claimtemplate.ClaimTemplate
 
-	entries, err := os.ReadDir(dir)
+	// WalkDir does not follow symlinks, resolve a linked directory itself
+	root, err := filepath.EvalSymlinks(dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && (strings.HasPrefix(d.Name(), ".") || matchAnyGlob(opts.Exclude, rel)) {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
 		// Only load YAML files
-		if !isYAMLFile(entry.Name()) {
-			continue
+		if !isYAMLFile(rel) || !matchAnyGlob(opts.include(), rel) || matchAnyGlob(opts.Exclude, rel) {
+			return nil
 		}
 
-		templatePath := filepath.Join(dir, entry.Name())
-		tmpl, err := claimtemplate.LoadClaimTemplate(templatePath)
+		tmpls, err := claimtemplate.LoadClaimTemplates(p)
 		if err != nil {
-			log.Printf("⚠️  failed to load template %s: %v", entry.Name(), err)
-			continue
+			log.Printf("⚠️  failed to load template %s: %v", rel, err)
+			return nil
 		}
 
-		templates = append(templates, tmpl)
+		category := path.Dir(rel)
+		for _, tmpl := range tmpls {
+			if !isClaimTemplate(tmpl) {
+				continue
+			}
+			if tmpl.Metadata.Category == "" && category != "." {
+				tmpl.Metadata.Category = category
+			}
+			templates = append(templates, tmpl)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
 	return templates, nil
 }
 
+// isClaimTemplate reports whether a parsed file is a claim template or
+// bundle; artifacts and repositories carry other YAML files as well
+func isClaimTemplate(t *claimtemplate.ClaimTemplate) bool {
+	return t.Metadata.Name != "" && (t.Kind == claimtemplate.KindClaimTemplate || t.IsBundle())
+}
+
 // isYAMLFile checks if a file is a YAML file
 func isYAMLFile(filename string) bool {
 	ext := filepath.Ext(filename)
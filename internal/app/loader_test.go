@@ -0,0 +1,77 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func writeTemplates(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+}
+
+func categories(tmpls []*claimtemplate.ClaimTemplate) map[string]string {
+	out := make(map[string]string, len(tmpls))
+	for _, t := range tmpls {
+		out[t.Metadata.Name] = t.Metadata.Category
+	}
+	return out
+}
+
+func TestLoadAllTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplates(t, dir, map[string]string{
+		"volumeclaim.yaml": templateYAML("volumeclaim", "1.0.0"),
+		"team-a/databases/postgres.yaml": templateYAML("postgresql", "1.0.0") + "---\n" +
+			templateYAML("mysql", "1.0.0") + "---\n",
+		"team-a/drafts/wip.yaml":      templateYAML("wip", "0.1.0"),
+		"team-b/harborproject.yml":    templateYAML("harborproject", "1.0.0"),
+		"team-b/vm.yaml":              strings.Replace(templateYAML("vspherevm", "1.0.0"), "metadata:\n", "metadata:\n  category: compute\n", 1),
+		"team-b/broken.yaml":          "metadata: [\n",
+		"team-b/profile.yaml":         "templates:\n  - volumeclaim.yaml\n",
+		"team-b/README.md":            "# team b\n",
+		".git/templates/hidden.yaml":  templateYAML("hidden", "1.0.0"),
+		"team-a/databases/notes.yaml": "notes: true\n",
+	})
+
+	tmpls, err := LoadAllTemplates(dir, DirOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"volumeclaim":   "",
+		"postgresql":    "team-a/databases",
+		"mysql":         "team-a/databases",
+		"wip":           "team-a/drafts",
+		"harborproject": "team-b",
+		"vspherevm":     "compute",
+	}, categories(tmpls))
+
+	// Include and exclude globs
+	tmpls, err = LoadAllTemplates(dir, DirOptions{Include: []string{"team-a/**"}, Exclude: []string{"**/drafts"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"postgresql": "team-a/databases", "mysql": "team-a/databases"}, categories(tmpls))
+
+	tmpls, err = LoadAllTemplates(dir, DirOptions{Exclude: []string{"team-a/**", "*.yaml"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"harborproject": "team-b", "vspherevm": "compute"}, categories(tmpls))
+
+	// Linked directories are walked
+	link := filepath.Join(t.TempDir(), "templates")
+	require.NoError(t, os.Symlink(filepath.Join(dir, "team-b"), link))
+	tmpls, err = LoadAllTemplates(link, DirOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"harborproject": "", "vspherevm": "compute"}, categories(tmpls))
+
+	_, err = LoadAllTemplates(filepath.Join(dir, "missing"), DirOptions{})
+	assert.Error(t, err)
+}
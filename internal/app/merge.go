@@ -0,0 +1,56 @@
+package app
+
+import (
+	"log"
+	"sort"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// MergeTemplates de-duplicates templates by metadata.name and version;
+// later lists override earlier ones. The result is sorted by name and
+// version.
+func MergeTemplates(lists ...[]*claimtemplate.ClaimTemplate) []*claimtemplate.ClaimTemplate {
+	merged := make(map[string]*claimtemplate.ClaimTemplate)
+	for _, list := range lists {
+		for _, t := range list {
+			merged[t.Metadata.Name+"@"+t.VersionKey()] = t
+		}
+	}
+	final := make([]*claimtemplate.ClaimTemplate, 0, len(merged))
+	for _, t := range merged {
+		final = append(final, t)
+	}
+	sort.Slice(final, func(i, j int) bool {
+		if final[i].Metadata.Name != final[j].Metadata.Name {
+			return final[i].Metadata.Name < final[j].Metadata.Name
+		}
+		return claimtemplate.CompareVersions(final[i].VersionKey(), final[j].VersionKey()) < 0
+	})
+	return final
+}
+
+// ServedTemplates combines the templates configured on the server
+// (directory and profile) with ClaimTemplate resources of the cluster.
+// Configured templates take precedence: resources named like one of them
+// are dropped, so namespaces cannot replace or add versions of templates
+// configured on the server. Without a cluster resources is nil.
+func ServedTemplates(configured, resources []*claimtemplate.ClaimTemplate) []*claimtemplate.ClaimTemplate {
+	names := make(map[string]bool, len(configured))
+	for _, t := range configured {
+		names[t.Metadata.Name] = true
+	}
+	cluster := make([]*claimtemplate.ClaimTemplate, 0, len(resources))
+	for _, t := range resources {
+		if names[t.Metadata.Name] {
+			from := t.Metadata.Name
+			if t.Metadata.Origin != nil {
+				from = t.Metadata.Origin.Path
+			}
+			log.Printf("⚠️  ClaimTemplate %s conflicts with configured template %s (skipping)", from, t.Metadata.Name)
+			continue
+		}
+		cluster = append(cluster, t)
+	}
+	return MergeTemplates(configured, cluster)
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func testTemplate(name, version, origin string) *claimtemplate.ClaimTemplate {
+	return &claimtemplate.ClaimTemplate{Metadata: claimtemplate.ClaimTemplateMetadata{
+		Name:    name,
+		Version: version,
+		Origin:  &claimtemplate.Origin{Type: origin, Path: origin + "/" + name},
+	}}
+}
+
+// templateIDs lists templates as name@version:origin
+func templateIDs(tmpls []*claimtemplate.ClaimTemplate) []string {
+	ids := make([]string, 0, len(tmpls))
+	for _, t := range tmpls {
+		ids = append(ids, t.Metadata.Name+"@"+t.VersionKey()+":"+t.Metadata.Origin.Type)
+	}
+	return ids
+}
+
+func TestMergeTemplates(t *testing.T) {
+	tests := []struct {
+		name  string
+		lists [][]*claimtemplate.ClaimTemplate
+		want  []string
+	}{
+		{
+			name: "empty",
+			want: []string{},
+		},
+		{
+			name: "later lists override the same version",
+			lists: [][]*claimtemplate.ClaimTemplate{
+				{testTemplate("volumeclaim", "1.0.0", "dir"), testTemplate("postgres", "", "dir")},
+				{testTemplate("volumeclaim", "v1.0", "profile")},
+			},
+			want: []string{"postgres@0.0.0:dir", "volumeclaim@1.0.0:profile"},
+		},
+		{
+			name: "other versions are kept",
+			lists: [][]*claimtemplate.ClaimTemplate{
+				{testTemplate("volumeclaim", "1.0.0", "dir")},
+				{testTemplate("volumeclaim", "1.10.0", "profile"), testTemplate("volumeclaim", "1.9.0", "profile")},
+			},
+			want: []string{"volumeclaim@1.0.0:dir", "volumeclaim@1.9.0:profile", "volumeclaim@1.10.0:profile"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, templateIDs(MergeTemplates(tt.lists...)))
+		})
+	}
+}
+
+func TestServedTemplates(t *testing.T) {
+	configured := []*claimtemplate.ClaimTemplate{
+		testTemplate("volumeclaim", "1.0.0", "dir"),
+		testTemplate("postgres", "2.0.0", "profile"),
+	}
+
+	tests := []struct {
+		name      string
+		resources []*claimtemplate.ClaimTemplate
+		want      []string
+	}{
+		{
+			name: "cluster unavailable",
+			want: []string{"postgres@2.0.0:profile", "volumeclaim@1.0.0:dir"},
+		},
+		{
+			name:      "no resources",
+			resources: []*claimtemplate.ClaimTemplate{},
+			want:      []string{"postgres@2.0.0:profile", "volumeclaim@1.0.0:dir"},
+		},
+		{
+			name:      "new templates are added",
+			resources: []*claimtemplate.ClaimTemplate{testTemplate("redis", "1.0.0", "kubernetes")},
+			want:      []string{"postgres@2.0.0:profile", "redis@1.0.0:kubernetes", "volumeclaim@1.0.0:dir"},
+		},
+		{
+			name:      "configured versions are not replaced",
+			resources: []*claimtemplate.ClaimTemplate{testTemplate("volumeclaim", "1.0.0", "kubernetes")},
+			want:      []string{"postgres@2.0.0:profile", "volumeclaim@1.0.0:dir"},
+		},
+		{
+			name: "configured templates get no new versions",
+			resources: []*claimtemplate.ClaimTemplate{
+				testTemplate("postgres", "3.0.0", "kubernetes"),
+				testTemplate("redis", "1.0.0", "kubernetes"),
+			},
+			want: []string{"postgres@2.0.0:profile", "redis@1.0.0:kubernetes", "volumeclaim@1.0.0:dir"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, templateIDs(ServedTemplates(configured, tt.resources)))
+		})
+	}
+
+	// Without configured templates the cluster serves everything
+	assert.Equal(t, []string{"redis@1.0.0:kubernetes"},
+		templateIDs(ServedTemplates(nil, []*claimtemplate.ClaimTemplate{testTemplate("redis", "1.0.0", "kubernetes")})))
+}
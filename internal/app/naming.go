@@ -0,0 +1,105 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// Order naming strategies
+const (
+	NamingULID      = "ulid"
+	NamingUUID      = "uuid"
+	NamingTimestamp = "timestamp"
+)
+
+// maxNameLength is the Kubernetes DNS label limit
+const maxNameLength = 63
+
+// ValidNamingStrategy reports whether s is a known naming strategy
+func ValidNamingStrategy(s string) bool {
+	return s == NamingULID || s == NamingUUID || s == NamingTimestamp
+}
+
+// OrderName builds the human readable name of an order. A template's
+// spec.orderName pattern takes precedence over the strategy; it is a Go
+// template over the parameters plus .template, .uid and .shortId.
+// uid must be a ULID.
+func OrderName(t *claimtemplate.ClaimTemplate, params map[string]interface{}, strategy, uid string, now time.Time) (string, error) {
+	name := t.Metadata.Name
+	if t.Spec.OrderName != "" {
+		data := make(map[string]interface{}, len(params)+3)
+		for k, v := range params {
+			data[k] = v
+		}
+		data["template"] = name
+		data["uid"] = strings.ToLower(uid)
+		data["shortId"] = shortID(uid)
+
+		out, err := evaluateTemplate("orderName", t.Spec.OrderName, data)
+		if err != nil {
+			return "", fmt.Errorf("spec.orderName: %w", err)
+		}
+		out = sanitizeName(out)
+		if out == "" {
+			return "", fmt.Errorf("spec.orderName: pattern produced an empty name")
+		}
+		return out, nil
+	}
+
+	var unique string
+	switch strategy {
+	case NamingTimestamp:
+		unique = now.Format("20060102150405")
+	case NamingUUID:
+		id, err := NewUUID()
+		if err != nil {
+			return "", err
+		}
+		unique = id
+	default:
+		unique = strings.ToLower(uid)
+	}
+
+	// Shorten the template part so the unique part always fits
+	prefix := sanitizeName(name + "-order")
+	if limit := maxNameLength - len(unique) - 1; len(prefix) > limit {
+		prefix = strings.TrimRight(prefix[:limit], "-")
+	}
+	return prefix + "-" + unique, nil
+}
+
+// shortID returns the last 8 characters of a ULID, which are random
+func shortID(uid string) string {
+	uid = strings.ToLower(uid)
+	if len(uid) <= 8 {
+		return uid
+	}
+	return uid[len(uid)-8:]
+}
+
+// sanitizeName turns s into a valid Kubernetes resource name: lowercase
+// alphanumerics and dashes, at most 63 characters
+func sanitizeName(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+
+	out := b.String()
+	for strings.Contains(out, "--") {
+		out = strings.ReplaceAll(out, "--", "-")
+	}
+	if len(out) > maxNameLength {
+		// Keep the end, which carries the unique part
+		out = out[len(out)-maxNameLength:]
+	}
+	return strings.Trim(out, "-")
+}
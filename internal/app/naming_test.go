@@ -0,0 +1,75 @@
+package app
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func TestNewULID(t *testing.T) {
+	now := time.Now()
+	ids := make([]string, 100)
+	seen := make(map[string]bool)
+	for i := range ids {
+		id, err := newULID(now)
+		require.NoError(t, err)
+		require.Len(t, id, 26)
+		require.False(t, seen[id], "duplicate ULID %s", id)
+		seen[id] = true
+		ids[i] = id
+	}
+
+	// IDs within the same millisecond are monotonic
+	assert.True(t, sort.StringsAreSorted(ids))
+
+	later, err := newULID(now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Greater(t, later, ids[len(ids)-1])
+}
+
+func TestOrderName_Strategies(t *testing.T) {
+	tmpl := &claimtemplate.ClaimTemplate{Metadata: claimtemplate.ClaimTemplateMetadata{Name: "volumeclaim"}}
+	uid := "01J9ZQ8Y7E3M4K5N6P7Q8R9S0T"
+	now := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+
+	name, err := OrderName(tmpl, nil, NamingULID, uid, now)
+	require.NoError(t, err)
+	assert.Equal(t, "volumeclaim-order-01j9zq8y7e3m4k5n6p7q8r9s0t", name)
+
+	name, err = OrderName(tmpl, nil, NamingTimestamp, uid, now)
+	require.NoError(t, err)
+	assert.Equal(t, "volumeclaim-order-20261019083000", name)
+
+	name, err = OrderName(tmpl, nil, NamingUUID, uid, now)
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^volumeclaim-order-[0-9a-f-]{36}$`), name)
+
+	// Long template names are shortened, the unique part is kept
+	tmpl.Metadata.Name = strings.Repeat("x", 60)
+	name, err = OrderName(tmpl, nil, NamingULID, uid, now)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(name), 63)
+	assert.True(t, strings.HasSuffix(name, "-01j9zq8y7e3m4k5n6p7q8r9s0t"))
+}
+
+func TestOrderName_Pattern(t *testing.T) {
+	tmpl := &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "postgresql"},
+		Spec:     claimtemplate.ClaimTemplateSpec{OrderName: "{{ .namespace }}-{{ .template }}-{{ .shortId }}"},
+	}
+	uid := "01J9ZQ8Y7E3M4K5N6P7Q8R9S0T"
+
+	name, err := OrderName(tmpl, map[string]interface{}{"namespace": "Team_A"}, NamingULID, uid, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "team-a-postgresql-7q8r9s0t", name)
+
+	_, err = OrderName(tmpl, map[string]interface{}{}, NamingULID, uid, time.Now())
+	assert.Error(t, err)
+}
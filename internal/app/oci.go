@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci"
+)
+
+// ociPullTimeout bounds pulling a template artifact
+const ociPullTimeout = 2 * time.Minute
+
+// LoadOCITemplates pulls an OCI artifact (oci://registry/repository:tag,
+// optionally pinned with @sha256:...) and loads the claim templates of its
+// YAML files. Each source is the reference pinned to the pulled digest and
+// the file name, e.g. oci://ghcr.io/org/templates:1.0.0@sha256:...#volumeclaim.yaml.
+func LoadOCITemplates(ctx context.Context, client *oci.Client, entry string) ([]*claimtemplate.ClaimTemplate, []string, error) {
+	ref, err := oci.ParseReference(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	artifact, err := client.Pull(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err := artifact.Files()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unpack %s: %w", ref, err)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if isYAMLFile(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	pinned := ref
+	pinned.Digest = artifact.Digest
+
+	var (
+		out     []*claimtemplate.ClaimTemplate
+		sources []string
+	)
+	for _, name := range names {
+		tmpls, err := claimtemplate.ParseClaimTemplates(files[name])
+		if err != nil {
+			log.Printf("⚠️  failed to load template %s from %s: %v (skipping)", name, ref, err)
+			continue
+		}
+		for _, tmpl := range tmpls {
+			if !isClaimTemplate(tmpl) {
+				continue
+			}
+			tmpl.Metadata.Origin = &claimtemplate.Origin{
+				Type:     "oci",
+				URL:      "oci://" + ref.String(),
+				Revision: artifact.Digest,
+				Path:     name,
+			}
+			out = append(out, tmpl)
+			sources = append(sources, "oci://"+pinned.String()+"#"+name)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil, fmt.Errorf("no claim templates in %s", ref)
+	}
+	return out, sources, nil
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci"
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci/ocitest"
+)
+
+func templateYAML(name, version string) string {
+	return "apiVersion: resources.stuttgart-things.com/v1alpha1\nkind: ClaimTemplate\nmetadata:\n  name: " + name +
+		"\n  version: " + version + "\nspec:\n  type: kcl\n  source: oci://ghcr.io/stuttgart-things/claim-xplane-" + name + "\n"
+}
+
+func tarLayer(t *testing.T, files map[string]string) ocitest.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return ocitest.Layer{MediaType: "application/vnd.oci.image.layer.v1.tar", Data: buf.Bytes()}
+}
+
+func TestLoadOCITemplates(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	digest := registry.Push("stuttgart-things/claim-templates", "1.0.0",
+		tarLayer(t, map[string]string{
+			"templates/volumeclaim.yaml":   templateYAML("volumeclaim", "1.0.0"),
+			"templates/harborproject.yaml": templateYAML("harborproject", "1.0.0"),
+			"kcl.mod.yaml":                 "package:\n  name: claims\n",
+			"README.md":                    "# templates\n",
+		}),
+		ocitest.Layer{MediaType: "application/yaml", Title: "vspherevm.yaml", Data: []byte(templateYAML("vspherevm", "2.0.0"))},
+	)
+	ref := "oci://" + registry.Host() + "/stuttgart-things/claim-templates"
+
+	tmpls, sources, err := LoadOCITemplates(context.Background(), oci.NewClient(), ref+":1.0.0")
+	require.NoError(t, err)
+	require.Len(t, tmpls, 3)
+	assert.Equal(t, "harborproject", tmpls[0].Metadata.Name)
+	assert.Equal(t, "volumeclaim", tmpls[1].Metadata.Name)
+	assert.Equal(t, "2.0.0", tmpls[2].VersionKey())
+	assert.Equal(t, &claimtemplate.Origin{
+		Type:     "oci",
+		URL:      ref + ":1.0.0",
+		Revision: digest,
+		Path:     "templates/volumeclaim.yaml",
+	}, tmpls[1].Metadata.Origin)
+	assert.Equal(t, []string{
+		ref + ":1.0.0@" + digest + "#templates/harborproject.yaml",
+		ref + ":1.0.0@" + digest + "#templates/volumeclaim.yaml",
+		ref + ":1.0.0@" + digest + "#vspherevm.yaml",
+	}, sources)
+
+	// Pinned digests must match
+	_, _, err = LoadOCITemplates(context.Background(), oci.NewClient(), ref+"@"+digest)
+	require.NoError(t, err)
+	_, _, err = LoadOCITemplates(context.Background(), oci.NewClient(), ref+":1.0.0@sha256:"+strings.Repeat("0", 64))
+	assert.Error(t, err)
+
+	// Artifacts without templates are an error
+	registry.Push("stuttgart-things/empty", "1.0.0", tarLayer(t, map[string]string{"kcl.mod.yaml": "package: {}\n"}))
+	_, _, err = LoadOCITemplates(context.Background(), oci.NewClient(), "oci://"+registry.Host()+"/stuttgart-things/empty:1.0.0")
+	assert.Error(t, err)
+}
+
+func TestLoadTemplatesFromProfile_OCI(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	registry.Token, registry.Username, registry.Password = "token", "alice", "s3cret"
+	registry.Push("stuttgart-things/claim-templates", "1.0.0",
+		ocitest.Layer{MediaType: "application/yaml", Title: "volumeclaim.yaml", Data: []byte(templateYAML("volumeclaim", "1.0.0"))},
+	)
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.yaml")
+	require.NoError(t, os.WriteFile(local, []byte(templateYAML("local", "0.1.0")), 0o644))
+	profile := filepath.Join(dir, "profile.yaml")
+	require.NoError(t, os.WriteFile(profile, []byte("templates:\n"+
+		"  - oci://"+registry.Host()+"/stuttgart-things/claim-templates:1.0.0\n"+
+		"  - oci://"+registry.Host()+"/stuttgart-things/missing:1.0.0\n"+
+		"  - "+local+"\n"), 0o644))
+
+	// Registry logins come from the docker config
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"),
+		[]byte(`{"auths":{"`+registry.Host()+`":{"username":"alice","password":"s3cret"}}}`), 0o600))
+	t.Setenv("DOCKER_CONFIG", dir)
+
+	tmpls, sources, err := LoadTemplatesFromProfile(profile)
+	require.NoError(t, err)
+	require.Len(t, tmpls, 2)
+	assert.Equal(t, "volumeclaim", tmpls[0].Metadata.Name)
+	assert.Equal(t, "local", tmpls[1].Metadata.Name)
+	assert.Contains(t, sources[0], "@sha256:")
+	assert.Equal(t, local, sources[1])
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci"
 	"gopkg.in/yaml.v3"
 )
 
@@ -19,21 +21,43 @@ import (
 type profileYAML struct {
 	Templates []string `yaml:"templates"`
 	Tenplates []string `yaml:"tenplates"`
+
+	// Git lists repositories to load templates from
+	Git []GitSource `yaml:"git"`
 }
 
-// LoadTemplatesFromProfile loads claim templates from a YAML profile file.
-// Entries can be local file paths or HTTP/HTTPS URLs. URLs are validated and
-// downloaded to a temporary file before parsing.
-func LoadTemplatesFromProfile(profilePath string) ([]*claimtemplate.ClaimTemplate, []string, error) {
+func readProfile(profilePath string) (*profileYAML, error) {
 	f, err := os.Open(profilePath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("open profile: %w", err)
+		return nil, fmt.Errorf("open profile: %w", err)
 	}
 	defer f.Close()
 
 	var p profileYAML
 	if err := yaml.NewDecoder(f).Decode(&p); err != nil {
-		return nil, nil, fmt.Errorf("parse profile yaml: %w", err)
+		return nil, fmt.Errorf("parse profile yaml: %w", err)
+	}
+	return &p, nil
+}
+
+// ProfileGitSources returns the Git sources of a profile file
+func ProfileGitSources(profilePath string) ([]GitSource, error) {
+	p, err := readProfile(profilePath)
+	if err != nil {
+		return nil, err
+	}
+	return p.Git, nil
+}
+
+// LoadTemplatesFromProfile loads claim templates from a YAML profile file.
+// Entries can be local file paths, HTTP/HTTPS URLs or OCI artifacts
+// (oci://...). URLs are validated and downloaded to a temporary file before
+// parsing; artifacts may contain several templates. Git sources are fetched
+// into the Git cache directory.
+func LoadTemplatesFromProfile(profilePath string) ([]*claimtemplate.ClaimTemplate, []string, error) {
+	p, err := readProfile(profilePath)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	entries := append([]string{}, p.Templates...)
@@ -44,6 +68,7 @@ func LoadTemplatesFromProfile(profilePath string) ([]*claimtemplate.ClaimTemplat
 	var (
 		out     []*claimtemplate.ClaimTemplate
 		sources []string
+		client  *oci.Client
 	)
 
 	for _, e := range entries {
@@ -52,6 +77,22 @@ func LoadTemplatesFromProfile(profilePath string) ([]*claimtemplate.ClaimTemplat
 			continue
 		}
 
+		if strings.HasPrefix(e, "oci://") {
+			if client == nil {
+				client = oci.NewClient()
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), ociPullTimeout)
+			tmpls, srcs, err := LoadOCITemplates(ctx, client, e)
+			cancel()
+			if err != nil {
+				log.Printf("⚠️  failed to load templates from %s: %v (skipping)", e, err)
+				continue
+			}
+			out = append(out, tmpls...)
+			sources = append(sources, srcs...)
+			continue
+		}
+
 		localPath := e
 		if strings.HasPrefix(e, "http://") || strings.HasPrefix(e, "https://") {
 			// Validate URL via HEAD (fallback to GET), then download
@@ -82,6 +123,18 @@ func LoadTemplatesFromProfile(profilePath string) ([]*claimtemplate.ClaimTemplat
 		sources = append(sources, e)
 	}
 
+	for _, src := range p.Git {
+		ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+		tmpls, srcs, err := LoadGitTemplates(ctx, GitCacheDir(), src)
+		cancel()
+		if err != nil {
+			log.Printf("⚠️  failed to load templates from %s: %v (skipping)", src.URL, err)
+			continue
+		}
+		out = append(out, tmpls...)
+		sources = append(sources, srcs...)
+	}
+
 	return out, sources, nil
 }
 
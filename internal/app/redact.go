@@ -0,0 +1,52 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// MaskedValue replaces secret parameter values in logs and history
+const MaskedValue = "********"
+
+// RedactParameters returns a copy of params with secret values masked
+func RedactParameters(t *claimtemplate.ClaimTemplate, params map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+
+	for _, p := range t.Spec.Parameters {
+		if _, ok := out[p.Name]; ok && p.IsSecret() {
+			out[p.Name] = MaskedValue
+		}
+	}
+
+	return out
+}
+
+// SecretValues returns the plaintext values of secret parameters in params
+func SecretValues(t *claimtemplate.ClaimTemplate, params map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, p := range t.Spec.Parameters {
+		if v, ok := params[p.Name]; ok && p.IsSecret() {
+			out[p.Name] = v
+		}
+	}
+	return out
+}
+
+// RedactString masks occurrences of secret parameter values in text,
+// e.g. error messages that echo rendering input
+func RedactString(t *claimtemplate.ClaimTemplate, params map[string]interface{}, text string) string {
+	for _, p := range t.Spec.Parameters {
+		if !p.IsSecret() || params[p.Name] == nil {
+			continue
+		}
+		if v := fmt.Sprintf("%v", params[p.Name]); v != "" {
+			text = strings.ReplaceAll(text, v, MaskedValue)
+		}
+	}
+	return text
+}
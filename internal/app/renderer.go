@@ -20,7 +20,7 @@ func BuildParameterValues(t *claimtemplate.ClaimTemplate) map[string]interface{}
 		} else {
 			// Provide reasonable defaults based on type
 			switch p.Type {
-			case "string":
+			case "string", "password":
 				params[p.Name] = ""
 			case "boolean":
 				params[p.Name] = false
@@ -49,14 +49,23 @@ func RenderTemplate(t *claimtemplate.ClaimTemplate, customParams ...map[string]i
 		}
 	}
 
+	// Move secretRef values into separate Secret manifests
+	renderParams, secretManifests, err := applySecretRefs(t, params)
+	if err != nil {
+		return "", err
+	}
+
 	// Render using KCL from OCI source
-	result := render.RenderKCLFromOCI(t.Spec.Source, t.Spec.Tag, params)
+	result, err := render.ExecKCLFromOCI(t.Spec.Source, t.Spec.Tag, renderParams, t.SecretParameterNames()...)
+	if err != nil {
+		return "", fmt.Errorf("rendering template %s: %w", t.Metadata.Name, err)
+	}
 
 	if result == "" {
 		return "", fmt.Errorf("rendering produced empty result for template %s", t.Metadata.Name)
 	}
 
-	return result, nil
+	return appendManifests(result, secretManifests), nil
 }
 
 // RenderTemplateToFile renders a template and saves to file
@@ -65,7 +74,7 @@ func RenderTemplateToFile(t *claimtemplate.ClaimTemplate, destination string) (s
 	params := BuildParameterValues(t)
 
 	// Render using KCL from OCI source
-	result, err := render.RenderKCLFromOCIToFile(t.Spec.Source, t.Spec.Tag, params, destination)
+	result, err := render.RenderKCLFromOCIToFile(t.Spec.Source, t.Spec.Tag, params, destination, t.SecretParameterNames()...)
 	if err != nil {
 		log.Printf("⚠️  failed to render template %s: %v", t.Metadata.Name, err)
 		return result, err
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	secretRefKindSecret         = "Secret"
+	secretRefKindExternalSecret = "ExternalSecret"
+)
+
+type manifestMetadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// secretManifest is a core/v1 Secret holding secret parameter values
+type secretManifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   manifestMetadata  `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// externalSecretManifest is an external-secrets.io ExternalSecret
+type externalSecretManifest struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   manifestMetadata   `yaml:"metadata"`
+	Spec       externalSecretSpec `yaml:"spec"`
+}
+
+type externalSecretSpec struct {
+	SecretStoreRef struct {
+		Kind string `yaml:"kind"`
+		Name string `yaml:"name"`
+	} `yaml:"secretStoreRef"`
+	Target struct {
+		Name string `yaml:"name"`
+	} `yaml:"target"`
+	Data []externalSecretData `yaml:"data"`
+}
+
+type externalSecretData struct {
+	SecretKey string `yaml:"secretKey"`
+	RemoteRef struct {
+		Key string `yaml:"key"`
+	} `yaml:"remoteRef"`
+}
+
+// applySecretRefs replaces the values of secretRef parameters with the name
+// of the target Secret and returns the manifests that carry the values.
+// Parameters referencing the same Secret are combined into one manifest.
+func applySecretRefs(t *claimtemplate.ClaimTemplate, params map[string]interface{}) (map[string]interface{}, []string, error) {
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+
+	var (
+		order     []string
+		secrets   = make(map[string]*secretManifest)
+		externals = make(map[string]*externalSecretManifest)
+	)
+
+	data := map[string]interface{}{
+		"template": t.Metadata.Name,
+		"params":   params,
+	}
+
+	for _, p := range t.Spec.Parameters {
+		ref := p.SecretRef
+		if ref == nil || isEmptyValue(params[p.Name]) {
+			continue
+		}
+
+		name, err := evaluateTemplate(p.Name+".secretRef.name", ref.Name, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("secretRef name of %s: %w", p.Name, err)
+		}
+		if name == "" {
+			return nil, nil, fmt.Errorf("secretRef name of %s is empty", p.Name)
+		}
+
+		namespace, err := evaluateTemplate(p.Name+".secretRef.namespace", ref.Namespace, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("secretRef namespace of %s: %w", p.Name, err)
+		}
+		if namespace == "" {
+			if ns, ok := params["namespace"].(string); ok {
+				namespace = ns
+			}
+		}
+
+		key := ref.Key
+		if key == "" {
+			key = p.Name
+		}
+		value := fmt.Sprintf("%v", params[p.Name])
+		id := namespace + "/" + name
+
+		switch ref.Kind {
+		case "", secretRefKindSecret:
+			m, ok := secrets[id]
+			if !ok {
+				m = &secretManifest{
+					APIVersion: "v1",
+					Kind:       secretRefKindSecret,
+					Metadata:   manifestMetadata{Name: name, Namespace: namespace},
+					Type:       "Opaque",
+					StringData: make(map[string]string),
+				}
+				secrets[id] = m
+				order = append(order, secretRefKindSecret+":"+id)
+			}
+			m.StringData[key] = value
+		case secretRefKindExternalSecret:
+			if ref.SecretStore == "" {
+				return nil, nil, fmt.Errorf("secretRef of %s requires secretStore for ExternalSecret", p.Name)
+			}
+			m, ok := externals[id]
+			if !ok {
+				m = &externalSecretManifest{
+					APIVersion: "external-secrets.io/v1beta1",
+					Kind:       secretRefKindExternalSecret,
+					Metadata:   manifestMetadata{Name: name, Namespace: namespace},
+				}
+				m.Spec.SecretStoreRef.Kind = "ClusterSecretStore"
+				m.Spec.SecretStoreRef.Name = ref.SecretStore
+				m.Spec.Target.Name = name
+				externals[id] = m
+				order = append(order, secretRefKindExternalSecret+":"+id)
+			}
+			entry := externalSecretData{SecretKey: key}
+			entry.RemoteRef.Key = value
+			m.Spec.Data = append(m.Spec.Data, entry)
+		default:
+			return nil, nil, fmt.Errorf("secretRef of %s has unsupported kind %q", p.Name, ref.Kind)
+		}
+
+		out[p.Name] = name
+	}
+
+	manifests := make([]string, 0, len(order))
+	for _, entry := range order {
+		kind, id, _ := strings.Cut(entry, ":")
+
+		var doc interface{} = secrets[id]
+		if kind == secretRefKindExternalSecret {
+			doc = externals[id]
+		}
+
+		b, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal %s %s: %w", kind, id, err)
+		}
+		manifests = append(manifests, string(b))
+	}
+
+	return out, manifests, nil
+}
+
+// appendManifests joins additional YAML documents to rendered output
+func appendManifests(rendered string, manifests []string) string {
+	if len(manifests) == 0 {
+		return rendered
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(rendered, "\n"))
+	for _, m := range manifests {
+		b.WriteString("\n---\n")
+		b.WriteString(strings.TrimRight(m, "\n"))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func secretTestTemplate() *claimtemplate.ClaimTemplate {
+	return &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: "postgresql"},
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{
+				{Name: "name", Type: "string"},
+				{Name: "namespace", Type: "string"},
+				{Name: "apiToken", Type: "string", Secret: true},
+				{
+					Name: "adminPassword",
+					Type: "password",
+					SecretRef: &claimtemplate.SecretRef{
+						Name: "{{ .params.name }}-credentials",
+						Key:  "password",
+					},
+				},
+				{
+					Name: "adminUser",
+					Type: "string",
+					SecretRef: &claimtemplate.SecretRef{
+						Name: "{{ .params.name }}-credentials",
+						Key:  "username",
+					},
+				},
+				{
+					Name: "backupKey",
+					Type: "string",
+					SecretRef: &claimtemplate.SecretRef{
+						Kind:        "ExternalSecret",
+						Name:        "{{ .params.name }}-backup",
+						SecretStore: "vault",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestApplySecretRefs(t *testing.T) {
+	tmpl := secretTestTemplate()
+	params := map[string]interface{}{
+		"name":          "db1",
+		"namespace":     "team-a",
+		"apiToken":      "tok",
+		"adminPassword": "s3cret",
+		"adminUser":     "admin",
+		"backupKey":     "kv/db1/backup",
+	}
+
+	out, manifests, err := applySecretRefs(tmpl, params)
+	require.NoError(t, err)
+
+	assert.Equal(t, "db1-credentials", out["adminPassword"])
+	assert.Equal(t, "db1-credentials", out["adminUser"])
+	assert.Equal(t, "db1-backup", out["backupKey"])
+	assert.Equal(t, "tok", out["apiToken"], "secrets without secretRef stay inline")
+
+	require.Len(t, manifests, 2)
+	assert.Contains(t, manifests[0], "kind: Secret")
+	assert.Contains(t, manifests[0], "namespace: team-a")
+	assert.Contains(t, manifests[0], "password: s3cret")
+	assert.Contains(t, manifests[0], "username: admin")
+	assert.Contains(t, manifests[1], "kind: ExternalSecret")
+	assert.Contains(t, manifests[1], "key: kv/db1/backup")
+}
+
+func TestApplySecretRefs_UnsupportedKind(t *testing.T) {
+	tmpl := &claimtemplate.ClaimTemplate{
+		Spec: claimtemplate.ClaimTemplateSpec{
+			Parameters: []claimtemplate.Parameter{
+				{Name: "token", Type: "string", SecretRef: &claimtemplate.SecretRef{Kind: "Vault", Name: "x"}},
+			},
+		},
+	}
+
+	_, _, err := applySecretRefs(tmpl, map[string]interface{}{"token": "abc"})
+	assert.Error(t, err)
+}
+
+func TestRedactString(t *testing.T) {
+	tmpl := secretTestTemplate()
+	params := map[string]interface{}{"name": "db1", "apiToken": "tok-123"}
+
+	msg := RedactString(tmpl, params, "kcl failed: apiToken=tok-123 name=db1")
+
+	assert.Equal(t, "kcl failed: apiToken="+MaskedValue+" name=db1", msg)
+}
+
+func TestAppendManifests(t *testing.T) {
+	assert.Equal(t, "a: 1\n", appendManifests("a: 1\n", nil))
+	assert.Equal(t, "a: 1\n---\nb: 2\n", appendManifests("a: 1\n", []string{"b: 2\n"}))
+}
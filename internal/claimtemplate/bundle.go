@@ -0,0 +1,44 @@
+package claimtemplate
+
+import (
+	"fmt"
+)
+
+// KindClaimBundle marks a template that orders several claim templates together
+const KindClaimBundle = "ClaimBundle"
+
+// BundleMember references a claim template of a bundle
+type BundleMember struct {
+	// Name identifies the member within the bundle; later members can use
+	// its resolved parameters as {{ .members.<name>.<parameter> }}
+	Name     string `yaml:"name" json:"name"`
+	Template string `yaml:"template" json:"template"`
+	Version  string `yaml:"version,omitempty" json:"version,omitempty"`
+
+	// Parameters of the member template. String values are Go templates
+	// over the bundle parameters and the members before this one.
+	Parameters map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+}
+
+// IsBundle reports whether the template is a ClaimBundle
+func (t *ClaimTemplate) IsBundle() bool {
+	return t.Kind == KindClaimBundle
+}
+
+// ValidateBundle checks the member list of a bundle
+func (t *ClaimTemplate) ValidateBundle() error {
+	if len(t.Spec.Members) == 0 {
+		return fmt.Errorf("bundle %s has no members", t.Metadata.Name)
+	}
+	seen := make(map[string]bool, len(t.Spec.Members))
+	for i, m := range t.Spec.Members {
+		if m.Name == "" || m.Template == "" {
+			return fmt.Errorf("bundle %s: member %d needs a name and a template", t.Metadata.Name, i)
+		}
+		if seen[m.Name] {
+			return fmt.Errorf("bundle %s: duplicate member %s", t.Metadata.Name, m.Name)
+		}
+		seen[m.Name] = true
+	}
+	return nil
+}
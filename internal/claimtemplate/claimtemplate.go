@@ -1,5 +1,11 @@
 package claimtemplate
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
 // ClaimTemplateList represents GET /claim-templates
 type ClaimTemplateList struct {
 	APIVersion string          `json:"apiVersion"`
@@ -7,6 +13,9 @@ type ClaimTemplateList struct {
 	Items      []ClaimTemplate `json:"items"`
 }
 
+// KindClaimTemplate is the kind of claim templates
+const KindClaimTemplate = "ClaimTemplate"
+
 // ClaimTemplate represents a single claim template
 type ClaimTemplate struct {
 	APIVersion string                `yaml:"apiVersion" json:"apiVersion"`
@@ -20,6 +29,51 @@ type ClaimTemplateMetadata struct {
 	Title       string   `yaml:"title,omitempty" json:"title,omitempty"`
 	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
 	Tags        []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
+	// Category groups templates, e.g. team-a/databases; the templates
+	// directory sets it to the subdirectory of the file
+	Category string `yaml:"category,omitempty" json:"category,omitempty"`
+
+	// Labels are arbitrary key/value pairs used for filtering
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
+	// Version of the template (semver, e.g. 1.2.0); several versions of the
+	// same name can be served side by side
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+
+	// Default marks the version served when no version is requested
+	// (otherwise the latest version is used)
+	Default bool `yaml:"default,omitempty" json:"default,omitempty"`
+
+	// Lifecycle state: experimental | stable | deprecated
+	Lifecycle string `yaml:"lifecycle,omitempty" json:"lifecycle,omitempty"`
+
+	// ReplacedBy names the template that supersedes a deprecated one
+	ReplacedBy string `yaml:"replacedBy,omitempty" json:"replacedBy,omitempty"`
+
+	// Sunset is the date (YYYY-MM-DD or RFC3339) after which a deprecated
+	// template is retired
+	Sunset string `yaml:"sunset,omitempty" json:"sunset,omitempty"`
+
+	// Origin is set by loaders of OCI, Git and Kubernetes sources
+	Origin *Origin `yaml:"-" json:"origin,omitempty"`
+}
+
+// Origin records the artifact, commit or resource a template was loaded from
+type Origin struct {
+	// Type is oci, git or kubernetes
+	Type string `json:"type"`
+
+	// URL is the artifact reference, repository URL or resource URL
+	URL string `json:"url"`
+	Ref string `json:"ref,omitempty"`
+
+	// Revision is the artifact digest, commit SHA or resource version
+	Revision string `json:"revision"`
+
+	// Path is the file within the artifact or repository, or the
+	// namespace/name of the resource
+	Path string `json:"path"`
 }
 
 type ClaimTemplateSpec struct {
@@ -27,13 +81,22 @@ type ClaimTemplateSpec struct {
 	Source     string      `yaml:"source" json:"source"`
 	Tag        string      `yaml:"tag,omitempty" json:"tag,omitempty"`
 	Parameters []Parameter `yaml:"parameters" json:"parameters"`
+
+	// RequiresApproval holds orders as pending until an approver signs off
+	RequiresApproval bool `yaml:"requiresApproval,omitempty" json:"requiresApproval,omitempty"`
+
+	// OrderName is a Go template for order names, e.g. "{{ .namespace }}-{{ .template }}-{{ .shortId }}"
+	OrderName string `yaml:"orderName,omitempty" json:"orderName,omitempty"`
+
+	// Members are the templates ordered together by a ClaimBundle
+	Members []BundleMember `yaml:"members,omitempty" json:"members,omitempty"`
 }
 
 type Parameter struct {
 	Name        string      `yaml:"name" json:"name"`
 	Title       string      `yaml:"title" json:"title"`
 	Description string      `yaml:"description,omitempty" json:"description,omitempty"`
-	Type        string      `yaml:"type" json:"type"` // string | boolean | array | number
+	Type        string      `yaml:"type" json:"type"` // string | boolean | array | number | password
 	Default     interface{} `yaml:"default,omitempty" json:"default,omitempty"`
 	Required    bool        `yaml:"required,omitempty" json:"required,omitempty"`
 	Enum        []string    `yaml:"enum,omitempty" json:"enum,omitempty"`
@@ -46,8 +109,62 @@ type Parameter struct {
 	// Only applies to parameters with enum values
 	AllowRandom bool `yaml:"allowRandom,omitempty" json:"allowRandom,omitempty"`
 
+	// Generate is a Go template evaluated server-side when the caller does not
+	// provide a value, e.g. "{{ .template }}-{{ randAlpha 5 }}" or "{{ password 24 }}"
+	Generate string `yaml:"generate,omitempty" json:"generate,omitempty"`
+
+	// Secret values are redacted in logs, debug output, history and errors
+	Secret bool `yaml:"secret,omitempty" json:"secret,omitempty"`
+
+	// SecretRef writes the secret value into a separate manifest and passes
+	// the Secret name to KCL instead of the plaintext value
+	SecretRef *SecretRef `yaml:"secretRef,omitempty" json:"secretRef,omitempty"`
+
 	// Validation
 	Pattern   string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
 	MinLength *int   `yaml:"minLength,omitempty" json:"minLength,omitempty"`
 	MaxLength *int   `yaml:"maxLength,omitempty" json:"maxLength,omitempty"`
 }
+
+// SecretRef describes the manifest a secret parameter value is written to
+type SecretRef struct {
+	// Kind is Secret (default, value stored in stringData) or ExternalSecret
+	// (the provided value is the remote key in the external secret store)
+	Kind string `yaml:"kind,omitempty" json:"kind,omitempty"`
+
+	// Name and Namespace of the target Secret, Go templates like generate
+	Name      string `yaml:"name" json:"name"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Key within the Secret, defaults to the parameter name
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+
+	// SecretStore is the ClusterSecretStore used by ExternalSecret
+	SecretStore string `yaml:"secretStore,omitempty" json:"secretStore,omitempty"`
+}
+
+// IsSecret reports whether the parameter value must be masked in logs and history
+func (p Parameter) IsSecret() bool {
+	return p.Secret || p.Type == "password" || p.SecretRef != nil
+}
+
+// SecretParameterNames returns the names of all secret parameters
+func (t *ClaimTemplate) SecretParameterNames() []string {
+	var names []string
+	for _, p := range t.Spec.Parameters {
+		if p.IsSecret() {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// Hash returns a stable content hash of the template. The origin is left
+// out, so a new commit or artifact only changes templates it modified.
+func (t *ClaimTemplate) Hash() string {
+	c := *t
+	c.Metadata.Origin = nil
+	b, _ := json.Marshal(c)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
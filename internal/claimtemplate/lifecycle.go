@@ -0,0 +1,43 @@
+package claimtemplate
+
+import (
+	"fmt"
+	"time"
+)
+
+// Lifecycle states of a claim template
+const (
+	LifecycleExperimental = "experimental"
+	LifecycleStable       = "stable"
+	LifecycleDeprecated   = "deprecated"
+)
+
+// IsDeprecated reports whether the template is marked deprecated
+func (t *ClaimTemplate) IsDeprecated() bool {
+	return t.Metadata.Lifecycle == LifecycleDeprecated
+}
+
+// SunsetTime parses metadata.sunset. The boolean is false if no sunset is set.
+// A plain date refers to the start of that day in UTC.
+func (t *ClaimTemplate) SunsetTime() (time.Time, bool, error) {
+	if t.Metadata.Sunset == "" {
+		return time.Time{}, false, nil
+	}
+	if ts, err := time.Parse(time.DateOnly, t.Metadata.Sunset); err == nil {
+		return ts, true, nil
+	}
+	ts, err := time.Parse(time.RFC3339, t.Metadata.Sunset)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid sunset %q: expected YYYY-MM-DD or RFC3339", t.Metadata.Sunset)
+	}
+	return ts, true, nil
+}
+
+// IsSunset reports whether a deprecated template is past its sunset date
+func (t *ClaimTemplate) IsSunset(now time.Time) bool {
+	if !t.IsDeprecated() {
+		return false
+	}
+	ts, ok, err := t.SunsetTime()
+	return err == nil && ok && !now.Before(ts)
+}
@@ -0,0 +1,37 @@
+package claimtemplate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func TestLifecycle_IsSunset(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		lifecycle string
+		sunset    string
+		want      bool
+	}{
+		{name: "stable", lifecycle: claimtemplate.LifecycleStable, sunset: "2026-01-01", want: false},
+		{name: "deprecated without sunset", lifecycle: claimtemplate.LifecycleDeprecated, want: false},
+		{name: "deprecated before sunset", lifecycle: claimtemplate.LifecycleDeprecated, sunset: "2026-12-31", want: false},
+		{name: "deprecated after sunset", lifecycle: claimtemplate.LifecycleDeprecated, sunset: "2026-05-31", want: true},
+		{name: "rfc3339 sunset", lifecycle: claimtemplate.LifecycleDeprecated, sunset: "2026-06-01T11:00:00Z", want: true},
+		{name: "invalid sunset", lifecycle: claimtemplate.LifecycleDeprecated, sunset: "next year", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := &claimtemplate.ClaimTemplate{
+				Metadata: claimtemplate.ClaimTemplateMetadata{Lifecycle: tt.lifecycle, Sunset: tt.sunset},
+			}
+			require.Equal(t, tt.want, tmpl.IsSunset(now))
+		})
+	}
+}
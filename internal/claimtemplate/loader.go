@@ -1,6 +1,10 @@
 package claimtemplate
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -11,11 +15,70 @@ func LoadClaimTemplate(path string) (*ClaimTemplate, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ParseClaimTemplate(data)
+}
 
+// LoadClaimTemplates loads all templates of a multi-document YAML file
+func LoadClaimTemplates(path string) ([]*ClaimTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseClaimTemplates(data)
+}
+
+// ParseClaimTemplate parses a claim template or bundle from YAML
+func ParseClaimTemplate(data []byte) (*ClaimTemplate, error) {
 	var tmpl ClaimTemplate
 	if err := yaml.Unmarshal(data, &tmpl); err != nil {
 		return nil, err
 	}
 
+	if err := tmpl.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &tmpl, nil
 }
+
+// Validate checks the fields the API relies on after loading: the member
+// list of bundles and the sunset date
+func (t *ClaimTemplate) Validate() error {
+	if t.IsBundle() {
+		if err := t.ValidateBundle(); err != nil {
+			return err
+		}
+	}
+	if _, _, err := t.SunsetTime(); err != nil {
+		return fmt.Errorf("template %s: %w", t.Metadata.Name, err)
+	}
+	return nil
+}
+
+// ParseClaimTemplates parses the documents of a YAML stream separated by
+// "---"; empty documents are skipped
+func ParseClaimTemplates(data []byte) ([]*ClaimTemplate, error) {
+	var out []*ClaimTemplate
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return out, nil
+			}
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			continue
+		}
+
+		var tmpl ClaimTemplate
+		if err := doc.Decode(&tmpl); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		if err := tmpl.Validate(); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		out = append(out, &tmpl)
+	}
+}
@@ -23,3 +23,49 @@ func TestLoadClaimTemplate(t *testing.T) {
 	require.Equal(t, "string", param.Type)
 	require.Contains(t, param.Enum, "simple")
 }
+
+func TestParseClaimTemplates(t *testing.T) {
+	tmpls, err := claimtemplate.ParseClaimTemplates([]byte(`---
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: ClaimTemplate
+metadata:
+  name: volumeclaim
+spec:
+  type: kcl
+  source: oci://ghcr.io/stuttgart-things/claim-xplane-volumeclaim
+---
+---
+# comment only
+---
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: ClaimBundle
+metadata:
+  name: stack
+spec:
+  members:
+    - name: data
+      template: volumeclaim
+`))
+	require.NoError(t, err)
+	require.Len(t, tmpls, 2)
+	require.Equal(t, "volumeclaim", tmpls[0].Metadata.Name)
+	require.True(t, tmpls[1].IsBundle())
+
+	// Invalid documents fail the whole stream
+	_, err = claimtemplate.ParseClaimTemplates([]byte("kind: ClaimBundle\nmetadata:\n  name: empty\n---\nmetadata: [\n"))
+	require.ErrorContains(t, err, "document 0")
+}
+
+func TestParseClaimTemplate_InvalidSunset(t *testing.T) {
+	_, err := claimtemplate.ParseClaimTemplate([]byte(`kind: ClaimTemplate
+metadata:
+  name: volumeclaim
+  lifecycle: deprecated
+  sunset: next year
+`))
+	require.ErrorContains(t, err, `invalid sunset "next year"`)
+
+	tmpl, err := claimtemplate.ParseClaimTemplate([]byte("kind: ClaimTemplate\nmetadata:\n  name: volumeclaim\n  sunset: 2026-12-31\n"))
+	require.NoError(t, err)
+	require.Equal(t, "2026-12-31", tmpl.Metadata.Sunset)
+}
@@ -0,0 +1,235 @@
+package claimtemplate
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Change types reported when the registry content changes
+const (
+	ChangeAdded   = "added"
+	ChangeUpdated = "updated"
+	ChangeRemoved = "removed"
+)
+
+// Change describes a template that was added, updated or removed
+type Change struct {
+	Type     string         `json:"type"`
+	Name     string         `json:"name"`
+	Version  string         `json:"version"`
+	Template *ClaimTemplate `json:"template,omitempty"`
+}
+
+// registryEntry is a registered template with its content hash
+type registryEntry struct {
+	template *ClaimTemplate
+	hash     string
+	modified time.Time
+}
+
+// Registry holds claim templates by name and version. It is safe for
+// concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	templates map[string]map[string]*registryEntry
+	updated   time.Time
+}
+
+// NewRegistry creates a registry from a list of templates. Later entries
+// replace earlier ones with the same name and version.
+func NewRegistry(templates []*ClaimTemplate) *Registry {
+	r := &Registry{
+		templates: make(map[string]map[string]*registryEntry),
+		updated:   time.Now(),
+	}
+	for _, t := range templates {
+		r.add(t, r.updated)
+	}
+	return r
+}
+
+// Replace swaps the registry content for a new set of templates and returns
+// what changed. Unchanged templates keep their modification time.
+func (r *Registry) Replace(templates []*ClaimTemplate) []Change {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	seen := make(map[string]map[string]bool)
+	var changes []Change
+
+	for _, t := range templates {
+		if change, ok := r.add(t, now); ok {
+			changes = append(changes, change)
+		}
+		if seen[t.Metadata.Name] == nil {
+			seen[t.Metadata.Name] = make(map[string]bool)
+		}
+		seen[t.Metadata.Name][t.VersionKey()] = true
+	}
+
+	for name, versions := range r.templates {
+		for version, e := range versions {
+			if seen[name][version] {
+				continue
+			}
+			delete(versions, version)
+			changes = append(changes, Change{Type: ChangeRemoved, Name: name, Version: version, Template: e.template})
+		}
+		if len(versions) == 0 {
+			delete(r.templates, name)
+		}
+	}
+
+	if len(changes) > 0 {
+		r.updated = now
+	}
+	return changes
+}
+
+// add registers a template and reports the change, if any
+func (r *Registry) add(t *ClaimTemplate, now time.Time) (Change, bool) {
+	versions, ok := r.templates[t.Metadata.Name]
+	if !ok {
+		versions = make(map[string]*registryEntry)
+		r.templates[t.Metadata.Name] = versions
+	}
+
+	hash := t.Hash()
+	change := Change{Type: ChangeAdded, Name: t.Metadata.Name, Version: t.VersionKey(), Template: t}
+	if existing, ok := versions[t.VersionKey()]; ok {
+		if existing.hash == hash {
+			existing.template = t
+			return Change{}, false
+		}
+		change.Type = ChangeUpdated
+	}
+
+	versions[t.VersionKey()] = &registryEntry{template: t, hash: hash, modified: now}
+	return change, true
+}
+
+// Get returns the default version of a template.
+// The default is the version marked with metadata.default, otherwise the latest.
+func (r *Registry) Get(name string) (*ClaimTemplate, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e := defaultVersion(r.templates[name])
+	if e == nil {
+		return nil, false
+	}
+	return e.template, true
+}
+
+// GetVersion returns a specific version of a template; "latest" resolves to
+// the highest release, or the highest pre-release if there is no release.
+func (r *Registry) GetVersion(name, version string) (*ClaimTemplate, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.templates[name]
+	if version == LatestVersion {
+		e := latestVersion(versions)
+		if e == nil {
+			return nil, false
+		}
+		return e.template, true
+	}
+	e, ok := versions[NormalizeVersion(version)]
+	if !ok {
+		return nil, false
+	}
+	return e.template, true
+}
+
+// Versions returns all versions of a template, newest first
+func (r *Registry) Versions(name string) []*ClaimTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := sortedVersions(r.templates[name])
+	out := make([]*ClaimTemplate, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.template)
+	}
+	return out
+}
+
+// List returns the default version of every template, sorted by name
+func (r *Registry) List() []*ClaimTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]*ClaimTemplate, 0, len(r.templates))
+	for _, versions := range r.templates {
+		if e := defaultVersion(versions); e != nil {
+			out = append(out, e.template)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Metadata.Name < out[j].Metadata.Name
+	})
+	return out
+}
+
+// ModifiedAt returns when a template version was last added or changed
+func (r *Registry) ModifiedAt(name, version string) time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if e, ok := r.templates[name][NormalizeVersion(version)]; ok {
+		return e.modified
+	}
+	return time.Time{}
+}
+
+// Updated returns when the set of templates last changed
+func (r *Registry) Updated() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.updated
+}
+
+// sortedVersions returns versions ordered from newest to oldest
+func sortedVersions(versions map[string]*registryEntry) []*registryEntry {
+	out := make([]*registryEntry, 0, len(versions))
+	for _, e := range versions {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].template.VersionKey(), out[j].template.VersionKey()
+		if c := CompareVersions(a, b); c != 0 {
+			return c > 0
+		}
+		// Keys that compare equal (build metadata) still sort stably
+		return a > b
+	})
+	return out
+}
+
+// latestVersion returns the highest release; pre-releases are only picked
+// if a template has no release
+func latestVersion(versions map[string]*registryEntry) *registryEntry {
+	sorted := sortedVersions(versions)
+	if len(sorted) == 0 {
+		return nil
+	}
+	for _, e := range sorted {
+		if !IsPrerelease(e.template.VersionKey()) {
+			return e
+		}
+	}
+	return sorted[0]
+}
+
+func defaultVersion(versions map[string]*registryEntry) *registryEntry {
+	for _, e := range sortedVersions(versions) {
+		if e.template.Metadata.Default {
+			return e
+		}
+	}
+	return latestVersion(versions)
+}
@@ -0,0 +1,154 @@
+package claimtemplate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+func versioned(name, version string, def bool) *claimtemplate.ClaimTemplate {
+	return &claimtemplate.ClaimTemplate{
+		Metadata: claimtemplate.ClaimTemplateMetadata{Name: name, Version: version, Default: def},
+	}
+}
+
+func TestRegistry_Versions(t *testing.T) {
+	reg := claimtemplate.NewRegistry([]*claimtemplate.ClaimTemplate{
+		versioned("volumeclaim", "1.2.0", false),
+		versioned("volumeclaim", "1.10.0", false),
+		versioned("volumeclaim", "2.0.0-rc.1", false),
+		versioned("postgresql", "", false),
+	})
+
+	versions := reg.Versions("volumeclaim")
+	require.Len(t, versions, 3)
+	require.Equal(t, "2.0.0-rc.1", versions[0].Metadata.Version)
+	require.Equal(t, "1.10.0", versions[1].Metadata.Version)
+	require.Equal(t, "1.2.0", versions[2].Metadata.Version)
+
+	// Pre-releases are listed but not the latest version or the default
+	latest, ok := reg.GetVersion("volumeclaim", claimtemplate.LatestVersion)
+	require.True(t, ok)
+	require.Equal(t, "1.10.0", latest.Metadata.Version)
+	def, ok := reg.Get("volumeclaim")
+	require.True(t, ok)
+	require.Equal(t, "1.10.0", def.Metadata.Version)
+
+	// Unless there is no release
+	reg = claimtemplate.NewRegistry([]*claimtemplate.ClaimTemplate{
+		versioned("volumeclaim", "2.0.0-rc.1", false),
+		versioned("volumeclaim", "2.0.0-beta.1", false),
+		versioned("postgresql", "", false),
+	})
+	latest, ok = reg.GetVersion("volumeclaim", claimtemplate.LatestVersion)
+	require.True(t, ok)
+	require.Equal(t, "2.0.0-rc.1", latest.Metadata.Version)
+
+	unversioned, ok := reg.GetVersion("postgresql", claimtemplate.UnversionedVersion)
+	require.True(t, ok)
+	require.Equal(t, "postgresql", unversioned.Metadata.Name)
+
+	_, ok = reg.GetVersion("volumeclaim", "9.9.9")
+	require.False(t, ok)
+}
+
+func TestRegistry_DefaultVersion(t *testing.T) {
+	reg := claimtemplate.NewRegistry([]*claimtemplate.ClaimTemplate{
+		versioned("volumeclaim", "1.0.0", false),
+		versioned("volumeclaim", "2.0.0", false),
+	})
+
+	def, ok := reg.Get("volumeclaim")
+	require.True(t, ok)
+	require.Equal(t, "2.0.0", def.Metadata.Version, "latest is the default")
+
+	reg.Replace([]*claimtemplate.ClaimTemplate{
+		versioned("volumeclaim", "1.0.0", true),
+		versioned("volumeclaim", "2.0.0", false),
+	})
+	def, _ = reg.Get("volumeclaim")
+	require.Equal(t, "1.0.0", def.Metadata.Version, "explicit default wins")
+
+	list := reg.List()
+	require.Len(t, list, 1)
+	require.Equal(t, "1.0.0", list[0].Metadata.Version)
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"1.0.0+build.5", "1.0.0+build.7", 0},
+		{"1.0.0-rc.1+build.5", "1.0.0", -1},
+		{"1.0.0-rc.1+build.5", "1.0.0-rc.1", 0},
+		{"1.2.0+20240101", "1.10.0", -1},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, claimtemplate.CompareVersions(tt.a, tt.b), "%s vs %s", tt.a, tt.b)
+	}
+}
+
+func TestRegistry_Replace(t *testing.T) {
+	reg := claimtemplate.NewRegistry([]*claimtemplate.ClaimTemplate{
+		versioned("volumeclaim", "1.0.0", false),
+		versioned("postgresql", "1.0.0", false),
+	})
+
+	updated := versioned("volumeclaim", "1.0.0", false)
+	updated.Metadata.Title = "changed"
+
+	changes := reg.Replace([]*claimtemplate.ClaimTemplate{
+		updated,
+		versioned("harborproject", "1.0.0", false),
+	})
+
+	byName := make(map[string]string)
+	for _, c := range changes {
+		byName[c.Name] = c.Type
+	}
+	require.Equal(t, map[string]string{
+		"volumeclaim":   claimtemplate.ChangeUpdated,
+		"harborproject": claimtemplate.ChangeAdded,
+		"postgresql":    claimtemplate.ChangeRemoved,
+	}, byName)
+
+	// Replacing with identical content reports no changes
+	require.Empty(t, reg.Replace([]*claimtemplate.ClaimTemplate{
+		updated,
+		versioned("harborproject", "1.0.0", false),
+	}))
+}
+
+func TestRegistry_NormalizedVersions(t *testing.T) {
+	reg := claimtemplate.NewRegistry([]*claimtemplate.ClaimTemplate{
+		versioned("volumeclaim", "1.0", false),
+		versioned("volumeclaim", "v1.0.0", false),
+		versioned("volumeclaim", "1.1.0", false),
+	})
+
+	// Equal versions share one key, the later template replaces the earlier
+	require.Len(t, reg.Versions("volumeclaim"), 2)
+
+	for _, version := range []string{"1.0", "1.0.0", "v1.0.0", "1.00.0"} {
+		tmpl, ok := reg.GetVersion("volumeclaim", version)
+		require.True(t, ok, version)
+		require.Equal(t, "v1.0.0", tmpl.Metadata.Version)
+	}
+
+	require.Equal(t, "1.0.0", claimtemplate.NormalizeVersion("v1"))
+	require.Equal(t, "1.0.0-rc.1", claimtemplate.NormalizeVersion("v1.0-rc.1"))
+	require.Equal(t, "latest", claimtemplate.NormalizeVersion("latest"))
+	require.Equal(t, "nightly", claimtemplate.NormalizeVersion("nightly"))
+}
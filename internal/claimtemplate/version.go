@@ -0,0 +1,117 @@
+package claimtemplate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// UnversionedVersion is the version key of templates without metadata.version
+const UnversionedVersion = "0.0.0"
+
+// LatestVersion is an alias resolving to the highest version of a template
+const LatestVersion = "latest"
+
+// VersionKey returns the normalized version a template is registered under
+func (t *ClaimTemplate) VersionKey() string {
+	if t.Metadata.Version == "" {
+		return UnversionedVersion
+	}
+	return NormalizeVersion(t.Metadata.Version)
+}
+
+// NormalizeVersion returns the canonical form of a version, so that versions
+// comparing equal share a key: the leading "v" is dropped and numeric
+// versions are padded to three segments (v1.0 becomes 1.0.0). Other
+// versions are returned unchanged.
+func NormalizeVersion(v string) string {
+	if v == LatestVersion {
+		return v
+	}
+	core, suffix := strings.TrimPrefix(v, "v"), ""
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core, suffix = core[:i], core[i:]
+	}
+	segments := strings.Split(core, ".")
+	if len(segments) > 3 {
+		return v
+	}
+	for i, s := range segments {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return v
+		}
+		segments[i] = strconv.Itoa(n)
+	}
+	for len(segments) < 3 {
+		segments = append(segments, "0")
+	}
+	return strings.Join(segments, ".") + suffix
+}
+
+// CompareVersions compares two semver-like versions and returns -1, 0 or 1.
+// A leading "v" and build metadata (+build) are ignored, numeric segments
+// are compared numerically and a pre-release (1.0.0-rc.1) sorts before its
+// release.
+func CompareVersions(a, b string) int {
+	a, aPre := splitVersion(a)
+	b, bPre := splitVersion(b)
+
+	if c := compareSegments(strings.Split(a, "."), strings.Split(b, "."), "0"); c != 0 {
+		return c
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return compareSegments(strings.Split(aPre, "."), strings.Split(bPre, "."), "")
+}
+
+// IsPrerelease reports whether a version is a pre-release such as 1.0.0-rc.1
+func IsPrerelease(v string) bool {
+	_, pre := splitVersion(v)
+	return pre != ""
+}
+
+// splitVersion returns the core and pre-release of a version without the
+// leading "v" and build metadata
+func splitVersion(v string) (string, string) {
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "+")
+	core, pre, _ := strings.Cut(v, "-")
+	return core, pre
+}
+
+// compareSegments compares dot-separated segments, filling missing ones with pad
+func compareSegments(a, b []string, pad string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		x, y := pad, pad
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn != yn {
+				if xn < yn {
+					return -1
+				}
+				return 1
+			}
+		case x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Client reads resources from the Kubernetes API server
+type Client struct {
+	server    string
+	http      *http.Client
+	token     string
+	tokenFile string
+}
+
+// NewClient creates a client for the API server of a config. It has no
+// overall timeout, since watches are long-running requests.
+func NewClient(cfg *Config) (*Client, error) {
+	if cfg.Server == "" {
+		return nil, errors.New("kubernetes config without server")
+	}
+	// InsecureSkipVerify is only set by insecure-skip-tls-verify in a kubeconfig
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.Insecure}
+	if len(cfg.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.CAData) {
+			return nil, errors.New("kubernetes config: invalid CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(cfg.CertData) > 0 {
+		cert, err := tls.X509KeyPair(cfg.CertData, cfg.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes config: client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Client{
+		server:    strings.TrimSuffix(cfg.Server, "/"),
+		http:      &http.Client{Transport: transport},
+		token:     cfg.Token,
+		tokenFile: cfg.TokenFile,
+	}, nil
+}
+
+// Server returns the URL of the API server
+func (c *Client) Server() string {
+	return c.server
+}
+
+// StatusError is a failure reported by the API server
+type StatusError struct {
+	Code    int
+	Reason  string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("kubernetes api: %d %s", e.Code, http.StatusText(e.Code))
+	}
+	return fmt.Sprintf("kubernetes api: %d %s", e.Code, e.Message)
+}
+
+// isGone reports whether a watch must list again because its resource
+// version is too old
+func isGone(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusGone
+}
+
+// status is the Status object returned for failures
+type status struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (s status) err() *StatusError {
+	return &StatusError{Code: s.Code, Reason: s.Reason, Message: s.Message}
+}
+
+// get requests an API path; the caller closes the body of the response
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	token := c.token
+	if c.tokenFile != "" {
+		b, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read token: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var s status
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if json.Unmarshal(body, &s) != nil || s.Code == 0 {
+			s = status{Code: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+		}
+		return nil, s.err()
+	}
+	return resp, nil
+}
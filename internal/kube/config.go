@@ -0,0 +1,179 @@
+package kube
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// serviceAccountDir holds the credentials of the pod's service account
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Config tells the client how to reach and authenticate to the API server
+type Config struct {
+	Server string
+
+	// Token is a bearer token; TokenFile is read on every request, since
+	// service account tokens are rotated
+	Token     string
+	TokenFile string
+
+	// CAData verifies the server certificate (default: system roots)
+	CAData   []byte
+	Insecure bool
+
+	// CertData and KeyData are a client certificate
+	CertData []byte
+	KeyData  []byte
+}
+
+// InClusterConfig uses the service account of the pod the server runs in
+func InClusterConfig() (*Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("read service account CA: %w", err)
+	}
+	return &Config{
+		Server:    "https://" + net.JoinHostPort(host, port),
+		TokenFile: filepath.Join(serviceAccountDir, "token"),
+		CAData:    ca,
+	}, nil
+}
+
+// kubeconfig is the part of a kubeconfig file the client supports
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server   string `yaml:"server"`
+			CAData   string `yaml:"certificate-authority-data"`
+			CAFile   string `yaml:"certificate-authority"`
+			Insecure bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token     string `yaml:"token"`
+			TokenFile string `yaml:"tokenFile"`
+			CertData  string `yaml:"client-certificate-data"`
+			CertFile  string `yaml:"client-certificate"`
+			KeyData   string `yaml:"client-key-data"`
+			KeyFile   string `yaml:"client-key"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// LoadKubeconfig reads the current context of a kubeconfig file. Tokens and
+// client certificates are supported, exec and auth provider plugins are not.
+func LoadKubeconfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read kubeconfig: %w", err)
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("parse kubeconfig %s: %w", path, err)
+	}
+
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == kc.CurrentContext {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("kubeconfig %s: current context %q not found", path, kc.CurrentContext)
+	}
+
+	cfg := &Config{}
+	dir := filepath.Dir(path)
+	found = false
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		cfg.Server = strings.TrimSuffix(c.Cluster.Server, "/")
+		cfg.Insecure = c.Cluster.Insecure
+		if cfg.CAData, err = dataOrFile(c.Cluster.CAData, c.Cluster.CAFile, dir); err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: cluster %s: %w", path, clusterName, err)
+		}
+		break
+	}
+	if !found || cfg.Server == "" {
+		return nil, fmt.Errorf("kubeconfig %s: cluster %q not found", path, clusterName)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		cfg.Token = u.User.Token
+		if u.User.TokenFile != "" {
+			cfg.TokenFile = resolvePath(u.User.TokenFile, dir)
+		}
+		if cfg.CertData, err = dataOrFile(u.User.CertData, u.User.CertFile, dir); err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: user %s: %w", path, userName, err)
+		}
+		if cfg.KeyData, err = dataOrFile(u.User.KeyData, u.User.KeyFile, dir); err != nil {
+			return nil, fmt.Errorf("kubeconfig %s: user %s: %w", path, userName, err)
+		}
+		break
+	}
+	return cfg, nil
+}
+
+// DefaultConfig uses the first file of $KUBECONFIG, the pod's service
+// account when running in a cluster, or ~/.kube/config
+func DefaultConfig() (*Config, error) {
+	if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 && paths[0] != "" {
+		return LoadKubeconfig(paths[0])
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return InClusterConfig()
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return LoadKubeconfig(filepath.Join(home, ".kube", "config"))
+}
+
+// dataOrFile decodes base64 data or reads a file relative to the kubeconfig
+func dataOrFile(data, file, dir string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(resolvePath(file, dir))
+	}
+	return nil, nil
+}
+
+func resolvePath(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,75 @@
+package kube
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKubeconfig(t *testing.T) {
+	server := &fakeAPIServer{resources: map[string]ClaimTemplate{"team-a/volumeclaim": resource("team-a", "volumeclaim", "1.0.0")}}
+	server.Server = httptest.NewTLSServer(http.HandlerFunc(server.serve))
+	t.Cleanup(server.Close)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("s3cret\n"), 0o600))
+	path := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(path, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+contexts:
+  - name: prod
+    context: {cluster: prod, user: admin}
+  - name: dev
+    context: {cluster: dev, user: dev}
+clusters:
+  - name: prod
+    cluster: {server: https://prod.example.com}
+  - name: dev
+    cluster:
+      server: `+server.URL+`/
+      certificate-authority-data: `+base64.StdEncoding.EncodeToString(ca)+`
+users:
+  - name: admin
+    user: {token: admin}
+  - name: dev
+    user: {tokenFile: token}
+`), 0o600))
+
+	cfg, err := LoadKubeconfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL, cfg.Server)
+	assert.Equal(t, filepath.Join(dir, "token"), cfg.TokenFile)
+	assert.Equal(t, ca, cfg.CAData)
+
+	// The server certificate is verified against the CA, the token file is sent
+	client, err := NewClient(cfg)
+	require.NoError(t, err)
+	_, err = NewWatcher(client, nil).list(context.Background())
+	require.NoError(t, err)
+
+	// Without the CA the server is not trusted
+	client, err = NewClient(&Config{Server: server.URL, Token: "s3cret"})
+	require.NoError(t, err)
+	_, err = NewWatcher(client, nil).list(context.Background())
+	assert.Error(t, err)
+
+	t.Setenv("KUBECONFIG", path+string(filepath.ListSeparator)+filepath.Join(dir, "other"))
+	cfg, err = DefaultConfig()
+	require.NoError(t, err)
+	assert.Equal(t, server.URL, cfg.Server)
+
+	// Unknown contexts
+	require.NoError(t, os.WriteFile(path, []byte("current-context: missing\n"), 0o600))
+	_, err = LoadKubeconfig(path)
+	assert.Error(t, err)
+}
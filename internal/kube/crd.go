@@ -0,0 +1,164 @@
+package kube
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaProps is the OpenAPI v3 subset of CRD schemas the generator emits
+type schemaProps struct {
+	Type                  string                  `yaml:"type,omitempty"`
+	Properties            map[string]*schemaProps `yaml:"properties,omitempty"`
+	AdditionalProperties  *schemaProps            `yaml:"additionalProperties,omitempty"`
+	Items                 *schemaProps            `yaml:"items,omitempty"`
+	Required              []string                `yaml:"required,omitempty"`
+	PreserveUnknownFields bool                    `yaml:"x-kubernetes-preserve-unknown-fields,omitempty"`
+}
+
+type printerColumn struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	JSONPath string `yaml:"jsonPath"`
+}
+
+type crdVersion struct {
+	Name    string `yaml:"name"`
+	Served  bool   `yaml:"served"`
+	Storage bool   `yaml:"storage"`
+	Schema  struct {
+		OpenAPIV3Schema *schemaProps `yaml:"openAPIV3Schema"`
+	} `yaml:"schema"`
+	AdditionalPrinterColumns []printerColumn `yaml:"additionalPrinterColumns"`
+}
+
+type crdNames struct {
+	Kind       string   `yaml:"kind"`
+	ListKind   string   `yaml:"listKind"`
+	Plural     string   `yaml:"plural"`
+	Singular   string   `yaml:"singular"`
+	ShortNames []string `yaml:"shortNames"`
+}
+
+type customResourceDefinition struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Group    string       `yaml:"group"`
+		Names    crdNames     `yaml:"names"`
+		Scope    string       `yaml:"scope"`
+		Versions []crdVersion `yaml:"versions"`
+	} `yaml:"spec"`
+}
+
+// CRD returns the CustomResourceDefinition manifest of ClaimTemplates. The
+// spec schema is generated from the Go types, so it follows new template
+// fields without changes here.
+func CRD() ([]byte, error) {
+	spec := schemaOf(reflect.TypeOf(ClaimTemplateSpec{}))
+	spec.Properties["parameters"].Items.Required = []string{"name", "type"}
+	spec.Properties["members"].Items.Required = []string{"name", "template"}
+
+	v := crdVersion{Name: Version, Served: true, Storage: true}
+	v.Schema.OpenAPIV3Schema = &schemaProps{
+		Type: "object",
+		Properties: map[string]*schemaProps{
+			"apiVersion": {Type: "string"},
+			"kind":       {Type: "string"},
+			"metadata":   {Type: "object"},
+			"spec":       spec,
+		},
+		Required: []string{"spec"},
+	}
+	v.AdditionalPrinterColumns = []printerColumn{
+		{Name: "Version", Type: "string", JSONPath: ".spec.version"},
+		{Name: "Type", Type: "string", JSONPath: ".spec.type"},
+		{Name: "Lifecycle", Type: "string", JSONPath: ".spec.lifecycle"},
+		{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+	}
+
+	var crd customResourceDefinition
+	crd.APIVersion = "apiextensions.k8s.io/v1"
+	crd.Kind = "CustomResourceDefinition"
+	crd.Metadata.Name = Resource + "." + Group
+	crd.Spec.Group = Group
+	crd.Spec.Names = crdNames{
+		Kind:       "ClaimTemplate",
+		ListKind:   "ClaimTemplateList",
+		Plural:     Resource,
+		Singular:   "claimtemplate",
+		ShortNames: []string{"ctpl"},
+	}
+	crd.Spec.Scope = "Namespaced"
+	crd.Spec.Versions = []crdVersion{v}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(crd); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return append([]byte("---\n"), buf.Bytes()...), nil
+}
+
+// schemaOf derives the schema of a Go type from its json field names
+func schemaOf(t reflect.Type) *schemaProps {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return &schemaProps{Type: "string"}
+	case reflect.Bool:
+		return &schemaProps{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schemaProps{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &schemaProps{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &schemaProps{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return &schemaProps{Type: "object", PreserveUnknownFields: true}
+		}
+		return &schemaProps{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case reflect.Struct:
+		s := &schemaProps{Type: "object", Properties: map[string]*schemaProps{}}
+		addFields(s, t)
+		return s
+	default:
+		// interface{} values such as parameter defaults take any type
+		return &schemaProps{PreserveUnknownFields: true}
+	}
+}
+
+// addFields adds the fields of a struct; embedded structs without a json
+// name are inlined like encoding/json does
+func addFields(s *schemaProps, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(s, f.Type)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = schemaOf(f.Type)
+	}
+}
@@ -0,0 +1,65 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/schema"
+)
+
+func TestCRD(t *testing.T) {
+	crd, err := CRD()
+	require.NoError(t, err)
+
+	v, err := schema.NewValidator(crd)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sthings.io/v1alpha1/ClaimTemplate"}, v.Kinds())
+
+	docs, err := manifest.Split(`apiVersion: sthings.io/v1alpha1
+kind: ClaimTemplate
+metadata:
+  name: volumeclaim
+  namespace: team-a
+spec:
+  title: Volume Claim
+  version: 1.2.0
+  lifecycle: stable
+  type: kcl
+  source: oci://ghcr.io/stuttgart-things/claim-xplane-volumeclaim
+  parameters:
+    - name: size
+      title: Size
+      type: string
+      default: 10Gi
+    - name: replicas
+      title: Replicas
+      type: number
+      default: 2
+      minLength: 1
+      secretRef:
+        name: db
+`)
+	require.NoError(t, err)
+	assert.Empty(t, v.Validate(docs))
+
+	docs, err = manifest.Split(`apiVersion: sthings.io/v1alpha1
+kind: ClaimTemplate
+metadata:
+  name: volumeclaim
+spec:
+  type: kcl
+  sorce: oci://ghcr.io/stuttgart-things/claim-xplane-volumeclaim
+  parameters:
+    - title: Size
+      hidden: "yes"
+`)
+	require.NoError(t, err)
+	var fields []string
+	for _, violation := range v.Validate(docs) {
+		fields = append(fields, violation.Field)
+	}
+	assert.ElementsMatch(t, []string{"spec.sorce", "spec.parameters[0].name", "spec.parameters[0].type", "spec.parameters[0].hidden"}, fields)
+}
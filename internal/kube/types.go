@@ -0,0 +1,97 @@
+package kube
+
+import (
+	"sort"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// Group, version and resource of ClaimTemplate custom resources
+const (
+	Group    = "sthings.io"
+	Version  = "v1alpha1"
+	Resource = "claimtemplates"
+)
+
+// APIVersion of ClaimTemplate custom resources
+const APIVersion = Group + "/" + Version
+
+// ClaimTemplate is a claim template managed as a Kubernetes custom resource.
+// The API server keeps only standard object metadata, so the catalog
+// metadata of template files (title, version, ...) moves into the spec.
+type ClaimTemplate struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Spec       ClaimTemplateSpec `json:"spec"`
+}
+
+// ObjectMeta is the part of the object metadata the loader uses
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// ClaimTemplateSpec is the template spec with the catalog metadata
+type ClaimTemplateSpec struct {
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Default     bool     `json:"default,omitempty"`
+	Lifecycle   string   `json:"lifecycle,omitempty"`
+	ReplacedBy  string   `json:"replacedBy,omitempty"`
+	Sunset      string   `json:"sunset,omitempty"`
+
+	claimtemplate.ClaimTemplateSpec `json:",inline"`
+}
+
+// key identifies a resource across namespaces
+func (c *ClaimTemplate) key() string {
+	return c.Metadata.Namespace + "/" + c.Metadata.Name
+}
+
+// Template converts the resource to a claim template named after the
+// resource. Resources with members are served as ClaimBundles.
+func (c *ClaimTemplate) Template(server string) *claimtemplate.ClaimTemplate {
+	kind := claimtemplate.KindClaimTemplate
+	if len(c.Spec.Members) > 0 {
+		kind = claimtemplate.KindClaimBundle
+	}
+	path := "namespaces/" + c.Metadata.Namespace + "/" + Resource + "/" + c.Metadata.Name
+	return &claimtemplate.ClaimTemplate{
+		APIVersion: APIVersion,
+		Kind:       kind,
+		Metadata: claimtemplate.ClaimTemplateMetadata{
+			Name:        c.Metadata.Name,
+			Title:       c.Spec.Title,
+			Description: c.Spec.Description,
+			Tags:        c.Spec.Tags,
+			Labels:      c.Metadata.Labels,
+			Version:     c.Spec.Version,
+			Default:     c.Spec.Default,
+			Lifecycle:   c.Spec.Lifecycle,
+			ReplacedBy:  c.Spec.ReplacedBy,
+			Sunset:      c.Spec.Sunset,
+			Origin: &claimtemplate.Origin{
+				Type:     "kubernetes",
+				URL:      server + "/apis/" + APIVersion + "/" + path,
+				Revision: c.Metadata.ResourceVersion,
+				Path:     c.Metadata.Namespace + "/" + c.Metadata.Name,
+			},
+		},
+		Spec: c.Spec.ClaimTemplateSpec,
+	}
+}
+
+// sortedKeys returns the keys of resources in namespace/name order
+func sortedKeys(resources map[string]*ClaimTemplate) []string {
+	keys := make([]string, 0, len(resources))
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,195 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// resourcePath lists ClaimTemplates of all namespaces
+const resourcePath = "/apis/" + APIVersion + "/" + Resource
+
+// Watcher keeps the ClaimTemplate resources of all namespaces in sync and
+// reports the resulting templates after every change
+type Watcher struct {
+	client   *Client
+	onChange func([]*claimtemplate.ClaimTemplate)
+
+	// RetryDelay is the wait before listing or watching again after a failure
+	RetryDelay time.Duration
+
+	mu        sync.Mutex
+	resources map[string]*ClaimTemplate
+}
+
+// NewWatcher creates a watcher calling onChange with all templates after
+// the initial list and every added, modified or deleted resource
+func NewWatcher(client *Client, onChange func([]*claimtemplate.ClaimTemplate)) *Watcher {
+	return &Watcher{
+		client:     client,
+		onChange:   onChange,
+		RetryDelay: 5 * time.Second,
+		resources:  make(map[string]*ClaimTemplate),
+	}
+}
+
+// Templates returns the templates of the current resources. When resources
+// in several namespaces declare the same name and version, the first
+// namespace in alphabetical order wins.
+func (w *Watcher) Templates() []*claimtemplate.ClaimTemplate {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seen := make(map[string]string, len(w.resources))
+	out := make([]*claimtemplate.ClaimTemplate, 0, len(w.resources))
+	for _, key := range sortedKeys(w.resources) {
+		t := w.resources[key].Template(w.client.Server())
+		if err := t.Validate(); err != nil {
+			log.Printf("⚠️  ClaimTemplate %s: %v (skipping)", key, err)
+			continue
+		}
+		id := t.Metadata.Name + "@" + t.VersionKey()
+		if first, ok := seen[id]; ok {
+			log.Printf("⚠️  ClaimTemplate %s duplicates %s of %s (skipping)", key, id, first)
+			continue
+		}
+		seen[id] = key
+		out = append(out, t)
+	}
+	return out
+}
+
+// Run lists and watches ClaimTemplates until the context is cancelled.
+// Failures are logged and retried; expired watches list again.
+func (w *Watcher) Run(ctx context.Context) error {
+	var resourceVersion string
+	for {
+		var err error
+		if resourceVersion == "" {
+			resourceVersion, err = w.list(ctx)
+		}
+		if err == nil {
+			resourceVersion, err = w.watch(ctx, resourceVersion)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			// The server closed the watch, resume it
+			continue
+		}
+		if isGone(err) {
+			resourceVersion = ""
+			continue
+		}
+
+		log.Printf("⚠️  watching ClaimTemplates failed: %v (retrying in %s)", err, w.RetryDelay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.RetryDelay):
+		}
+	}
+}
+
+// list replaces all resources and returns the resource version to watch from
+func (w *Watcher) list(ctx context.Context) (string, error) {
+	resp, err := w.client.get(ctx, resourcePath, nil)
+	if err != nil {
+		return "", fmt.Errorf("list %s: %w", Resource, err)
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []ClaimTemplate `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("list %s: %w", Resource, err)
+	}
+
+	resources := make(map[string]*ClaimTemplate, len(list.Items))
+	for i := range list.Items {
+		resources[list.Items[i].key()] = &list.Items[i]
+	}
+	w.mu.Lock()
+	w.resources = resources
+	w.mu.Unlock()
+	w.notify()
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watchEvent is a line of a watch stream
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// watch applies events until the stream ends and returns the last resource
+// version seen
+func (w *Watcher) watch(ctx context.Context, resourceVersion string) (string, error) {
+	resp, err := w.client.get(ctx, resourcePath, url.Values{
+		"watch":               {"true"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+	})
+	if err != nil {
+		return resourceVersion, fmt.Errorf("watch %s: %w", Resource, err)
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev watchEvent
+		if err := dec.Decode(&ev); err != nil {
+			if errors.Is(err, io.EOF) {
+				return resourceVersion, nil
+			}
+			return resourceVersion, fmt.Errorf("watch %s: %w", Resource, err)
+		}
+
+		if ev.Type == "ERROR" {
+			var s status
+			if err := json.Unmarshal(ev.Object, &s); err != nil {
+				return resourceVersion, fmt.Errorf("watch %s: %w", Resource, err)
+			}
+			return resourceVersion, s.err()
+		}
+
+		var obj ClaimTemplate
+		if err := json.Unmarshal(ev.Object, &obj); err != nil {
+			return resourceVersion, fmt.Errorf("watch %s: %w", Resource, err)
+		}
+		resourceVersion = obj.Metadata.ResourceVersion
+
+		w.mu.Lock()
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			w.resources[obj.key()] = &obj
+		case "DELETED":
+			delete(w.resources, obj.key())
+		default:
+			// BOOKMARK only advances the resource version
+			w.mu.Unlock()
+			continue
+		}
+		w.mu.Unlock()
+		w.notify()
+	}
+}
+
+func (w *Watcher) notify() {
+	if w.onChange != nil {
+		w.onChange(w.Templates())
+	}
+}
@@ -0,0 +1,203 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+)
+
+// fakeAPIServer serves list and watch requests for ClaimTemplates
+type fakeAPIServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	rv        int
+	resources map[string]ClaimTemplate
+	lists     int
+	events    chan watchEvent
+}
+
+func newFakeAPIServer(t *testing.T) *fakeAPIServer {
+	t.Helper()
+	s := &fakeAPIServer{resources: make(map[string]ClaimTemplate), events: make(chan watchEvent, 16)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeAPIServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer s3cret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"kind":"Status","code":401,"reason":"Unauthorized","message":"Unauthorized"}`))
+		return
+	}
+	if r.URL.Path != resourcePath {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.URL.Query().Get("watch") != "true" {
+		s.mu.Lock()
+		s.lists++
+		list := map[string]interface{}{
+			"metadata": map[string]string{"resourceVersion": strconv.Itoa(s.rv)},
+			"items":    mapValues(s.resources),
+		}
+		s.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-s.events:
+			_ = json.NewEncoder(w).Encode(ev)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func mapValues(m map[string]ClaimTemplate) []ClaimTemplate {
+	out := make([]ClaimTemplate, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	return out
+}
+
+// apply stores a resource and sends the event to the watch
+func (s *fakeAPIServer) apply(eventType string, c ClaimTemplate) {
+	s.mu.Lock()
+	s.rv++
+	c.APIVersion, c.Kind = APIVersion, "ClaimTemplate"
+	c.Metadata.ResourceVersion = strconv.Itoa(s.rv)
+	if eventType == "DELETED" {
+		delete(s.resources, c.key())
+	} else {
+		s.resources[c.key()] = c
+	}
+	s.mu.Unlock()
+	obj, _ := json.Marshal(c)
+	s.events <- watchEvent{Type: eventType, Object: obj}
+}
+
+func resource(namespace, name, version string) ClaimTemplate {
+	c := ClaimTemplate{Metadata: ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"team": namespace}}}
+	c.Spec.Title = "Claim " + name
+	c.Spec.Version = version
+	c.Spec.Type = "kcl"
+	c.Spec.Source = "oci://ghcr.io/stuttgart-things/claim-xplane-" + name
+	return c
+}
+
+func names(templates []*claimtemplate.ClaimTemplate) []string {
+	out := make([]string, len(templates))
+	for i, t := range templates {
+		out[i] = t.Metadata.Name + "@" + t.VersionKey()
+	}
+	return out
+}
+
+func TestWatcher(t *testing.T) {
+	server := newFakeAPIServer(t)
+	server.resources["team-a/volumeclaim"] = resource("team-a", "volumeclaim", "1.0.0")
+
+	client, err := NewClient(&Config{Server: server.URL, Token: "s3cret"})
+	require.NoError(t, err)
+
+	updates := make(chan []*claimtemplate.ClaimTemplate, 16)
+	w := NewWatcher(client, func(templates []*claimtemplate.ClaimTemplate) { updates <- templates })
+	w.RetryDelay = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	next := func() []string {
+		t.Helper()
+		select {
+		case templates := <-updates:
+			return names(templates)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no template update")
+			return nil
+		}
+	}
+
+	// Initial list
+	assert.Equal(t, []string{"volumeclaim@1.0.0"}, next())
+	tmpl := w.Templates()[0]
+	assert.Equal(t, "Claim volumeclaim", tmpl.Metadata.Title)
+	assert.Equal(t, claimtemplate.KindClaimTemplate, tmpl.Kind)
+	assert.Equal(t, map[string]string{"team": "team-a"}, tmpl.Metadata.Labels)
+	assert.Equal(t, "oci://ghcr.io/stuttgart-things/claim-xplane-volumeclaim", tmpl.Spec.Source)
+	assert.Equal(t, &claimtemplate.Origin{
+		Type: "kubernetes",
+		URL:  server.URL + "/apis/sthings.io/v1alpha1/namespaces/team-a/claimtemplates/volumeclaim",
+		Path: "team-a/volumeclaim",
+	}, tmpl.Metadata.Origin)
+
+	// Live updates
+	server.apply("ADDED", resource("team-b", "harborproject", "0.1.0"))
+	assert.Equal(t, []string{"volumeclaim@1.0.0", "harborproject@0.1.0"}, next())
+	server.apply("MODIFIED", resource("team-a", "volumeclaim", "1.1.0"))
+	assert.Equal(t, []string{"volumeclaim@1.1.0", "harborproject@0.1.0"}, next())
+	assert.Equal(t, "2", w.Templates()[0].Metadata.Origin.Revision)
+
+	// Duplicates in later namespaces are skipped
+	server.apply("ADDED", resource("team-c", "volumeclaim", "1.1.0"))
+	assert.Equal(t, []string{"volumeclaim@1.1.0", "harborproject@0.1.0"}, next())
+	server.apply("DELETED", resource("team-a", "volumeclaim", "1.1.0"))
+	assert.Equal(t, []string{"harborproject@0.1.0", "volumeclaim@1.1.0"}, next())
+	assert.Equal(t, "team-c/volumeclaim", w.Templates()[1].Metadata.Origin.Path)
+
+	// Bundles
+	bundle := resource("team-a", "stack", "1.0.0")
+	bundle.Spec.Members = []claimtemplate.BundleMember{{Name: "data", Template: "volumeclaim"}}
+	server.apply("ADDED", bundle)
+	assert.Equal(t, []string{"stack@1.0.0", "harborproject@0.1.0", "volumeclaim@1.1.0"}, next())
+	assert.Equal(t, claimtemplate.KindClaimBundle, w.Templates()[0].Kind)
+
+	// Bookmarks change nothing, expired watches list again
+	server.events <- watchEvent{Type: "BOOKMARK", Object: json.RawMessage(`{"metadata":{"resourceVersion":"7"}}`)}
+	server.events <- watchEvent{Type: "ERROR", Object: json.RawMessage(`{"kind":"Status","code":410,"reason":"Expired"}`)}
+	assert.Equal(t, []string{"stack@1.0.0", "harborproject@0.1.0", "volumeclaim@1.1.0"}, next())
+	server.mu.Lock()
+	assert.Equal(t, 2, server.lists)
+	server.mu.Unlock()
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not stop")
+	}
+}
+
+func TestWatcher_Unauthorized(t *testing.T) {
+	server := newFakeAPIServer(t)
+	client, err := NewClient(&Config{Server: server.URL, Token: "wrong"})
+	require.NoError(t, err)
+
+	w := NewWatcher(client, nil)
+	_, err = w.list(context.Background())
+	var se *StatusError
+	require.ErrorAs(t, err, &se)
+	assert.Equal(t, http.StatusUnauthorized, se.Code)
+	assert.Equal(t, "Unauthorized", se.Reason)
+}
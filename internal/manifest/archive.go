@@ -0,0 +1,133 @@
+package manifest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Archive formats
+const (
+	ArchiveTar = "tar"
+	ArchiveZip = "zip"
+)
+
+// File is a file of a manifest archive
+type File struct {
+	Name string
+	Data []byte
+}
+
+// kustomization is the kustomization.yaml of an archive
+type kustomization struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Resources  []string `yaml:"resources"`
+}
+
+// Files returns one file per document, named <index>-<kind>-<name>.yaml so
+// the order of the stream is kept. With withKustomization a
+// kustomization.yaml listing all files is added.
+func Files(docs []Document, withKustomization bool) ([]File, error) {
+	files := make([]File, 0, len(docs)+1)
+	names := make([]string, 0, len(docs))
+	for i, d := range docs {
+		name := fileName(i, d)
+		names = append(names, name)
+		files = append(files, File{Name: name, Data: []byte(strings.TrimRight(d.Raw, "\n") + "\n")})
+	}
+
+	if withKustomization {
+		data, err := yaml.Marshal(kustomization{
+			APIVersion: "kustomize.config.k8s.io/v1beta1",
+			Kind:       "Kustomization",
+			Resources:  names,
+		})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: "kustomization.yaml", Data: data})
+	}
+	return files, nil
+}
+
+// WriteArchive writes files below dir into a tar or zip archive
+func WriteArchive(w io.Writer, format, dir string, files []File) error {
+	switch format {
+	case ArchiveTar:
+		return writeTar(w, dir, files)
+	case ArchiveZip:
+		return writeZip(w, dir, files)
+	default:
+		return fmt.Errorf("unsupported archive format %q: use tar or zip", format)
+	}
+}
+
+func writeTar(w io.Writer, dir string, files []File) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    path.Join(dir, f.Name),
+			Mode:    0o644,
+			Size:    int64(len(f.Data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeZip(w io.Writer, dir string, files []File) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     path.Join(dir, f.Name),
+			Method:   zip.Deflate,
+			Modified: now,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// fileName builds a file name from the document's kind and name
+func fileName(index int, d Document) string {
+	parts := []string{fmt.Sprintf("%02d", index)}
+	for _, s := range []string{d.Kind(), d.Name()} {
+		if s = slug(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "-") + ".yaml"
+}
+
+// slug lowercases s and replaces everything but alphanumerics with dashes
+func slug(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
@@ -0,0 +1,73 @@
+package manifest
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deleteLast lists kinds that are deleted after all other objects, as
+// deleting them removes the objects inside them
+var deleteLast = map[string]bool{
+	"Namespace":                true,
+	"CustomResourceDefinition": true,
+}
+
+// ObjectRef identifies an object of a rendered stream
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// DeletionOrder returns references to the named objects of documents in
+// the order they should be deleted: reverse render order, with namespaces
+// and CRDs last
+func DeletionOrder(docs []Document) []ObjectRef {
+	var refs, last []ObjectRef
+	for i := len(docs) - 1; i >= 0; i-- {
+		d := docs[i]
+		if d.Name() == "" || d.Kind() == "" {
+			continue
+		}
+		ref := ObjectRef{APIVersion: d.APIVersion(), Kind: d.Kind(), Namespace: d.Namespace(), Name: d.Name()}
+		if deleteLast[ref.Kind] {
+			last = append(last, ref)
+		} else {
+			refs = append(refs, ref)
+		}
+	}
+	return append(refs, last...)
+}
+
+// DeletionManifest writes references as a multi-document stream of
+// minimal objects, e.g. for kubectl delete -f
+func DeletionManifest(refs []ObjectRef) (string, error) {
+	parts := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		metadata := map[string]string{"name": ref.Name}
+		if ref.Namespace != "" {
+			metadata["namespace"] = ref.Namespace
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(map[string]interface{}{
+			"apiVersion": ref.APIVersion,
+			"kind":       ref.Kind,
+			"metadata":   metadata,
+		}); err != nil {
+			return "", err
+		}
+		if err := enc.Close(); err != nil {
+			return "", err
+		}
+		parts = append(parts, strings.TrimRight(buf.String(), "\n"))
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	return strings.Join(parts, "\n---\n") + "\n", nil
+}
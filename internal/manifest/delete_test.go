@@ -0,0 +1,38 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletionOrder(t *testing.T) {
+	docs, err := Split(testStream + "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: team-a\n---\nkind: List\n")
+	require.NoError(t, err)
+
+	refs := DeletionOrder(docs)
+	assert.Equal(t, []ObjectRef{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "team-a", Name: "settings"},
+		{APIVersion: "resources.stuttgart-things.com/v1alpha1", Kind: "VolumeClaim", Namespace: "team-a", Name: "data"},
+		{APIVersion: "v1", Kind: "Namespace", Name: "team-a"},
+	}, refs)
+
+	out, err := DeletionManifest(refs[1:])
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data
+  namespace: team-a
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+`, out)
+
+	out, err = DeletionManifest(nil)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
@@ -0,0 +1,307 @@
+package manifest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Change types of documents and fields
+const (
+	ChangeAdded     = "added"
+	ChangeRemoved   = "removed"
+	ChangeModified  = "modified"
+	ChangeUnchanged = "unchanged"
+)
+
+// maxDiffCells bounds the line matrix of Unified; larger changes are shown
+// as a replacement of the changed region
+const maxDiffCells = 4 << 20
+
+// FieldChange is a changed field of a document
+type FieldChange struct {
+	// Path addresses the field, e.g. spec.ports[0] or metadata.labels["app.kubernetes.io/name"]
+	Path string      `json:"path"`
+	Type string      `json:"type"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// DocumentDiff compares a document of two renders. Documents are matched
+// by apiVersion, kind, namespace and name.
+type DocumentDiff struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Name       string        `json:"name,omitempty"`
+	Status     string        `json:"status"`
+	Changes    []FieldChange `json:"changes,omitempty"`
+}
+
+// Diff compares two sets of documents. Results follow the order of the new
+// documents; removed documents come last.
+func Diff(old, new []Document) []DocumentDiff {
+	oldByKey := make(map[string]Document, len(old))
+	for i, d := range old {
+		oldByKey[d.key(i)] = d
+	}
+
+	var out []DocumentDiff
+	seen := make(map[string]bool, len(new))
+	for i, d := range new {
+		key := d.key(i)
+		seen[key] = true
+		dd := d.diffHeader()
+
+		prev, ok := oldByKey[key]
+		if !ok {
+			dd.Status = ChangeAdded
+			out = append(out, dd)
+			continue
+		}
+		diffValues("", prev.Object, d.Object, &dd.Changes)
+		dd.Status = ChangeUnchanged
+		if len(dd.Changes) > 0 {
+			dd.Status = ChangeModified
+		}
+		out = append(out, dd)
+	}
+
+	for i, d := range old {
+		if !seen[d.key(i)] {
+			dd := d.diffHeader()
+			dd.Status = ChangeRemoved
+			out = append(out, dd)
+		}
+	}
+	return out
+}
+
+// key identifies a document across renders; unnamed documents are matched
+// by position
+func (d Document) key(i int) string {
+	if d.Name() == "" {
+		return fmt.Sprintf("#%d", i)
+	}
+	return strings.Join([]string{d.APIVersion(), d.Kind(), d.Namespace(), d.Name()}, "/")
+}
+
+func (d Document) diffHeader() DocumentDiff {
+	return DocumentDiff{APIVersion: d.APIVersion(), Kind: d.Kind(), Namespace: d.Namespace(), Name: d.Name()}
+}
+
+func diffValues(path string, old, new interface{}, out *[]FieldChange) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(o)+len(n))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, ok := o[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			p := fieldPath(path, k)
+			switch {
+			case !inOld:
+				*out = append(*out, FieldChange{Path: p, Type: ChangeAdded, New: nv})
+			case !inNew:
+				*out = append(*out, FieldChange{Path: p, Type: ChangeRemoved, Old: ov})
+			default:
+				diffValues(p, ov, nv, out)
+			}
+		}
+		return
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(o) || i < len(n); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(o):
+				*out = append(*out, FieldChange{Path: p, Type: ChangeAdded, New: n[i]})
+			case i >= len(n):
+				*out = append(*out, FieldChange{Path: p, Type: ChangeRemoved, Old: o[i]})
+			default:
+				diffValues(p, o[i], n[i], out)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		*out = append(*out, FieldChange{Path: path, Type: ChangeModified, Old: old, New: new})
+	}
+}
+
+// plainKey matches keys that can be written with dot notation
+var plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func fieldPath(path, key string) string {
+	if !plainKey.MatchString(key) {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Unified returns a unified diff of two texts with the given number of
+// context lines. It is empty if the texts are equal.
+func Unified(oldName, newName, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change and the extent of its hunk
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		from := max(first-context, start)
+		end := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i
+			} else if i-end > 2*context {
+				break
+			}
+		}
+		to := min(end+context+1, len(ops))
+
+		oldStart, newStart := ops[from].oldLine, ops[from].newLine
+		oldCount, newCount := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, op := range ops[from:to] {
+			buf.WriteByte(op.kind)
+			buf.WriteString(op.text)
+			buf.WriteByte('\n')
+		}
+		start = to
+	}
+	return buf.String()
+}
+
+// lineOp is a line of an edit script: ' ' (kept), '-' (removed) or '+' (added)
+type lineOp struct {
+	kind             byte
+	text             string
+	oldLine, newLine int
+}
+
+// diffLines computes an edit script from the longest common subsequence of
+// the lines, after trimming the common prefix and suffix
+func diffLines(a, b []string) []lineOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	var ops []lineOp
+	i, j := 0, 0
+	emit := func(kind byte, text string) {
+		ops = append(ops, lineOp{kind: kind, text: text, oldLine: i + 1, newLine: j + 1})
+		if kind != '+' {
+			i++
+		}
+		if kind != '-' {
+			j++
+		}
+	}
+
+	for _, line := range a[:prefix] {
+		emit(' ', line)
+	}
+	if len(ma)*len(mb) > maxDiffCells {
+		for _, line := range ma {
+			emit('-', line)
+		}
+		for _, line := range mb {
+			emit('+', line)
+		}
+	} else {
+		// lcs[x][y] is the LCS length of ma[x:] and mb[y:]
+		lcs := make([][]int, len(ma)+1)
+		for x := range lcs {
+			lcs[x] = make([]int, len(mb)+1)
+		}
+		for x := len(ma) - 1; x >= 0; x-- {
+			for y := len(mb) - 1; y >= 0; y-- {
+				if ma[x] == mb[y] {
+					lcs[x][y] = lcs[x+1][y+1] + 1
+				} else {
+					lcs[x][y] = max(lcs[x+1][y], lcs[x][y+1])
+				}
+			}
+		}
+		x, y := 0, 0
+		for x < len(ma) || y < len(mb) {
+			switch {
+			case x < len(ma) && y < len(mb) && ma[x] == mb[y]:
+				emit(' ', ma[x])
+				x, y = x+1, y+1
+			case y < len(mb) && (x == len(ma) || lcs[x][y+1] > lcs[x+1][y]):
+				emit('+', mb[y])
+				y++
+			default:
+				emit('-', ma[x])
+				x++
+			}
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		emit(' ', line)
+	}
+	return ops
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,122 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	diffOld = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data
+  namespace: team-a
+  labels:
+    app.kubernetes.io/name: data
+spec:
+  storage: 10Gi
+  storageClassName: standard
+  accessModes:
+    - ReadWriteOnce
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: team-a
+`
+	diffNew = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data
+  namespace: team-a
+  labels:
+    app.kubernetes.io/name: data-v2
+spec:
+  storage: 20Gi
+  accessModes:
+    - ReadWriteOnce
+    - ReadOnlyMany
+  volumeMode: Filesystem
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+  namespace: team-a
+`
+)
+
+func TestDiff(t *testing.T) {
+	old, err := Split(diffOld)
+	require.NoError(t, err)
+	new, err := Split(diffNew)
+	require.NoError(t, err)
+
+	diffs := Diff(old, new)
+	require.Len(t, diffs, 4)
+
+	assert.Equal(t, DocumentDiff{APIVersion: "v1", Kind: "Namespace", Name: "team-a", Status: ChangeUnchanged}, diffs[0])
+	assert.Equal(t, ChangeModified, diffs[1].Status)
+	assert.Equal(t, []FieldChange{
+		{Path: `metadata.labels["app.kubernetes.io/name"]`, Type: ChangeModified, Old: "data", New: "data-v2"},
+		{Path: "spec.accessModes[1]", Type: ChangeAdded, New: "ReadOnlyMany"},
+		{Path: "spec.storage", Type: ChangeModified, Old: "10Gi", New: "20Gi"},
+		{Path: "spec.storageClassName", Type: ChangeRemoved, Old: "standard"},
+		{Path: "spec.volumeMode", Type: ChangeAdded, New: "Filesystem"},
+	}, diffs[1].Changes)
+	assert.Equal(t, "Secret", diffs[2].Kind)
+	assert.Equal(t, ChangeAdded, diffs[2].Status)
+	assert.Equal(t, "ConfigMap", diffs[3].Kind)
+	assert.Equal(t, ChangeRemoved, diffs[3].Status)
+}
+
+func TestUnified(t *testing.T) {
+	assert.Empty(t, Unified("a", "b", diffOld, diffOld, 3))
+
+	assert.Equal(t, `--- previous
++++ proposed
+@@ -9,15 +9,16 @@
+   name: data
+   namespace: team-a
+   labels:
+-    app.kubernetes.io/name: data
++    app.kubernetes.io/name: data-v2
+ spec:
+-  storage: 10Gi
+-  storageClassName: standard
++  storage: 20Gi
+   accessModes:
+     - ReadWriteOnce
++    - ReadOnlyMany
++  volumeMode: Filesystem
+ ---
+ apiVersion: v1
+-kind: ConfigMap
++kind: Secret
+ metadata:
+-  name: settings
++  name: credentials
+   namespace: team-a
+`, Unified("previous", "proposed", diffOld, diffNew, 3))
+
+	// Distant changes get separate hunks
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "A\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n"
+	assert.Equal(t, "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n@@ -9,2 +9,2 @@\n i\n-j\n+J\n", Unified("a", "b", a, b, 1))
+
+	// Additions to an empty text
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n", Unified("a", "b", "", "x\ny\n", 3))
+}
@@ -0,0 +1,142 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a single manifest of a multi-document YAML stream
+type Document struct {
+	// Raw is the document text as rendered, without the separator
+	Raw string
+
+	// Object is the parsed document
+	Object map[string]interface{}
+}
+
+// Split parses a multi-document YAML stream. Empty and comment-only
+// documents are skipped.
+func Split(stream string) ([]Document, error) {
+	var docs []Document
+	for i, raw := range splitRaw(stream) {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(raw), &obj); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+		if obj == nil {
+			continue
+		}
+		docs = append(docs, Document{Raw: raw, Object: obj})
+	}
+	return docs, nil
+}
+
+// Join writes documents back into a multi-document YAML stream
+func Join(docs []Document) string {
+	parts := make([]string, len(docs))
+	for i, d := range docs {
+		parts[i] = strings.TrimRight(d.Raw, "\n")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "\n---\n") + "\n"
+}
+
+// splitRaw cuts a stream at "---" separator lines
+func splitRaw(stream string) []string {
+	var (
+		out []string
+		cur strings.Builder
+	)
+	scanner := bufio.NewScanner(strings.NewReader(stream))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isSeparator(line) {
+			out = append(out, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteString("\n")
+	}
+	return append(out, cur.String())
+}
+
+func isSeparator(line string) bool {
+	if !strings.HasPrefix(line, "---") {
+		return false
+	}
+	rest := strings.TrimSpace(line[3:])
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
+
+// APIVersion returns the apiVersion of the document
+func (d Document) APIVersion() string {
+	s, _ := d.Object["apiVersion"].(string)
+	return s
+}
+
+// Kind returns the kind of the document
+func (d Document) Kind() string {
+	s, _ := d.Object["kind"].(string)
+	return s
+}
+
+// Name returns metadata.name of the document
+func (d Document) Name() string {
+	return d.metadata("name")
+}
+
+// Namespace returns metadata.namespace of the document
+func (d Document) Namespace() string {
+	return d.metadata("namespace")
+}
+
+func (d Document) metadata(field string) string {
+	meta, _ := d.Object["metadata"].(map[string]interface{})
+	s, _ := meta[field].(string)
+	return s
+}
+
+// Transform applies fn to the parsed node of every non-empty document of a
+// stream. Documents fn reports as unchanged, as well as empty and
+// comment-only ones, are kept verbatim; changed documents are re-encoded
+// with their comments. Document order is preserved.
+func Transform(stream string, fn func(doc *yaml.Node) (bool, error)) (string, error) {
+	parts := splitRaw(stream)
+	for i, raw := range parts {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(raw), &node); err != nil {
+			return "", fmt.Errorf("document %d: %w", i+1, err)
+		}
+		if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+
+		changed, err := fn(node.Content[0])
+		if err != nil {
+			return "", fmt.Errorf("document %d: %w", i+1, err)
+		}
+		if !changed {
+			continue
+		}
+
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return "", fmt.Errorf("document %d: %w", i+1, err)
+		}
+		if err := enc.Close(); err != nil {
+			return "", fmt.Errorf("document %d: %w", i+1, err)
+		}
+		parts[i] = buf.String()
+	}
+	return strings.Join(parts, "---\n"), nil
+}
@@ -0,0 +1,129 @@
+package manifest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testStream = `---
+# namespace for the team
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+--- # the claim
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data
+  namespace: team-a
+---
+# only a comment
+`
+
+func TestSplit(t *testing.T) {
+	docs, err := Split(testStream)
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+
+	assert.Equal(t, "Namespace", docs[0].Kind())
+	assert.Equal(t, "team-a", docs[0].Name())
+	assert.Equal(t, "v1", docs[0].APIVersion())
+	assert.Contains(t, docs[0].Raw, "# namespace for the team")
+	assert.Equal(t, "team-a", docs[1].Namespace())
+
+	_, err = Split("a: [1\n---\nb: 2\n")
+	assert.ErrorContains(t, err, "document 1")
+}
+
+func TestJoin(t *testing.T) {
+	docs, err := Split(testStream)
+	require.NoError(t, err)
+
+	again, err := Split(Join(docs))
+	require.NoError(t, err)
+	assert.Equal(t, docs, again)
+}
+
+func TestTransform(t *testing.T) {
+	out, err := Transform(testStream, func(doc *yaml.Node) (bool, error) {
+		var obj struct {
+			Kind string `yaml:"kind"`
+		}
+		require.NoError(t, doc.Decode(&obj))
+		if obj.Kind != "VolumeClaim" {
+			return false, nil
+		}
+		doc.Content = append(doc.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "spec"},
+			&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: "storage"},
+				{Kind: yaml.ScalarNode, Value: "10Gi"},
+			}},
+		)
+		return true, nil
+	})
+	require.NoError(t, err)
+
+	// Unchanged and comment-only documents are kept as they are
+	assert.Equal(t, `---
+# namespace for the team
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data
+  namespace: team-a
+spec:
+  storage: 10Gi
+---
+# only a comment
+`, out)
+
+	_, err = Transform("a: [1\n", func(*yaml.Node) (bool, error) { return false, nil })
+	assert.ErrorContains(t, err, "document 1")
+}
+
+func TestWriteArchive(t *testing.T) {
+	docs, err := Split(testStream)
+	require.NoError(t, err)
+	files, err := Files(docs, true)
+	require.NoError(t, err)
+
+	names := []string{"00-namespace-team-a.yaml", "01-volumeclaim-data.yaml", "kustomization.yaml"}
+	require.Len(t, files, 3)
+	for i, f := range files {
+		assert.Equal(t, names[i], f.Name)
+	}
+	assert.Contains(t, string(files[2].Data), "- 00-namespace-team-a.yaml")
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteArchive(&buf, ArchiveTar, "order-1", files))
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "order-1/00-namespace-team-a.yaml", hdr.Name)
+	data, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "kind: Namespace")
+
+	buf.Reset()
+	require.NoError(t, WriteArchive(&buf, ArchiveZip, "order-1", files))
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 3)
+	assert.Equal(t, "order-1/kustomization.yaml", zr.File[2].Name)
+
+	assert.Error(t, WriteArchive(&buf, "rar", "order-1", files))
+}
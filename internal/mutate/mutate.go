@@ -0,0 +1,373 @@
+package mutate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"gopkg.in/yaml.v3"
+)
+
+// clusterScoped lists well-known kinds that never get a namespace
+var clusterScoped = map[string]bool{
+	"Namespace":                      true,
+	"Node":                           true,
+	"CustomResourceDefinition":       true,
+	"APIService":                     true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"PersistentVolume":               true,
+	"StorageClass":                   true,
+	"CSIDriver":                      true,
+	"IngressClass":                   true,
+	"PriorityClass":                  true,
+	"RuntimeClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"MutatingWebhookConfiguration":   true,
+}
+
+// Config is the mutation configuration file
+type Config struct {
+	Mutations []Mutation `yaml:"mutations"`
+
+	// ClusterScopedKinds adds kinds that never get a namespace, e.g.
+	// cluster-scoped CRDs when no schema source is configured
+	ClusterScopedKinds []string `yaml:"clusterScopedKinds,omitempty"`
+}
+
+// ScopeFunc reports whether a kind is cluster-scoped. The second value is
+// false if the scope of the kind is unknown.
+type ScopeFunc func(apiVersion, kind string) (bool, bool)
+
+// Mutation adds labels and annotations to rendered objects and optionally
+// forces their namespace. Values are Go templates over .order and .object.
+type Mutation struct {
+	Name string `yaml:"name"`
+
+	// Match restricts the mutation to templates and kinds (empty = all)
+	Match Match `yaml:"match,omitempty"`
+
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+
+	// Namespace is set on all namespaced objects, replacing any namespace
+	// the template rendered
+	Namespace string `yaml:"namespace,omitempty"`
+
+	labels      map[string]*template.Template
+	annotations map[string]*template.Template
+	namespace   *template.Template
+}
+
+// Match selects the objects a mutation applies to
+type Match struct {
+	Templates []string `yaml:"templates,omitempty"`
+	Kinds     []string `yaml:"kinds,omitempty"`
+}
+
+// LoadConfig reads and validates a mutation configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mutation config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse mutation config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the configuration and parses the value templates
+func (c *Config) Validate() error {
+	for i := range c.Mutations {
+		m := &c.Mutations[i]
+		if m.Name == "" {
+			m.Name = fmt.Sprintf("mutation-%d", i)
+		}
+		if len(m.Labels) == 0 && len(m.Annotations) == 0 && m.Namespace == "" {
+			return fmt.Errorf("mutation %s: labels, annotations or namespace is required", m.Name)
+		}
+
+		var err error
+		if m.labels, err = parseAll(m.Name, "label", m.Labels); err != nil {
+			return err
+		}
+		if m.annotations, err = parseAll(m.Name, "annotation", m.Annotations); err != nil {
+			return err
+		}
+		if m.Namespace != "" {
+			if m.namespace, err = parse(m.Namespace); err != nil {
+				return fmt.Errorf("mutation %s: namespace: %w", m.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func parseAll(mutation, what string, values map[string]string) (map[string]*template.Template, error) {
+	out := make(map[string]*template.Template, len(values))
+	for key, value := range values {
+		if key == "" {
+			return nil, fmt.Errorf("mutation %s: empty %s key", mutation, what)
+		}
+		tpl, err := parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("mutation %s: %s %s: %w", mutation, what, key, err)
+		}
+		out[key] = tpl
+	}
+	return out, nil
+}
+
+func parse(text string) (*template.Template, error) {
+	return template.New("value").Funcs(template.FuncMap{
+		"lower":   strings.ToLower,
+		"upper":   strings.ToUpper,
+		"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"default": func(def string, v interface{}) string {
+			if s := valueString(v); s != "" {
+				return s
+			}
+			return def
+		},
+	}).Parse(text)
+}
+
+func (m Mutation) matches(template, kind string) bool {
+	return matchAny(m.Match.Templates, template) && matchAny(m.Match.Kinds, kind)
+}
+
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Mutator applies mutations to rendered orders
+type Mutator struct {
+	mutations     []Mutation
+	clusterScoped map[string]bool
+	scope         ScopeFunc
+}
+
+// NewMutator creates a mutator for a validated configuration
+func NewMutator(cfg *Config) *Mutator {
+	kinds := make(map[string]bool, len(clusterScoped)+len(cfg.ClusterScopedKinds))
+	for kind := range clusterScoped {
+		kinds[kind] = true
+	}
+	for _, kind := range cfg.ClusterScopedKinds {
+		kinds[kind] = true
+	}
+	return &Mutator{mutations: cfg.Mutations, clusterScoped: kinds}
+}
+
+// SetScope looks up the scope of kinds, e.g. from CRDs. Kinds it does not
+// know fall back to the cluster-scoped kind list.
+func (m *Mutator) SetScope(scope ScopeFunc) {
+	m.scope = scope
+}
+
+// isClusterScoped reports whether a document never gets a namespace
+func (m *Mutator) isClusterScoped(d manifest.Document) bool {
+	if m.scope != nil {
+		if clusterScoped, ok := m.scope(d.APIVersion(), d.Kind()); ok {
+			return clusterScoped
+		}
+	}
+	return m.clusterScoped[d.Kind()]
+}
+
+// Len returns the number of mutations
+func (m *Mutator) Len() int {
+	return len(m.mutations)
+}
+
+// Apply mutates the rendered output of an order. Only changed documents are
+// re-encoded; others keep their formatting. Values that render empty are
+// skipped.
+func (m *Mutator) Apply(o *order.Order, rendered string) (string, error) {
+	orderData := map[string]interface{}{
+		"uid":        o.UID,
+		"name":       o.Name,
+		"template":   o.Template,
+		"version":    o.TemplateVersion,
+		"requester":  o.Requester,
+		"requestId":  o.RequestID,
+		"parameters": o.Parameters,
+	}
+
+	return manifest.Transform(rendered, func(doc *yaml.Node) (bool, error) {
+		var obj map[string]interface{}
+		if err := doc.Decode(&obj); err != nil {
+			return false, err
+		}
+		d := manifest.Document{Object: obj}
+		data := map[string]interface{}{"order": orderData, "object": obj}
+
+		changed := false
+		for _, mu := range m.mutations {
+			if !mu.matches(o.Template, d.Kind()) {
+				continue
+			}
+
+			labels, err := execAll(mu.labels, data)
+			if err != nil {
+				return false, fmt.Errorf("mutation %s: %w", mu.Name, err)
+			}
+			for k, v := range labels {
+				labels[k] = labelValue(v)
+			}
+			annotations, err := execAll(mu.annotations, data)
+			if err != nil {
+				return false, fmt.Errorf("mutation %s: %w", mu.Name, err)
+			}
+
+			if setFields(doc, []string{"metadata", "labels"}, labels) {
+				changed = true
+			}
+			if setFields(doc, []string{"metadata", "annotations"}, annotations) {
+				changed = true
+			}
+
+			if mu.namespace == nil || m.isClusterScoped(d) {
+				continue
+			}
+			ns, err := execute(mu.namespace, data)
+			if err != nil {
+				return false, fmt.Errorf("mutation %s: namespace: %w", mu.Name, err)
+			}
+			if ns != "" && setFields(doc, []string{"metadata"}, map[string]string{"namespace": ns}) {
+				changed = true
+			}
+		}
+		return changed, nil
+	})
+}
+
+func execAll(templates map[string]*template.Template, data interface{}) (map[string]string, error) {
+	out := make(map[string]string, len(templates))
+	for key, tpl := range templates {
+		value, err := execute(tpl, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if value != "" {
+			out[key] = value
+		}
+	}
+	return out, nil
+}
+
+func execute(tpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(buf.String())
+	if value == "<no value>" {
+		return "", nil
+	}
+	return value, nil
+}
+
+func valueString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// invalidLabelChars are characters not allowed in label values
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// labelValue makes s a valid label value: at most 63 characters of
+// alphanumerics, '-', '_' and '.', starting and ending alphanumeric
+func labelValue(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return strings.Trim(s, "-_.")
+}
+
+// setFields sets string values in the mapping at path below doc, creating
+// the path if needed. Keys are added in sorted order. It reports whether
+// anything changed.
+func setFields(doc *yaml.Node, path []string, values map[string]string) bool {
+	if len(values) == 0 {
+		return false
+	}
+	node := doc
+	for _, key := range path {
+		child := lookup(node, key)
+		if child == nil || child.Kind != yaml.MappingNode {
+			if child != nil {
+				// Replace null or scalar placeholders such as "labels: {}" or "labels:"
+				*child = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			} else {
+				child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+			}
+		}
+		node = child
+	}
+	if node.Style == yaml.FlowStyle {
+		node.Style = 0
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	changed := false
+	for _, k := range keys {
+		v := values[k]
+		if existing := lookup(node, k); existing != nil {
+			if existing.Kind == yaml.ScalarNode && existing.Value == v {
+				continue
+			}
+			*existing = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+		} else {
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v},
+			)
+		}
+		changed = true
+	}
+	return changed
+}
+
+// lookup returns the value node of key in a mapping node
+func lookup(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
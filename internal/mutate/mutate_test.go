@@ -0,0 +1,201 @@
+package mutate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+const testRendered = `# rendered by KCL
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data # the volume
+  namespace: default
+  labels:
+    app: data
+spec:
+  storage: 10Gi
+---
+# trailing comment
+`
+
+func testOrder() *order.Order {
+	return &order.Order{
+		UID:             "01J9ZK3M4N5P6Q7R8S9T0V1W2X",
+		Name:            "volumeclaim-data",
+		Template:        "volumeclaim",
+		TemplateVersion: "1.2.0",
+		Requester:       "alice@example.com",
+		Parameters:      map[string]interface{}{"team": "payments"},
+	}
+}
+
+func testMutator(t *testing.T, mutations ...Mutation) *Mutator {
+	t.Helper()
+	cfg := &Config{Mutations: mutations}
+	require.NoError(t, cfg.Validate())
+	return NewMutator(cfg)
+}
+
+func TestApply(t *testing.T) {
+	m := testMutator(t, Mutation{
+		Name: "ownership",
+		Labels: map[string]string{
+			"app.kubernetes.io/managed-by": "claim-machinery",
+			"owner":                        "{{ .order.requester }}",
+			"team":                         "{{ .order.parameters.team }}",
+			"cost-center":                  "{{ .order.parameters.costCenter }}",
+		},
+		Annotations: map[string]string{
+			"claim-machinery.io/order-id":         "{{ .order.uid }}",
+			"claim-machinery.io/requester":        "{{ .order.requester }}",
+			"claim-machinery.io/template-version": "{{ .order.template }}@{{ .order.version }}",
+		},
+	}, Mutation{
+		Name:      "namespace",
+		Match:     Match{Kinds: []string{"VolumeClaim"}},
+		Namespace: "team-{{ .order.parameters.team }}",
+	})
+
+	out, err := m.Apply(testOrder(), testRendered)
+	require.NoError(t, err)
+	assert.Equal(t, `# rendered by KCL
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+  labels:
+    app.kubernetes.io/managed-by: claim-machinery
+    owner: alice-example.com
+    team: payments
+  annotations:
+    claim-machinery.io/order-id: 01J9ZK3M4N5P6Q7R8S9T0V1W2X
+    claim-machinery.io/requester: alice@example.com
+    claim-machinery.io/template-version: volumeclaim@1.2.0
+---
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data # the volume
+  namespace: team-payments
+  labels:
+    app: data
+    app.kubernetes.io/managed-by: claim-machinery
+    owner: alice-example.com
+    team: payments
+  annotations:
+    claim-machinery.io/order-id: 01J9ZK3M4N5P6Q7R8S9T0V1W2X
+    claim-machinery.io/requester: alice@example.com
+    claim-machinery.io/template-version: volumeclaim@1.2.0
+spec:
+  storage: 10Gi
+---
+# trailing comment
+`, out)
+}
+
+func TestApply_Match(t *testing.T) {
+	m := testMutator(t, Mutation{
+		Match:     Match{Templates: []string{"postgres*"}},
+		Labels:    map[string]string{"db": "true"},
+		Namespace: "databases",
+	})
+
+	// Nothing matches, so the output is unchanged
+	out, err := m.Apply(testOrder(), testRendered)
+	require.NoError(t, err)
+	assert.Equal(t, testRendered, out)
+
+	// Cluster-scoped kinds keep their (missing) namespace
+	o := testOrder()
+	o.Template = "postgresql"
+	out, err = m.Apply(o, testRendered)
+	require.NoError(t, err)
+	assert.Contains(t, out, "name: team-a\n  labels:\n    db: \"true\"\n---")
+	assert.Contains(t, out, "namespace: databases")
+}
+
+func TestApply_ClusterScoped(t *testing.T) {
+	rendered := "apiVersion: example.org/v1\nkind: ProviderConfig\nmetadata:\n  name: a\n---\n" +
+		"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: b\n"
+	cfg := &Config{Mutations: []Mutation{{Namespace: "databases"}}}
+	require.NoError(t, cfg.Validate())
+
+	// Unknown kinds are namespaced
+	out, err := NewMutator(cfg).Apply(testOrder(), rendered)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(out, "namespace: databases"))
+
+	// Configured kinds
+	cfg.ClusterScopedKinds = []string{"ProviderConfig"}
+	m := NewMutator(cfg)
+	out, err = m.Apply(testOrder(), rendered)
+	require.NoError(t, err)
+	assert.Equal(t, rendered, out)
+
+	// The scope lookup takes precedence over the kind lists
+	m.SetScope(func(apiVersion, kind string) (bool, bool) {
+		return false, apiVersion == "v1" && kind == "Namespace"
+	})
+	out, err = m.Apply(testOrder(), rendered)
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: example.org/v1\nkind: ProviderConfig\nmetadata:\n  name: a\n---\n"+
+		"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: b\n  namespace: databases\n", out)
+}
+
+func TestApply_EmptyMetadata(t *testing.T) {
+	m := testMutator(t, Mutation{Labels: map[string]string{"owner": "{{ .order.parameters.team }}"}})
+
+	out, err := m.Apply(testOrder(), "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  labels: {}\n---\napiVersion: v1\nkind: ConfigMap\n")
+	require.NoError(t, err)
+	assert.Equal(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  labels:\n    owner: payments\n---\n"+
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  labels:\n    owner: payments\n", out)
+}
+
+func TestLabelValue(t *testing.T) {
+	assert.Equal(t, "alice-example.com", labelValue("alice@example.com"))
+	assert.Equal(t, "a-b", labelValue("--a b__"))
+	long := "a"
+	for len(long) < 80 {
+		long += "b"
+	}
+	assert.Len(t, labelValue(long), 63)
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mutations.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`mutations:
+  - name: ownership
+    labels:
+      owner: "{{ .order.requester }}"
+    namespace: "{{ .order.parameters.namespace }}"
+`), 0o644))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Mutations, 1)
+	assert.NotNil(t, cfg.Mutations[0].namespace)
+
+	for _, invalid := range []string{
+		"mutations:\n  - name: empty\n",
+		"mutations:\n  - labels:\n      owner: '{{ .order.requester'\n",
+		"mutations: [",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(invalid), 0o644))
+		_, err := LoadConfig(path)
+		assert.Error(t, err, invalid)
+	}
+}
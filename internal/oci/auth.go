@@ -0,0 +1,112 @@
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credentials are registry logins read from a docker config file
+type Credentials struct {
+	logins map[string]login
+}
+
+type login struct {
+	username string
+	password string
+}
+
+// dockerConfig is the part of ~/.docker/config.json holding logins
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// DockerConfigPath returns the docker config file: $DOCKER_CONFIG/config.json
+// or ~/.docker/config.json
+func DockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// LoadDockerConfig reads the logins of a docker config file, as written by
+// docker login or oras login. Credential helpers are not supported.
+func LoadDockerConfig(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read docker config: %w", err)
+	}
+	var cfg dockerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse docker config %s: %w", path, err)
+	}
+
+	c := &Credentials{logins: make(map[string]login, len(cfg.Auths))}
+	for key, a := range cfg.Auths {
+		l := login{username: a.Username, password: a.Password}
+		if a.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("parse docker config %s: auth of %s: %w", path, key, err)
+			}
+			user, pass, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return nil, fmt.Errorf("parse docker config %s: auth of %s: expected user:password", path, key)
+			}
+			l = login{username: user, password: pass}
+		}
+		if l.username != "" {
+			c.logins[registryHost(key)] = l
+		}
+	}
+	return c, nil
+}
+
+// defaultCredentials loads the default docker config file; missing or
+// invalid files mean anonymous access
+func defaultCredentials() *Credentials {
+	path := DockerConfigPath()
+	if path == "" {
+		return nil
+	}
+	c, err := LoadDockerConfig(path)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// lookup returns the login for a registry host, nil if there is none
+func (c *Credentials) lookup(registry string) *login {
+	if c == nil {
+		return nil
+	}
+	if l, ok := c.logins[registryHost(registry)]; ok {
+		return &l
+	}
+	return nil
+}
+
+// registryHost normalizes docker config keys such as
+// https://index.docker.io/v1/ to the registry host
+func registryHost(key string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	switch host {
+	case "index.docker.io", "docker.io":
+		return defaultRegistry
+	}
+	return host
+}
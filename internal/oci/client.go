@@ -0,0 +1,352 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Media types of manifests
+const (
+	MediaTypeManifest       = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeIndex          = "application/vnd.oci.image.index.v1+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// AnnotationTitle names the file a layer was pushed from
+const AnnotationTitle = "org.opencontainers.image.title"
+
+// maxBlobSize limits the size of manifests and layers
+const maxBlobSize = 64 << 20
+
+// Client pulls artifacts from OCI registries (distribution API v2)
+type Client struct {
+	HTTP *http.Client
+
+	// Credentials log in to registries that require it
+	Credentials *Credentials
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewClient creates a client with a default timeout that logs in with the
+// credentials of the default docker config file
+func NewClient() *Client {
+	return &Client{HTTP: &http.Client{Timeout: 60 * time.Second}, Credentials: defaultCredentials()}
+}
+
+// Descriptor references a blob
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// manifest is an image manifest or an index
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Layers    []Descriptor `json:"layers"`
+	Manifests []Descriptor `json:"manifests"`
+}
+
+// Layer is a downloaded layer
+type Layer struct {
+	Descriptor
+	Data []byte
+}
+
+// Artifact is a pulled artifact with its layers
+type Artifact struct {
+	Reference Reference
+	// Digest of the manifest
+	Digest string
+	Layers []Layer
+}
+
+// Pull downloads the manifest and all layers of ref. Blob digests are
+// verified; a pinned manifest digest must match as well.
+func (c *Client) Pull(ctx context.Context, ref Reference) (*Artifact, error) {
+	m, digest, err := c.fetchManifest(ctx, ref, ref.manifestRef())
+	if err != nil {
+		return nil, err
+	}
+	if ref.Digest != "" && digest != ref.Digest {
+		return nil, fmt.Errorf("pull %s: manifest digest %s does not match", ref, digest)
+	}
+
+	// Indexes list per-platform manifests; artifacts use the first one
+	if len(m.Manifests) > 0 && len(m.Layers) == 0 {
+		if m, _, err = c.fetchManifest(ctx, ref, m.Manifests[0].Digest); err != nil {
+			return nil, err
+		}
+	}
+
+	a := &Artifact{Reference: ref, Digest: digest}
+	for _, d := range m.Layers {
+		data, err := c.fetchBlob(ctx, ref, d)
+		if err != nil {
+			return nil, err
+		}
+		a.Layers = append(a.Layers, Layer{Descriptor: d, Data: data})
+	}
+	return a, nil
+}
+
+// Files returns the files of an artifact. Tar layers (optionally gzipped)
+// are unpacked; other layers are named after their title annotation.
+func (a *Artifact) Files() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, l := range a.Layers {
+		if strings.Contains(l.MediaType, "tar") {
+			if err := untar(l.Data, files); err != nil {
+				return nil, fmt.Errorf("layer %s: %w", l.Digest, err)
+			}
+			continue
+		}
+		name := l.Annotations[AnnotationTitle]
+		if name == "" {
+			name = strings.TrimPrefix(l.Digest, "sha256:")
+		}
+		files[path.Clean(name)] = l.Data
+	}
+	return files, nil
+}
+
+func (c *Client) fetchManifest(ctx context.Context, ref Reference, reference string) (*manifest, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ref, "manifests", reference), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", strings.Join([]string{MediaTypeManifest, MediaTypeIndex, MediaTypeDockerManifest, MediaTypeDockerList}, ", "))
+
+	data, err := c.get(req, ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("pull %s: manifest: %w", ref, err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, "", fmt.Errorf("pull %s: parse manifest: %w", ref, err)
+	}
+	return &m, digestOf(data), nil
+}
+
+func (c *Client) fetchBlob(ctx context.Context, ref Reference, d Descriptor) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ref, "blobs", d.Digest), nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.get(req, ref)
+	if err != nil {
+		return nil, fmt.Errorf("pull %s: blob %s: %w", ref, d.Digest, err)
+	}
+	if got := digestOf(data); got != d.Digest {
+		return nil, fmt.Errorf("pull %s: blob %s has digest %s", ref, d.Digest, got)
+	}
+	return data, nil
+}
+
+// get performs a request, answering bearer token and basic auth challenges
+// once
+func (c *Client) get(req *http.Request, ref Reference) ([]byte, error) {
+	scope := "repository:" + ref.Repository + ":pull"
+	if token := c.token(ref.Registry, scope); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		retry := req.Clone(req.Context())
+		l := c.Credentials.lookup(ref.Registry)
+		if scheme, _, _ := strings.Cut(challenge, " "); strings.EqualFold(scheme, "Basic") {
+			if l == nil {
+				return nil, errors.New("unauthorized: no credentials for " + ref.Registry)
+			}
+			retry.SetBasicAuth(l.username, l.password)
+		} else {
+			token, err := c.fetchToken(req.Context(), challenge, scope, l)
+			if err != nil {
+				return nil, err
+			}
+			c.setToken(ref.Registry, scope, token)
+			retry.Header.Set("Authorization", "Bearer "+token)
+		}
+		if resp, err = c.HTTP.Do(retry); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBlobSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBlobSize {
+		return nil, fmt.Errorf("exceeds %d bytes", maxBlobSize)
+	}
+	return data, nil
+}
+
+// fetchToken gets a token for a Bearer challenge, anonymously or with a
+// login
+func (c *Client) fetchToken(ctx context.Context, challenge, scope string, l *login) (string, error) {
+	params := parseChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.New("unauthorized")
+	}
+
+	q := url.Values{}
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		scope = params["scope"]
+	}
+	q.Set("scope", scope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if l != nil {
+		req.SetBasicAuth(l.username, l.password)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+func (c *Client) token(registry, scope string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[registry+" "+scope]
+}
+
+func (c *Client) setToken(registry, scope, token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		c.tokens = make(map[string]string)
+	}
+	c.tokens[registry+" "+scope] = token
+}
+
+func (c *Client) url(ref Reference, kind, reference string) string {
+	scheme := "https"
+	host := ref.Registry
+	if isLocalhost(host) {
+		scheme = "http"
+	}
+	return scheme + "://" + host + "/v2/" + ref.Repository + "/" + kind + "/" + reference
+}
+
+// isLocalhost reports whether a registry host, with or without port, is the
+// local machine. Only these registries are contacted over plain HTTP.
+func isLocalhost(registry string) bool {
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// parseChallenge parses `Bearer realm="...",service="...",scope="..."`
+func parseChallenge(header string) map[string]string {
+	params := make(map[string]string)
+	scheme, rest, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return params
+	}
+	for _, part := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return params
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// untar unpacks regular files of a (gzipped) tar archive into files
+func untar(data []byte, files map[string][]byte) error {
+	var r io.Reader = bytes.NewReader(data)
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid path %q", hdr.Name)
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, maxBlobSize))
+		if err != nil {
+			return err
+		}
+		files[name] = content
+	}
+}
@@ -0,0 +1,20 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsLocalhost(t *testing.T) {
+	for _, registry := range []string{"localhost", "localhost:5000", "127.0.0.1", "127.0.0.1:5000", "[::1]:5000", "::1"} {
+		assert.True(t, isLocalhost(registry), registry)
+	}
+	for _, registry := range []string{"localhost.example.com", "localhost.evil.io:443", "127.0.0.1.nip.io", "ghcr.io", "127.0.0.2"} {
+		assert.False(t, isLocalhost(registry), registry)
+	}
+
+	c := &Client{}
+	assert.Equal(t, "http://localhost:5000/v2/crds/manifests/latest", c.url(Reference{Registry: "localhost:5000", Repository: "crds"}, "manifests", "latest"))
+	assert.Equal(t, "https://localhost.example.com/v2/crds/manifests/latest", c.url(Reference{Registry: "localhost.example.com", Repository: "crds"}, "manifests", "latest"))
+}
@@ -0,0 +1,125 @@
+package oci_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci"
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci/ocitest"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + string(bytes.Repeat([]byte("a"), 64))
+	tests := []struct {
+		in   string
+		want oci.Reference
+	}{
+		{"oci://ghcr.io/org/crds:1.0.0", oci.Reference{Registry: "ghcr.io", Repository: "org/crds", Tag: "1.0.0"}},
+		{"localhost:5000/crds", oci.Reference{Registry: "localhost:5000", Repository: "crds", Tag: "latest"}},
+		{"org/crds@" + digest, oci.Reference{Registry: "registry-1.docker.io", Repository: "org/crds", Digest: digest}},
+	}
+	for _, tt := range tests {
+		got, err := oci.ParseReference(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got)
+	}
+
+	_, err := oci.ParseReference("oci://ghcr.io/org/crds@sha256:short")
+	assert.Error(t, err)
+}
+
+func TestClient_Pull(t *testing.T) {
+	registry := ocitest.NewRegistry(t)
+	registry.Token = "secret-token"
+	digest := registry.Push("org/crds", "1.0.0",
+		ocitest.Layer{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Data: tarGz(t, map[string]string{"crds/a.yaml": "kind: A\n"})},
+		ocitest.Layer{MediaType: "application/yaml", Title: "b.yaml", Data: []byte("kind: B\n")},
+	)
+
+	client := oci.NewClient()
+	ref, err := oci.ParseReference("oci://" + registry.Host() + "/org/crds:1.0.0")
+	require.NoError(t, err)
+
+	artifact, err := client.Pull(context.Background(), ref)
+	require.NoError(t, err)
+	assert.Equal(t, digest, artifact.Digest)
+
+	files, err := artifact.Files()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"crds/a.yaml": []byte("kind: A\n"), "b.yaml": []byte("kind: B\n")}, files)
+
+	// Pinned digests must match the manifest
+	ref.Tag, ref.Digest = "", digest
+	_, err = client.Pull(context.Background(), ref)
+	require.NoError(t, err)
+
+	ref.Digest = "sha256:" + string(bytes.Repeat([]byte("0"), 64))
+	_, err = client.Pull(context.Background(), ref)
+	assert.Error(t, err)
+}
+
+func TestClient_DockerConfig(t *testing.T) {
+	bearer := ocitest.NewRegistry(t)
+	bearer.Token, bearer.Username, bearer.Password = "secret-token", "alice", "s3cret"
+	bearer.Push("org/templates", "1.0.0", ocitest.Layer{MediaType: "application/yaml", Title: "a.yaml", Data: []byte("kind: A\n")})
+
+	basic := ocitest.NewRegistry(t)
+	basic.Username, basic.Password = "bob", "hunter2"
+	basic.Push("org/templates", "1.0.0", ocitest.Layer{MediaType: "application/yaml", Title: "a.yaml", Data: []byte("kind: A\n")})
+
+	pull := func(client *oci.Client, host string) error {
+		ref, err := oci.ParseReference("oci://" + host + "/org/templates:1.0.0")
+		require.NoError(t, err)
+		_, err = client.Pull(context.Background(), ref)
+		return err
+	}
+
+	// Anonymous clients are refused
+	anonymous := &oci.Client{HTTP: oci.NewClient().HTTP}
+	assert.Error(t, pull(anonymous, bearer.Host()))
+	assert.Error(t, pull(anonymous, basic.Host()))
+
+	dir := t.TempDir()
+	auth := func(user, pass string) string {
+		return base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+	}
+	config := `{"auths": {
+		"http://` + bearer.Host() + `/v2/": {"auth": "` + auth("alice", "s3cret") + `"},
+		"` + basic.Host() + `": {"username": "bob", "password": "hunter2"}
+	}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600))
+	t.Setenv("DOCKER_CONFIG", dir)
+	assert.Equal(t, filepath.Join(dir, "config.json"), oci.DockerConfigPath())
+
+	client := oci.NewClient()
+	assert.NoError(t, pull(client, bearer.Host()))
+	assert.NoError(t, pull(client, basic.Host()))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"auths": {"x": {"auth": "bm9jb2xvbg=="}}}`), 0o600))
+	_, err := oci.LoadDockerConfig(filepath.Join(dir, "config.json"))
+	assert.Error(t, err)
+}
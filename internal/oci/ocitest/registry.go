@@ -0,0 +1,169 @@
+// Package ocitest provides an in-process OCI registry for tests.
+package ocitest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Layer is a blob pushed as part of an artifact
+type Layer struct {
+	MediaType string
+	// Title is stored as org.opencontainers.image.title annotation
+	Title string
+	Data  []byte
+}
+
+// Registry is a minimal read-only distribution API v2 server. Artifacts
+// are added with Push.
+type Registry struct {
+	Server *httptest.Server
+
+	// Token, if set, is required as bearer token; the token endpoint
+	// hands it out after checking Username and Password (if set). Without
+	// a token, Username and Password are required as basic auth.
+	Token    string
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	requests  int
+}
+
+// NewRegistry starts a registry that is closed when the test ends
+func NewRegistry(t testing.TB) *Registry {
+	t.Helper()
+	r := &Registry{blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Server.Close)
+	return r
+}
+
+// Host returns host:port of the registry
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.Server.URL, "http://")
+}
+
+// Requests returns the number of manifest and blob requests served
+func (r *Registry) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+// Push stores an artifact under repo:tag and returns the manifest digest
+func (r *Registry) Push(repo, tag string, layers ...Layer) string {
+	type descriptor struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Size        int               `json:"size"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	config := []byte("{}")
+	configDigest := digest(config)
+	r.blobs[configDigest] = config
+
+	m := struct {
+		SchemaVersion int          `json:"schemaVersion"`
+		MediaType     string       `json:"mediaType"`
+		Config        descriptor   `json:"config"`
+		Layers        []descriptor `json:"layers"`
+	}{
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.oci.image.manifest.v1+json",
+		Config:        descriptor{MediaType: "application/vnd.oci.empty.v1+json", Digest: configDigest, Size: len(config)},
+		Layers:        []descriptor{},
+	}
+	for _, l := range layers {
+		d := digest(l.Data)
+		r.blobs[d] = l.Data
+		desc := descriptor{MediaType: l.MediaType, Digest: d, Size: len(l.Data)}
+		if l.Title != "" {
+			desc.Annotations = map[string]string{"org.opencontainers.image.title": l.Title}
+		}
+		m.Layers = append(m.Layers, desc)
+	}
+
+	data, _ := json.Marshal(m)
+	d := digest(data)
+	r.manifests[repo+"@"+d] = data
+	r.manifests[repo+":"+tag] = data
+	return d
+}
+
+func (r *Registry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		r.serveToken(w, req)
+		return
+	}
+	if r.Token != "" && req.Header.Get("Authorization") != "Bearer "+r.Token {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.Server.URL+`/token",service="ocitest"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if user, pass, _ := req.BasicAuth(); r.Token == "" && r.Username != "" && (user != r.Username || pass != r.Password) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ocitest"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	rest := strings.TrimPrefix(req.URL.Path, "/v2/")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+
+	if i := strings.LastIndex(rest, "/manifests/"); i >= 0 {
+		repo, ref := rest[:i], rest[i+len("/manifests/"):]
+		key := repo + ":" + ref
+		if strings.HasPrefix(ref, "sha256:") {
+			key = repo + "@" + ref
+		}
+		data, ok := r.manifests[key]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Header().Set("Docker-Content-Digest", digest(data))
+		w.Write(data)
+		return
+	}
+	if i := strings.LastIndex(rest, "/blobs/"); i >= 0 {
+		data, ok := r.blobs[rest[i+len("/blobs/"):]]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(data)
+		return
+	}
+	http.NotFound(w, req)
+}
+
+func (r *Registry) serveToken(w http.ResponseWriter, req *http.Request) {
+	if r.Username != "" {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != r.Username || pass != r.Password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]string{"token": r.Token})
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
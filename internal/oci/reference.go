@@ -0,0 +1,70 @@
+package oci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultRegistry is used for references without a registry host
+const defaultRegistry = "registry-1.docker.io"
+
+// Reference addresses an artifact in an OCI registry
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses oci://registry/repository[:tag][@digest]. The
+// oci:// prefix is optional; without tag and digest "latest" is used.
+func ParseReference(s string) (Reference, error) {
+	raw := strings.TrimPrefix(s, "oci://")
+	var ref Reference
+
+	if name, digest, ok := strings.Cut(raw, "@"); ok {
+		if !strings.HasPrefix(digest, "sha256:") || len(digest) != len("sha256:")+64 {
+			return ref, fmt.Errorf("invalid reference %q: digest must be sha256:<64 hex>", s)
+		}
+		raw, ref.Digest = name, digest
+	}
+
+	// A colon after the last slash separates the tag
+	if i := strings.LastIndex(raw, ":"); i > strings.LastIndex(raw, "/") {
+		raw, ref.Tag = raw[:i], raw[i+1:]
+	}
+
+	host, repo, ok := strings.Cut(raw, "/")
+	if !ok || !(strings.ContainsAny(host, ".:") || host == "localhost") {
+		host, repo = defaultRegistry, raw
+	}
+	if repo == "" || host == "" {
+		return ref, fmt.Errorf("invalid reference %q: expected oci://registry/repository[:tag][@digest]", s)
+	}
+	ref.Registry, ref.Repository = host, repo
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// String formats the reference without the oci:// prefix
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// manifestRef is the digest if pinned, otherwise the tag
+func (r Reference) manifestRef() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
@@ -0,0 +1,179 @@
+package order
+
+import (
+	"time"
+)
+
+// Order states
+const (
+	StatusPending        = "pending"
+	StatusApproved       = "approved"
+	StatusRejected       = "rejected"
+	StatusRendered       = "rendered"
+	StatusFailed         = "failed"
+	StatusDecommissioned = "decommissioned"
+)
+
+// Audit actions recorded in an order's history
+const (
+	ActionCreated        = "created"
+	ActionApproved       = "approved"
+	ActionRejected       = "rejected"
+	ActionRendered       = "rendered"
+	ActionFailed         = "failed"
+	ActionUpdated        = "updated"
+	ActionDecommissioned = "decommissioned"
+)
+
+// maxRevisions bounds the superseded revisions kept per order
+const maxRevisions = 20
+
+// Order is a claim order and its audit trail
+type Order struct {
+	// UID identifies the order; Name is the human readable name
+	UID             string `json:"uid"`
+	Name            string `json:"name"`
+	Template        string `json:"template"`
+	TemplateVersion string `json:"templateVersion"`
+	Status          string `json:"status"`
+	Requester       string `json:"requester,omitempty"`
+	RequestID       string `json:"requestId,omitempty"`
+
+	// Parameters are the resolved values with secrets redacted
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// Members are the resolved members of bundle orders
+	Members []Member `json:"members,omitempty"`
+
+	// Secrets holds the plaintext secret parameter values. They are kept in
+	// memory only and never written to disk.
+	Secrets map[string]interface{} `json:"-"`
+
+	// Rendered is the rendered output with secrets redacted
+	Rendered string `json:"rendered,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	// Policies are the policy findings for the rendered output
+	Policies []PolicyResult `json:"policies,omitempty"`
+
+	// Revision counts updates of the order, starting at 1. Revisions holds
+	// the superseded ones, oldest first.
+	Revision  int        `json:"revision"`
+	Revisions []Revision `json:"revisions,omitempty"`
+
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+	History   []AuditEntry `json:"history"`
+}
+
+// Member is a template ordered as part of a bundle, with its resolved
+// parameters (secrets redacted)
+type Member struct {
+	Name            string                 `json:"name"`
+	Template        string                 `json:"template"`
+	TemplateVersion string                 `json:"templateVersion,omitempty"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// AuditEntry records a state change of an order
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Actor   string    `json:"actor,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// Revision is a superseded state of an updated order
+type Revision struct {
+	Revision        int                    `json:"revision"`
+	TemplateVersion string                 `json:"templateVersion"`
+	Status          string                 `json:"status"`
+	Parameters      map[string]interface{} `json:"parameters,omitempty"`
+	Rendered        string                 `json:"rendered,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+	SupersededAt    time.Time              `json:"supersededAt"`
+}
+
+// PolicyResult is a policy finding for one rendered object
+type PolicyResult struct {
+	Policy string `json:"policy"`
+	// Action is deny or warn
+	Action string `json:"action"`
+	// Document is the zero-based position in the rendered stream
+	Document int    `json:"document"`
+	Kind     string `json:"kind,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Record appends an audit entry and moves the order to a new status
+func (o *Order) Record(status, action, actor, comment string) {
+	now := time.Now()
+	o.Status = status
+	o.UpdatedAt = now
+	o.History = append(o.History, AuditEntry{Time: now, Action: action, Actor: actor, Comment: comment})
+}
+
+// Revise archives the current revision and starts the next one with a new
+// template version and parameters. The output of the previous revision is
+// cleared until the new one is rendered; callers record the new status.
+func (o *Order) Revise(templateVersion string, params, secrets map[string]interface{}) {
+	current := o.Revision
+	if current == 0 {
+		// Orders stored before revisions were introduced
+		current = 1
+	}
+	o.Revisions = append(o.Revisions, Revision{
+		Revision:        current,
+		TemplateVersion: o.TemplateVersion,
+		Status:          o.Status,
+		Parameters:      o.Parameters,
+		Rendered:        o.Rendered,
+		Error:           o.Error,
+		SupersededAt:    time.Now(),
+	})
+	if len(o.Revisions) > maxRevisions {
+		o.Revisions = append([]Revision(nil), o.Revisions[len(o.Revisions)-maxRevisions:]...)
+	}
+
+	o.Revision = current + 1
+	o.TemplateVersion = templateVersion
+	o.Parameters = params
+	o.Secrets = secrets
+	o.Rendered, o.Error, o.Policies = "", "", nil
+}
+
+// ParameterValues returns the parameters with plaintext secrets restored
+func (o *Order) ParameterValues() map[string]interface{} {
+	out := make(map[string]interface{}, len(o.Parameters))
+	for k, v := range o.Parameters {
+		out[k] = v
+	}
+	for k, v := range o.Secrets {
+		out[k] = v
+	}
+	return out
+}
+
+// clone returns a copy that can be modified without affecting the original
+func (o *Order) clone() *Order {
+	c := *o
+	c.Parameters = copyMap(o.Parameters)
+	c.Secrets = copyMap(o.Secrets)
+	c.History = append([]AuditEntry(nil), o.History...)
+	c.Policies = append([]PolicyResult(nil), o.Policies...)
+	c.Revisions = append([]Revision(nil), o.Revisions...)
+	c.Members = append([]Member(nil), o.Members...)
+	return &c
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,263 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for unknown order IDs and names
+	ErrNotFound = errors.New("order not found")
+
+	// ErrExists is returned when creating an order with a UID or name already in use
+	ErrExists = errors.New("order already exists")
+)
+
+// Store keeps orders in memory and, if a directory is configured, persists
+// each order as a JSON file named after its UID. Orders can be looked up by
+// UID or name. It is safe for concurrent use.
+type Store struct {
+	mu        sync.RWMutex
+	dir       string
+	orders    map[string]*Order
+	names     map[string]string
+	retention Retention
+	keep      func(o *Order, now time.Time) bool
+}
+
+// Retention bounds the orders a store keeps. Orders waiting for approval
+// and orders protected with SetKeep are always kept; all others are
+// dropped oldest first.
+type Retention struct {
+	// MaxOrders is the number of orders kept (0 keeps all)
+	MaxOrders int
+	// MaxAge drops orders not updated for this long (0 keeps all)
+	MaxAge time.Duration
+}
+
+// NewStore creates a store. With an empty dir orders are kept in memory only;
+// otherwise existing orders are loaded from dir.
+func NewStore(dir string) (*Store, error) {
+	s := &Store{dir: dir, orders: make(map[string]*Order), names: make(map[string]string)}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create order store: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read order %s: %w", f, err)
+		}
+		var o Order
+		if err := json.Unmarshal(data, &o); err != nil {
+			return nil, fmt.Errorf("parse order %s: %w", f, err)
+		}
+		// Orders stored before UIDs were introduced are keyed by name
+		if o.UID == "" {
+			o.UID = o.Name
+		}
+		s.orders[o.UID] = &o
+		s.names[o.Name] = o.UID
+	}
+	return s, nil
+}
+
+// Create adds a new order; UID and name must both be unused
+func (s *Store) Create(o *Order) error {
+	if err := validID(o.UID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[o.UID]; ok {
+		return fmt.Errorf("%w: %s", ErrExists, o.UID)
+	}
+	if _, ok := s.names[o.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrExists, o.Name)
+	}
+	c := o.clone()
+	if err := s.persist(c); err != nil {
+		return err
+	}
+	s.orders[o.UID] = c
+	s.names[o.Name] = o.UID
+	s.prune(time.Now())
+	return nil
+}
+
+// SetRetention limits the orders kept from the next created order on
+func (s *Store) SetRetention(r Retention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = r
+}
+
+// SetKeep protects orders from retention while keep returns true, e.g.
+// while they count towards quotas
+func (s *Store) SetKeep(keep func(o *Order, now time.Time) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keep = keep
+}
+
+// prune drops orders beyond the retention limits; callers hold the lock.
+// Persisted files of dropped orders are removed as well.
+func (s *Store) prune(now time.Time) {
+	r := s.retention
+	if r.MaxOrders <= 0 && r.MaxAge <= 0 {
+		return
+	}
+
+	var candidates []*Order
+	for _, o := range s.orders {
+		if o.Status == StatusPending || o.Status == StatusApproved {
+			continue
+		}
+		if s.keep != nil && s.keep(o, now) {
+			continue
+		}
+		candidates = append(candidates, o)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].UpdatedAt.Before(candidates[j].UpdatedAt)
+	})
+
+	excess := 0
+	if r.MaxOrders > 0 {
+		excess = len(s.orders) - r.MaxOrders
+	}
+	for i, o := range candidates {
+		expired := r.MaxAge > 0 && now.Sub(o.UpdatedAt) > r.MaxAge
+		if i >= excess && !expired {
+			// Candidates are sorted, later ones are newer
+			break
+		}
+		if s.dir != "" {
+			if err := os.Remove(filepath.Join(s.dir, o.UID+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+		}
+		delete(s.orders, o.UID)
+		delete(s.names, o.Name)
+	}
+}
+
+// Get returns a copy of an order by UID or name
+func (s *Store) Get(id string) (*Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return o.clone(), nil
+}
+
+// Update applies fn to a copy of an order and stores the result if fn
+// succeeds. Updates of the same order are serialized, so fn can safely check
+// the current status before changing it.
+func (s *Store) Update(id string, fn func(*Order) error) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	c := o.clone()
+	if err := fn(c); err != nil {
+		return nil, err
+	}
+	// UID and name are immutable
+	c.UID, c.Name = o.UID, o.Name
+	if err := s.persist(c); err != nil {
+		return nil, err
+	}
+	s.orders[o.UID] = c
+	return c.clone(), nil
+}
+
+// lookup finds an order by UID, then by name; callers hold the lock
+func (s *Store) lookup(id string) (*Order, bool) {
+	if o, ok := s.orders[id]; ok {
+		return o, true
+	}
+	if uid, ok := s.names[id]; ok {
+		return s.orders[uid], true
+	}
+	return nil, false
+}
+
+// List returns orders newest first, optionally filtered by status
+func (s *Store) List(status string) []*Order {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]*Order, 0, len(s.orders))
+	for _, o := range s.orders {
+		if status == "" || o.Status == status {
+			out = append(out, o.clone())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out
+}
+
+// persist writes an order file atomically (no-op for in-memory stores)
+func (s *Store) persist(o *Order) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, "."+o.UID+"-*")
+	if err != nil {
+		return fmt.Errorf("persist order %s: %w", o.UID, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("persist order %s: %w", o.UID, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("persist order %s: %w", o.UID, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, o.UID+".json")); err != nil {
+		return fmt.Errorf("persist order %s: %w", o.UID, err)
+	}
+	return nil
+}
+
+// validID rejects IDs that cannot be used as file names
+func validID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid order uid %q", id)
+	}
+	return nil
+}
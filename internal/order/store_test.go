@@ -0,0 +1,194 @@
+package order
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestOrder(name string) *Order {
+	o := &Order{
+		UID:        "uid-" + name,
+		Name:       name,
+		Template:   "postgresql",
+		Parameters: map[string]interface{}{"dbName": "orders", "password": "********"},
+		Secrets:    map[string]interface{}{"password": "hunter2"},
+		CreatedAt:  time.Now(),
+	}
+	o.Record(StatusPending, ActionCreated, "alice", "")
+	return o
+}
+
+func TestStore_CreateGetUpdate(t *testing.T) {
+	s, err := NewStore("")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Create(newTestOrder("pg-1")))
+	assert.True(t, errors.Is(s.Create(newTestOrder("pg-1")), ErrExists))
+
+	// Names must be unique as well
+	dup := newTestOrder("pg-1")
+	dup.UID = "uid-other"
+	assert.True(t, errors.Is(s.Create(dup), ErrExists))
+
+	// Orders are found by UID and by name
+	byUID, err := s.Get("uid-pg-1")
+	require.NoError(t, err)
+	assert.Equal(t, "pg-1", byUID.Name)
+
+	o, err := s.Get("pg-1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, o.Status)
+	assert.Equal(t, "hunter2", o.ParameterValues()["password"])
+
+	// Modifying a returned copy does not change the store
+	o.Status = StatusRejected
+	o, _ = s.Get("pg-1")
+	assert.Equal(t, StatusPending, o.Status)
+
+	updated, err := s.Update("pg-1", func(o *Order) error {
+		o.Record(StatusApproved, ActionApproved, "bob", "looks good")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StatusApproved, updated.Status)
+	require.Len(t, updated.History, 2)
+	assert.Equal(t, "looks good", updated.History[1].Comment)
+
+	// A failing update leaves the order untouched
+	_, err = s.Update("pg-1", func(o *Order) error {
+		o.Status = StatusFailed
+		return errors.New("conflict")
+	})
+	assert.Error(t, err)
+	o, _ = s.Get("pg-1")
+	assert.Equal(t, StatusApproved, o.Status)
+
+	_, err = s.Get("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestStore_List(t *testing.T) {
+	s, err := NewStore("")
+	require.NoError(t, err)
+
+	older := newTestOrder("a")
+	older.CreatedAt = time.Now().Add(-time.Hour)
+	require.NoError(t, s.Create(older))
+	require.NoError(t, s.Create(newTestOrder("b")))
+	_, err = s.Update("a", func(o *Order) error {
+		o.Record(StatusRendered, ActionRendered, "", "")
+		return nil
+	})
+	require.NoError(t, err)
+
+	all := s.List("")
+	require.Len(t, all, 2)
+	assert.Equal(t, "b", all[0].Name)
+
+	pending := s.List(StatusPending)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "b", pending[0].Name)
+}
+
+func TestStore_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.Create(newTestOrder("pg-1")))
+
+	reopened, err := NewStore(dir)
+	require.NoError(t, err)
+	o, err := reopened.Get("uid-pg-1")
+	require.NoError(t, err)
+	assert.Equal(t, "orders", o.Parameters["dbName"])
+	assert.Len(t, o.History, 1)
+
+	// Secrets are never written to disk
+	assert.Empty(t, o.Secrets)
+}
+
+func TestStore_Retention(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	require.NoError(t, err)
+	s.SetRetention(Retention{MaxOrders: 3, MaxAge: time.Hour})
+
+	render := func(name string, age time.Duration) {
+		t.Helper()
+		require.NoError(t, s.Create(newTestOrder(name)))
+		_, err := s.Update(name, func(o *Order) error {
+			o.Record(StatusRendered, ActionRendered, "", "")
+			o.UpdatedAt = time.Now().Add(-age)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	// Expired orders are dropped on the next create
+	render("expired", 2*time.Hour)
+	render("old", 30*time.Minute)
+	require.NoError(t, s.Create(newTestOrder("pending-1")))
+	_, err = s.Get("expired")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.NoFileExists(t, filepath.Join(dir, "uid-expired.json"))
+
+	// Beyond MaxOrders the oldest closed orders go first, pending orders stay
+	render("new", 0)
+	require.NoError(t, s.Create(newTestOrder("pending-2")))
+	var names []string
+	for _, o := range s.List("") {
+		names = append(names, o.Name)
+	}
+	assert.ElementsMatch(t, []string{"new", "pending-1", "pending-2"}, names)
+
+	// Protected orders are kept beyond the limits
+	s.SetKeep(func(o *Order, now time.Time) bool { return o.Name == "new" })
+	render("newer", 0)
+	require.NoError(t, s.Create(newTestOrder("pending-3")))
+	_, err = s.Get("new")
+	assert.NoError(t, err)
+	_, err = s.Get("newer")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestStore_InvalidName(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	require.NoError(t, err)
+	assert.Error(t, s.Create(newTestOrder("../escape")))
+}
+
+func TestOrder_Revise(t *testing.T) {
+	o := newTestOrder("pg-1")
+	o.Revision = 1
+	o.TemplateVersion = "1.0.0"
+	o.Rendered = "kind: PostgreSQL\n"
+	o.Record(StatusRendered, ActionRendered, "", "")
+
+	o.Revise("1.1.0", map[string]interface{}{"dbName": "billing"}, nil)
+	assert.Equal(t, 2, o.Revision)
+	assert.Equal(t, "1.1.0", o.TemplateVersion)
+	assert.Empty(t, o.Rendered)
+	assert.Empty(t, o.Secrets)
+	require.Len(t, o.Revisions, 1)
+	assert.Equal(t, Revision{
+		Revision:        1,
+		TemplateVersion: "1.0.0",
+		Status:          StatusRendered,
+		Parameters:      map[string]interface{}{"dbName": "orders", "password": "********"},
+		Rendered:        "kind: PostgreSQL\n",
+		SupersededAt:    o.Revisions[0].SupersededAt,
+	}, o.Revisions[0])
+
+	// Only the latest revisions are kept
+	for i := 0; i < maxRevisions+5; i++ {
+		o.Revise("1.1.0", nil, nil)
+	}
+	assert.Equal(t, maxRevisions+7, o.Revision)
+	require.Len(t, o.Revisions, maxRevisions)
+	assert.Equal(t, 7, o.Revisions[0].Revision)
+}
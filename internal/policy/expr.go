@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+)
+
+// Policy expressions are CEL (https://cel.dev) evaluated with cel-go. They
+// see the variables object and order and may use the quantity() extension
+// for Kubernetes quantities. Numbers of different types compare as numbers.
+
+// interruptCheckFrequency is the number of comprehension iterations between
+// checks of the evaluation context
+const interruptCheckFrequency = 100
+
+var celEnv = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("order", cel.DynType),
+		cel.CrossTypeNumericComparisons(true),
+		cel.Function("quantity",
+			cel.Overload("quantity_string", []*cel.Type{cel.StringType}, cel.DoubleType,
+				cel.UnaryBinding(quantity)),
+			cel.Overload("quantity_int", []*cel.Type{cel.IntType}, cel.DoubleType,
+				cel.UnaryBinding(quantity)),
+			cel.Overload("quantity_double", []*cel.Type{cel.DoubleType}, cel.DoubleType,
+				cel.UnaryBinding(quantity)),
+		),
+	)
+})
+
+// quantity converts a Kubernetes quantity like 500Gi into a number
+func quantity(v ref.Val) ref.Val {
+	switch v := v.(type) {
+	case types.String:
+		n, err := quota.ParseQuantity(string(v))
+		if err != nil {
+			return types.WrapErr(err)
+		}
+		return types.Double(n)
+	case types.Int:
+		return types.Double(v)
+	case types.Double:
+		return v
+	}
+	return types.MaybeNoSuchOverloadErr(v)
+}
+
+// Program is a compiled policy expression
+type Program struct {
+	source  string
+	program cel.Program
+}
+
+// Compile parses and checks a policy expression; it must return a bool
+func Compile(source string) (*Program, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("compile %q: %w", source, issues.Err())
+	}
+	if t := ast.OutputType(); !t.IsExactType(cel.BoolType) && !t.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("compile %q: expression must return a bool, got %s", source, t)
+	}
+	program, err := env.Program(ast, cel.InterruptCheckFrequency(interruptCheckFrequency))
+	if err != nil {
+		return nil, fmt.Errorf("compile %q: %w", source, err)
+	}
+	return &Program{source: source, program: program}, nil
+}
+
+// Eval evaluates the program with the given variables
+func (p *Program) Eval(ctx context.Context, vars map[string]interface{}) (bool, error) {
+	out, _, err := p.program.ContextEval(ctx, vars)
+	if err != nil {
+		return false, err
+	}
+	ok, isBool := out.Value().(bool)
+	if !isBool {
+		return false, fmt.Errorf("expression must return a bool, got %s", out.Type())
+	}
+	return ok, nil
+}
+
+// String returns the source of the program
+func (p *Program) String() string {
+	return p.source
+}
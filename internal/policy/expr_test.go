@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile_Eval(t *testing.T) {
+	vars := map[string]interface{}{
+		"object": map[string]interface{}{
+			"kind": "VolumeClaim",
+			"metadata": map[string]interface{}{
+				"name":   "data",
+				"labels": map[string]interface{}{"owner": "team-a", "tier": "gold"},
+			},
+			"spec": map[string]interface{}{
+				"storage":  "600Gi",
+				"replicas": 3,
+				"ratio":    0.5,
+				"ports":    []interface{}{80, 443},
+			},
+		},
+		"order": map[string]interface{}{
+			"requester":  "alice",
+			"parameters": map[string]interface{}{"environment": "dev"},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`object.kind == "VolumeClaim"`, true},
+		{`object.spec.replicas + 1 == 4`, true},
+		{`object.spec.replicas >= 3 && object.spec.replicas < 10`, true},
+		{`object.spec.ratio < 1 && object.spec.replicas > 2.5`, true},
+		{`7 / 2 == 3 && 7 % 2 == 1`, true},
+		{`"owner" in object.metadata.labels`, true},
+		{`443 in object.spec.ports`, true},
+		{`object.metadata.labels["tier"] == "gold"`, true},
+		{`object.spec.ports[0] == 80`, true},
+		{`has(object.metadata.labels.owner)`, true},
+		{`has(object.metadata.annotations)`, false},
+		{`!has(object.spec.size)`, true},
+		{`size(object.spec.ports) == 2 && object.metadata.name.size() == 4`, true},
+		{`object.metadata.name.startsWith("da") && object.metadata.name.endsWith("ta")`, true},
+		{`object.metadata.name.matches("^[a-z]+$")`, true},
+		{`object.metadata.name.contains("x")`, false},
+		{`quantity(object.spec.storage) > quantity("500Gi")`, true},
+		{`quantity(object.spec.replicas) == 3.0`, true},
+		{`order.parameters.environment != "dev" || quantity(object.spec.storage) <= quantity("500Gi")`, false},
+		{`object.spec.ports.all(p, p > 0)`, true},
+		{`object.spec.ports.exists(p, p == 8080)`, false},
+		{`object.spec.ports.exists_one(p, p > 100)`, true},
+		{`object.spec.ports.map(p, p * 2) == [160, 886]`, true},
+		{`object.spec.ports.filter(p, p > 100) == [443]`, true},
+		{`object.metadata.labels.all(k, k.size() > 2)`, true},
+		{`(order.requester == "alice" ? "self" : "other") == "self"`, true},
+		{`int("42") + int(2.9) == 44 && double("1.5") == 1.5`, true},
+		// A missing field on the left is absorbed when the right decides
+		{`object.spec.missing == 1 || true`, true},
+		{`object.spec.missing == 1 && false`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Compile(tt.expr)
+			require.NoError(t, err)
+			got, err := p.Eval(context.Background(), vars)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, expr := range []string{
+		`object.`,
+		`(1 + 2`,
+		`1 +`,
+		`unknown(1)`,
+		`undeclared == 1`,
+		`has(object)`,
+		`"unterminated`,
+		`1 2`,
+		`1 + 2`,
+		`"not a bool"`,
+	} {
+		_, err := Compile(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	vars := map[string]interface{}{
+		"object": map[string]interface{}{"spec": map[string]interface{}{"size": "big"}},
+		"order":  map[string]interface{}{},
+	}
+	for _, expr := range []string{
+		`object.spec.missing == 1`,
+		`object.spec.size > 1`,
+		`object.spec.size - 1 == 0`,
+		`1 / (size(object.spec) - 1) == 1`,
+		`quantity("lots") > 1.0`,
+		`object.spec.size ? true : false`,
+		`object.spec.size`,
+	} {
+		p, err := Compile(expr)
+		require.NoError(t, err, expr)
+		_, err = p.Eval(context.Background(), vars)
+		assert.Error(t, err, expr)
+	}
+
+	// The branch not taken is not evaluated
+	p, err := Compile(`true ? true : object.spec.missing`)
+	require.NoError(t, err)
+	_, err = p.Eval(context.Background(), vars)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,269 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"gopkg.in/yaml.v3"
+)
+
+// Actions taken when a policy is violated
+const (
+	ActionDeny = "deny"
+	ActionWarn = "warn"
+)
+
+// Config is the policy configuration file
+type Config struct {
+	Policies []Policy `yaml:"policies"`
+}
+
+// Policy is a check of each rendered object. Expression policies use CEL,
+// Rego policies use Rego or RegoFile.
+type Policy struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+
+	// Action is deny (default) or warn. Rego policies report their deny
+	// and warn rules instead.
+	Action string `yaml:"action,omitempty"`
+
+	// Match restricts the policy to templates and kinds (empty = all)
+	Match Match `yaml:"match,omitempty"`
+
+	// Expression is a CEL expression that must be true for every matching
+	// object. It sees the variables object and order.
+	Expression string `yaml:"expression,omitempty"`
+
+	// Message is reported when the expression is false
+	Message string `yaml:"message,omitempty"`
+
+	// Rego is a Rego module; messages of its deny and warn rules are
+	// reported. RegoFile is read relative to the configuration file.
+	Rego     string `yaml:"rego,omitempty"`
+	RegoFile string `yaml:"regoFile,omitempty"`
+
+	program *Program
+	query   *rego.PreparedEvalQuery
+}
+
+// Match selects the objects a policy applies to
+type Match struct {
+	Templates []string `yaml:"templates,omitempty"`
+	Kinds     []string `yaml:"kinds,omitempty"`
+}
+
+// LoadConfig reads and validates a policy configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse policy config: %w", err)
+	}
+	for i := range cfg.Policies {
+		p := &cfg.Policies[i]
+		if p.RegoFile == "" {
+			continue
+		}
+		file := p.RegoFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		module, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+		p.Rego = string(module)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the configuration and compiles expressions and Rego modules
+func (c *Config) Validate() error {
+	for i := range c.Policies {
+		p := &c.Policies[i]
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("policy-%d", i)
+		}
+		if p.Action == "" {
+			p.Action = ActionDeny
+		}
+		if p.Action != ActionDeny && p.Action != ActionWarn {
+			return fmt.Errorf("policy %s: action must be deny or warn", p.Name)
+		}
+
+		switch {
+		case p.Expression != "" && p.Rego != "":
+			return fmt.Errorf("policy %s: use either expression or rego", p.Name)
+		case p.Expression != "":
+			program, err := Compile(p.Expression)
+			if err != nil {
+				return fmt.Errorf("policy %s: %w", p.Name, err)
+			}
+			p.program = program
+		case p.Rego != "":
+			query, err := prepareRego(p.Name, p.Rego)
+			if err != nil {
+				return fmt.Errorf("policy %s: %w", p.Name, err)
+			}
+			p.query = query
+		default:
+			return fmt.Errorf("policy %s: expression or rego is required", p.Name)
+		}
+	}
+	return nil
+}
+
+func (p Policy) matches(template, kind string) bool {
+	return matchAny(p.Match.Templates, template) && matchAny(p.Match.Kinds, kind)
+}
+
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Engine evaluates policies against rendered orders
+type Engine struct {
+	policies []Policy
+}
+
+// NewEngine creates an engine for a validated configuration
+func NewEngine(cfg *Config) *Engine {
+	return &Engine{policies: cfg.Policies}
+}
+
+// Len returns the number of policies
+func (e *Engine) Len() int {
+	return len(e.policies)
+}
+
+// Evaluate checks every rendered document against the matching policies.
+// Rego policies are evaluated once per order for all their documents.
+// Evaluation errors are reported as findings with the policy's action.
+func (e *Engine) Evaluate(ctx context.Context, o *order.Order, docs []manifest.Document) []order.PolicyResult {
+	orderVars := orderContext(o)
+
+	var results []order.PolicyResult
+	for _, p := range e.policies {
+		var matched []int
+		for i, d := range docs {
+			if p.matches(o.Template, d.Kind()) {
+				matched = append(matched, i)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		add := func(i int, action, message string) {
+			results = append(results, order.PolicyResult{
+				Policy:   p.Name,
+				Action:   action,
+				Document: i,
+				Kind:     docs[i].Kind(),
+				Name:     docs[i].Name(),
+				Message:  message,
+			})
+		}
+
+		if p.program != nil {
+			for _, i := range matched {
+				vars := map[string]interface{}{"object": docs[i].Object, "order": orderVars}
+				if ok, err := p.program.Eval(ctx, vars); err != nil {
+					add(i, p.Action, "evaluation failed: "+err.Error())
+				} else if !ok {
+					add(i, p.Action, p.message())
+				}
+			}
+			continue
+		}
+
+		inputs := make([]interface{}, len(matched))
+		for j, i := range matched {
+			inputs[j] = map[string]interface{}{"object": docs[i].Object, "order": orderVars}
+		}
+		findings, err := evalRego(ctx, p, inputs)
+		if err != nil {
+			for _, i := range matched {
+				add(i, p.Action, "evaluation failed: "+err.Error())
+			}
+			continue
+		}
+		for j, i := range matched {
+			for _, f := range findings[j] {
+				add(i, f.action, f.message)
+			}
+		}
+	}
+
+	// Findings are listed by document, then in policy order
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Document < results[j].Document
+	})
+	return results
+}
+
+func (p Policy) message() string {
+	if p.Message != "" {
+		return p.Message
+	}
+	return "failed expression: " + p.Expression
+}
+
+// orderContext is the order as seen by policies
+func orderContext(o *order.Order) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       o.Name,
+		"template":   o.Template,
+		"version":    o.TemplateVersion,
+		"requester":  o.Requester,
+		"parameters": o.Parameters,
+	}
+}
+
+// Denied reports whether any result denies the order
+func Denied(results []order.PolicyResult) bool {
+	for _, r := range results {
+		if r.Action == ActionDeny {
+			return true
+		}
+	}
+	return false
+}
+
+// DeniedError rejects an order whose rendered output violates deny policies.
+// Results holds all findings, including warnings.
+type DeniedError struct {
+	Results []order.PolicyResult
+}
+
+func (e *DeniedError) Error() string {
+	var msgs []string
+	for _, r := range e.Results {
+		if r.Action == ActionDeny {
+			msgs = append(msgs, fmt.Sprintf("%s: %s %s: %s", r.Policy, r.Kind, r.Name, r.Message))
+		}
+	}
+	return "denied by policy: " + strings.Join(msgs, "; ")
+}
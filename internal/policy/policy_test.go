@@ -0,0 +1,171 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+const testRendered = `apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data
+spec:
+  storage: 600Gi
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  labels:
+    owner: team-a
+`
+
+func testDocs(t *testing.T) []manifest.Document {
+	t.Helper()
+	docs, err := manifest.Split(testRendered)
+	require.NoError(t, err)
+	return docs
+}
+
+func testPolicyOrder(environment string) *order.Order {
+	return &order.Order{
+		Name:       "volumeclaim-1",
+		Template:   "volumeclaim",
+		Requester:  "alice",
+		Parameters: map[string]interface{}{"environment": environment},
+	}
+}
+
+func TestEngine_Expression(t *testing.T) {
+	cfg := &Config{Policies: []Policy{
+		{
+			Name:       "dev-volume-size",
+			Match:      Match{Kinds: []string{"VolumeClaim"}},
+			Expression: `order.parameters.environment != "dev" || quantity(object.spec.storage) <= quantity("500Gi")`,
+			Message:    "volumes in dev are limited to 500Gi",
+		},
+		{
+			Name:       "owner-label",
+			Action:     ActionWarn,
+			Expression: `has(object.metadata.labels) && has(object.metadata.labels.owner)`,
+			Message:    "labels.owner is required",
+		},
+		{
+			Name:       "other-template",
+			Match:      Match{Templates: []string{"postgres*"}},
+			Expression: `false`,
+		},
+	}}
+	require.NoError(t, cfg.Validate())
+	engine := NewEngine(cfg)
+
+	results := engine.Evaluate(context.Background(), testPolicyOrder("dev"), testDocs(t))
+	assert.Equal(t, []order.PolicyResult{
+		{Policy: "dev-volume-size", Action: ActionDeny, Document: 0, Kind: "VolumeClaim", Name: "data", Message: "volumes in dev are limited to 500Gi"},
+		{Policy: "owner-label", Action: ActionWarn, Document: 0, Kind: "VolumeClaim", Name: "data", Message: "labels.owner is required"},
+	}, results)
+	assert.True(t, Denied(results))
+
+	err := &DeniedError{Results: results}
+	assert.Equal(t, "denied by policy: dev-volume-size: VolumeClaim data: volumes in dev are limited to 500Gi", err.Error())
+
+	// Outside dev only the warning remains
+	results = engine.Evaluate(context.Background(), testPolicyOrder("prod"), testDocs(t))
+	require.Len(t, results, 1)
+	assert.False(t, Denied(results))
+}
+
+func TestEngine_EvaluationError(t *testing.T) {
+	cfg := &Config{Policies: []Policy{{Name: "size", Expression: `object.spec.size > 1`}}}
+	require.NoError(t, cfg.Validate())
+
+	results := NewEngine(cfg).Evaluate(context.Background(), testPolicyOrder("dev"), testDocs(t))
+	require.Len(t, results, 2)
+	assert.Equal(t, ActionDeny, results[0].Action)
+	assert.Contains(t, results[0].Message, "evaluation failed: no such key: size")
+}
+
+func TestEngine_Rego(t *testing.T) {
+	cfg := &Config{Policies: []Policy{{
+		Name: "labels",
+		Rego: `package claims.labels
+
+deny contains msg if {
+	not input.object.metadata.labels.owner
+	msg := "owner label missing"
+}
+
+warn contains {"msg": "consider an annotation"} if {
+	input.object.kind == "ConfigMap"
+	input.order.requester == "alice"
+}
+`,
+	}}}
+	require.NoError(t, cfg.Validate())
+
+	results := NewEngine(cfg).Evaluate(context.Background(), testPolicyOrder("dev"), testDocs(t))
+	assert.Equal(t, []order.PolicyResult{
+		{Policy: "labels", Action: ActionDeny, Document: 0, Kind: "VolumeClaim", Name: "data", Message: "owner label missing"},
+		{Policy: "labels", Action: ActionWarn, Document: 1, Kind: "ConfigMap", Name: "settings", Message: "consider an annotation"},
+	}, results)
+
+	// Evaluation errors are findings with the policy's action
+	cfg = &Config{Policies: []Policy{{
+		Name: "conflict",
+		Rego: "package claims.conflict\n\ndeny := \"a\" if input.object.kind\n\ndeny := \"b\" if input.object.kind\n",
+	}}}
+	require.NoError(t, cfg.Validate())
+	results = NewEngine(cfg).Evaluate(context.Background(), testPolicyOrder("dev"), testDocs(t))
+	require.Len(t, results, 2)
+	assert.True(t, Denied(results))
+	assert.Contains(t, results[0].Message, "evaluation failed: ")
+}
+
+func TestRegoIndex(t *testing.T) {
+	i, err := regoIndex(json.Number("1"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, i)
+
+	for _, v := range []interface{}{json.Number("2"), json.Number("-1"), json.Number("0.5"), "0"} {
+		_, err := regoIndex(v, 2)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policies.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`policies:
+  - name: owner-label
+    action: warn
+    expression: has(object.metadata.labels) && has(object.metadata.labels.owner)
+`), 0o644))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Policies, 1)
+	assert.Equal(t, ActionWarn, cfg.Policies[0].Action)
+	assert.NotNil(t, cfg.Policies[0].program)
+
+	for _, invalid := range []string{
+		"policies:\n  - name: a\n",
+		"policies:\n  - name: a\n    action: block\n    expression: 'true'\n",
+		"policies:\n  - name: a\n    expression: 'object.'\n",
+		"policies:\n  - name: a\n    rego: 'deny[msg] { true }'\n",
+		"policies:\n  - name: a\n    rego: \"package a\\ndeny[msg] { true }\"\n",
+		"policies:\n  - name: a\n    regoFile: missing.rego\n",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(invalid), 0o644))
+		_, err := LoadConfig(path)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// regoTimeout bounds the evaluation of a policy for all documents of an order
+const regoTimeout = 10 * time.Second
+
+type finding struct {
+	action  string
+	message string
+}
+
+// prepareRego compiles a Rego module and the query of its deny and warn
+// rules. Each element of the input array is evaluated as the input of the
+// package, paired with its index.
+func prepareRego(name, module string) (*rego.PreparedEvalQuery, error) {
+	m, err := ast.ParseModule(name+".rego", module)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("[[i, v] | some i; d := input[i]; v := %s with input as d]", m.Package.Path)
+	prepared, err := rego.New(
+		rego.Query(query),
+		rego.ParsedModule(m),
+	).PrepareForEval(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &prepared, nil
+}
+
+// evalRego queries the deny and warn rules of a Rego policy for a list of
+// inputs in one evaluation and returns the findings per input. Rules may
+// yield strings or objects with a msg field, as in conftest and Gatekeeper
+// policies.
+func evalRego(ctx context.Context, p Policy, inputs []interface{}) ([][]finding, error) {
+	ctx, cancel := context.WithTimeout(ctx, regoTimeout)
+	defer cancel()
+
+	rs, err := p.query.Eval(ctx, rego.EvalInput(inputs))
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([][]finding, len(inputs))
+	for _, r := range rs {
+		for _, expr := range r.Expressions {
+			pairs, ok := expr.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("unexpected rego result %T", expr.Value)
+			}
+			for _, pair := range pairs {
+				kv, ok := pair.([]interface{})
+				if !ok || len(kv) != 2 {
+					return nil, fmt.Errorf("unexpected rego result %v", pair)
+				}
+				i, err := regoIndex(kv[0], len(inputs))
+				if err != nil {
+					return nil, err
+				}
+				value, _ := kv[1].(map[string]interface{})
+				for _, action := range []string{ActionDeny, ActionWarn} {
+					for _, msg := range regoMessages(value[action]) {
+						findings[i] = append(findings[i], finding{action: action, message: msg})
+					}
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// regoIndex checks the input index of a result pair
+func regoIndex(v interface{}, n int) (int, error) {
+	num, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("unexpected rego result: invalid index %v", v)
+	}
+	i, err := num.Int64()
+	if err != nil || i < 0 || i >= int64(n) {
+		return 0, fmt.Errorf("unexpected rego result: invalid index %v", v)
+	}
+	return int(i), nil
+}
+
+// regoMessages extracts messages from a rule value (a set of strings or
+// of objects with msg)
+func regoMessages(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	var msgs []string
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			msgs = append(msgs, v)
+		case map[string]interface{}:
+			if msg, ok := v["msg"].(string); ok {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	sort.Strings(msgs)
+	return msgs
+}
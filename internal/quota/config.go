@@ -0,0 +1,104 @@
+package quota
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the rate limit and quota configuration file
+type Config struct {
+	RateLimit *RateLimit `yaml:"rateLimit,omitempty"`
+	Quotas    []Quota    `yaml:"quotas,omitempty"`
+}
+
+// RateLimit configures a token bucket per client for write requests
+type RateLimit struct {
+	// RequestsPerSecond is the sustained refill rate
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+
+	// Burst is the bucket size (default: RequestsPerSecond rounded up)
+	Burst int `yaml:"burst,omitempty"`
+}
+
+// Quota limits orders within a rolling time window
+type Quota struct {
+	Name string `yaml:"name"`
+
+	// Template restricts the quota to one template (empty or "*" = all)
+	Template string `yaml:"template,omitempty"`
+
+	// PerUser counts orders per requester instead of globally (default true)
+	PerUser *bool `yaml:"perUser,omitempty"`
+
+	// Period is the rolling window, e.g. 24h
+	Period time.Duration `yaml:"period"`
+
+	// MaxOrders caps the number of orders in the window
+	MaxOrders int `yaml:"maxOrders,omitempty"`
+
+	// Parameter and MaxTotal cap the sum of a quantity parameter in the
+	// window, e.g. parameter: storage, maxTotal: 500Gi
+	Parameter string `yaml:"parameter,omitempty"`
+	MaxTotal  string `yaml:"maxTotal,omitempty"`
+
+	maxTotal float64
+}
+
+// LoadConfig reads and validates a quota configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read quota config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse quota config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the configuration and parses quantity limits
+func (c *Config) Validate() error {
+	if c.RateLimit != nil && c.RateLimit.RequestsPerSecond <= 0 {
+		return fmt.Errorf("rateLimit.requestsPerSecond must be positive")
+	}
+
+	for i := range c.Quotas {
+		q := &c.Quotas[i]
+		if q.Name == "" {
+			q.Name = fmt.Sprintf("quota-%d", i)
+		}
+		if q.Period <= 0 {
+			return fmt.Errorf("quota %s: period must be positive", q.Name)
+		}
+		if q.MaxOrders <= 0 && q.MaxTotal == "" {
+			return fmt.Errorf("quota %s: maxOrders or maxTotal is required", q.Name)
+		}
+		if q.MaxTotal != "" {
+			if q.Parameter == "" {
+				return fmt.Errorf("quota %s: maxTotal requires parameter", q.Name)
+			}
+			total, err := ParseQuantity(q.MaxTotal)
+			if err != nil {
+				return fmt.Errorf("quota %s: %w", q.Name, err)
+			}
+			q.maxTotal = total
+		}
+	}
+	return nil
+}
+
+func (q Quota) perUser() bool {
+	return q.PerUser == nil || *q.PerUser
+}
+
+func (q Quota) appliesTo(template string) bool {
+	return q.Template == "" || q.Template == "*" || q.Template == template
+}
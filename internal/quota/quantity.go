@@ -0,0 +1,61 @@
+package quota
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// quantitySuffixes follow Kubernetes resource quantities; binary suffixes
+// are listed first so "Gi" is not matched as "G"
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"Ti", 1 << 40},
+	{"Pi", 1 << 50},
+	{"k", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"T", 1e12},
+	{"P", 1e15},
+}
+
+// ParseQuantity parses a plain number or a quantity like 10Gi or 500M
+func ParseQuantity(s string) (float64, error) {
+	number := strings.TrimSpace(s)
+	multiplier := 1.0
+	for _, q := range quantitySuffixes {
+		if strings.HasSuffix(number, q.suffix) {
+			number = strings.TrimSuffix(number, q.suffix)
+			multiplier = q.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || !validQuantity(n) || !validQuantity(n*multiplier) {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// validQuantity reports whether n is a finite, non-negative quantity.
+// Negative values would lower the usage summed for maxTotal.
+func validQuantity(n float64) bool {
+	return n >= 0 && !math.IsInf(n, 0) && !math.IsNaN(n)
+}
+
+// FormatQuantity formats value in the unit used by like, e.g. 1.5Gi for like=500Gi
+func FormatQuantity(value float64, like string) string {
+	for _, q := range quantitySuffixes {
+		if strings.HasSuffix(like, q.suffix) {
+			return strconv.FormatFloat(value/q.multiplier, 'f', -1, 64) + q.suffix
+		}
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,231 @@
+package quota
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+// Request is an order about to be placed. Bundle orders list their
+// resolved members, which count towards the quotas of their templates.
+type Request struct {
+	Template   string
+	User       string
+	Parameters map[string]interface{}
+	Members    []order.Member
+}
+
+// Usage reports how much of a quota is consumed, including the new order
+type Usage struct {
+	Quota     string `json:"quota"`
+	Template  string `json:"template,omitempty"`
+	User      string `json:"user,omitempty"`
+	Period    string `json:"period"`
+	Orders    int    `json:"orders"`
+	MaxOrders int    `json:"maxOrders,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Total     string `json:"total,omitempty"`
+	MaxTotal  string `json:"maxTotal,omitempty"`
+}
+
+// Limits of a quota an order can exceed
+const (
+	LimitMaxOrders = "maxOrders"
+	LimitMaxTotal  = "maxTotal"
+)
+
+// ExceededError is returned when an order would exceed a quota
+type ExceededError struct {
+	Usage Usage
+
+	// Limit is the exceeded limit, LimitMaxOrders or LimitMaxTotal
+	Limit string
+
+	// RetryAfter is when enough usage leaves the window (0 if waiting does not help)
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	if e.Limit == LimitMaxTotal {
+		return fmt.Sprintf("quota %s exceeded: %s %s of %s within %s",
+			e.Usage.Quota, e.Usage.Total, e.Usage.Parameter, e.Usage.MaxTotal, e.Usage.Period)
+	}
+	return fmt.Sprintf("quota %s exceeded: %d of %d orders within %s",
+		e.Usage.Quota, e.Usage.Orders, e.Usage.MaxOrders, e.Usage.Period)
+}
+
+// Check returns an *ExceededError if placing req on top of the existing
+// orders would exceed one of the quotas. Rejected and failed orders do not count
+// unless an earlier revision of them was delivered.
+func Check(quotas []Quota, orders []*order.Order, req Request, now time.Time) error {
+	for _, q := range quotas {
+		requested := q.claims(req.Template, req.Parameters, req.Members)
+		if len(requested) == 0 {
+			continue
+		}
+
+		// Claims of the orders in the window, oldest first
+		var counted []claim
+		for _, o := range orders {
+			if !q.counts(o, now) {
+				continue
+			}
+			if q.perUser() && o.Requester != req.User {
+				continue
+			}
+			for _, params := range q.claims(o.Template, o.Parameters, o.Members) {
+				counted = append(counted, claim{createdAt: o.CreatedAt, parameters: params})
+			}
+		}
+		sort.SliceStable(counted, func(i, j int) bool {
+			return counted[i].createdAt.Before(counted[j].createdAt)
+		})
+
+		usage := Usage{
+			Quota:     q.Name,
+			Template:  q.Template,
+			Period:    q.Period.String(),
+			Orders:    len(counted) + len(requested),
+			MaxOrders: q.MaxOrders,
+		}
+		if q.perUser() {
+			usage.User = req.User
+		}
+
+		if q.MaxOrders > 0 && usage.Orders > q.MaxOrders {
+			exceeded := &ExceededError{Usage: usage, Limit: LimitMaxOrders}
+			// Waiting does not help if the order alone is too large
+			if len(requested) <= q.MaxOrders {
+				oldest := counted[usage.Orders-q.MaxOrders-1]
+				exceeded.RetryAfter = oldest.createdAt.Add(q.Period).Sub(now)
+			}
+			return exceeded
+		}
+
+		if q.MaxTotal == "" {
+			continue
+		}
+		var want float64
+		for _, params := range requested {
+			v, err := quantityValue(params[q.Parameter])
+			if err != nil {
+				return fmt.Errorf("parameter %s: %w", q.Parameter, err)
+			}
+			want += v
+		}
+		values := make([]float64, len(counted))
+		total := want
+		for i, c := range counted {
+			// Values that cannot be parsed were accepted before the quota existed
+			values[i], _ = quantityValue(c.parameters[q.Parameter])
+			total += values[i]
+		}
+
+		usage.Parameter = q.Parameter
+		usage.Total = FormatQuantity(total, q.MaxTotal)
+		usage.MaxTotal = q.MaxTotal
+		if total <= q.maxTotal {
+			continue
+		}
+
+		exceeded := &ExceededError{Usage: usage, Limit: LimitMaxTotal}
+		if want <= q.maxTotal {
+			for i, c := range counted {
+				total -= values[i]
+				if total <= q.maxTotal {
+					exceeded.RetryAfter = c.createdAt.Add(q.Period).Sub(now)
+					break
+				}
+			}
+		}
+		return exceeded
+	}
+	return nil
+}
+
+// claim is a template ordered with its parameters
+type claim struct {
+	createdAt  time.Time
+	parameters map[string]interface{}
+}
+
+// claims returns the parameters of everything an order claims under the
+// quota. Bundles claim their members; the bundle itself only counts for
+// quotas that name it.
+func (q Quota) claims(template string, params map[string]interface{}, members []order.Member) []map[string]interface{} {
+	var out []map[string]interface{}
+	if len(members) == 0 || q.Template == template {
+		if q.appliesTo(template) {
+			out = append(out, params)
+		}
+	}
+	for _, m := range members {
+		if q.appliesTo(m.Template) {
+			out = append(out, m.Parameters)
+		}
+	}
+	return out
+}
+
+// Counts reports whether an order is counted by one of the quotas at now.
+// Such orders must be kept for quotas to see them.
+func Counts(quotas []Quota, o *order.Order, now time.Time) bool {
+	for _, q := range quotas {
+		if q.counts(o, now) && len(q.claims(o.Template, o.Parameters, o.Members)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// counts reports whether an order consumes quota and is inside the window
+func (q Quota) counts(o *order.Order, now time.Time) bool {
+	return countsTowardsQuota(o) && o.CreatedAt.After(now.Add(-q.Period))
+}
+
+// countsTowardsQuota reports whether an order consumes quota. A failed or
+// rejected update keeps consuming it: the output of an earlier revision is
+// still applied.
+func countsTowardsQuota(o *order.Order) bool {
+	switch o.Status {
+	case order.StatusDecommissioned:
+		return false
+	case order.StatusRejected, order.StatusFailed:
+		for _, rev := range o.Revisions {
+			if rev.Rendered != "" {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// quantityValue reads a numeric or quantity parameter value; missing values
+// count as 0. Negative, NaN and infinite values are rejected.
+func quantityValue(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if !validQuantity(val) {
+			return 0, fmt.Errorf("invalid quantity %v", v)
+		}
+		return val, nil
+	case int:
+		if val < 0 {
+			return 0, fmt.Errorf("invalid quantity %v", v)
+		}
+		return float64(val), nil
+	case string:
+		if val == "" {
+			return 0, nil
+		}
+		return ParseQuantity(val)
+	default:
+		return 0, fmt.Errorf("invalid quantity %v", v)
+	}
+}
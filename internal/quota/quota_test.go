@@ -0,0 +1,283 @@
+package quota
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+)
+
+func testOrder(template, user, status string, age time.Duration, params map[string]interface{}) *order.Order {
+	return &order.Order{
+		Name:       template + "-" + user,
+		Template:   template,
+		Requester:  user,
+		Status:     status,
+		Parameters: params,
+		CreatedAt:  time.Now().Add(-age),
+	}
+}
+
+func TestCheck_MaxOrders(t *testing.T) {
+	cfg := &Config{Quotas: []Quota{{Name: "vms", Template: "vsphere-vm", Period: 24 * time.Hour, MaxOrders: 2}}}
+	require.NoError(t, cfg.Validate())
+
+	orders := []*order.Order{
+		testOrder("vsphere-vm", "alice", order.StatusRendered, 20*time.Hour, nil),
+		testOrder("vsphere-vm", "alice", order.StatusRendered, time.Hour, nil),
+		testOrder("vsphere-vm", "alice", order.StatusRejected, time.Hour, nil),
+		testOrder("vsphere-vm", "bob", order.StatusRendered, time.Hour, nil),
+		testOrder("volumeclaim", "alice", order.StatusRendered, time.Hour, nil),
+	}
+	now := time.Now()
+
+	err := Check(cfg.Quotas, orders, Request{Template: "vsphere-vm", User: "alice"}, now)
+	var exceeded *ExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, 3, exceeded.Usage.Orders)
+	assert.Equal(t, 2, exceeded.Usage.MaxOrders)
+	assert.Equal(t, "alice", exceeded.Usage.User)
+	// The oldest order leaves the window in about four hours
+	assert.InDelta(t, (4 * time.Hour).Seconds(), exceeded.RetryAfter.Seconds(), 5)
+
+	// Other users and templates are not affected
+	assert.NoError(t, Check(cfg.Quotas, orders, Request{Template: "vsphere-vm", User: "bob"}, now))
+	assert.NoError(t, Check(cfg.Quotas, orders, Request{Template: "volumeclaim", User: "alice"}, now))
+}
+
+func TestCheck_FailedUpdate(t *testing.T) {
+	cfg := &Config{Quotas: []Quota{{Name: "vms", Template: "vsphere-vm", Period: time.Hour, MaxOrders: 1}}}
+	require.NoError(t, cfg.Validate())
+
+	// A failed order frees its quota, a failed update does not
+	failed := testOrder("vsphere-vm", "alice", order.StatusFailed, time.Minute, nil)
+	require.NoError(t, Check(cfg.Quotas, []*order.Order{failed}, Request{Template: "vsphere-vm", User: "alice"}, time.Now()))
+
+	failed.Revisions = []order.Revision{{Revision: 1, Status: order.StatusRendered, Rendered: "kind: VirtualMachine\n"}}
+	err := Check(cfg.Quotas, []*order.Order{failed}, Request{Template: "vsphere-vm", User: "alice"}, time.Now())
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, 2, exceeded.Usage.Orders)
+
+	failed.Status = order.StatusDecommissioned
+	assert.NoError(t, Check(cfg.Quotas, []*order.Order{failed}, Request{Template: "vsphere-vm", User: "alice"}, time.Now()))
+}
+
+func TestCounts(t *testing.T) {
+	quotas := []Quota{
+		{Name: "vms", Template: "vsphere-vm", Period: time.Hour, MaxOrders: 1},
+		{Name: "daily", Template: "vsphere-vm", Period: 24 * time.Hour, MaxOrders: 5},
+	}
+	now := time.Now()
+
+	assert.True(t, Counts(quotas, testOrder("vsphere-vm", "alice", order.StatusRendered, 2*time.Hour, nil), now))
+	assert.False(t, Counts(quotas, testOrder("vsphere-vm", "alice", order.StatusRendered, 25*time.Hour, nil), now))
+	assert.False(t, Counts(quotas, testOrder("vsphere-vm", "alice", order.StatusRejected, time.Minute, nil), now))
+	assert.False(t, Counts(quotas, testOrder("volumeclaim", "alice", order.StatusRendered, time.Minute, nil), now))
+	assert.False(t, Counts(nil, testOrder("vsphere-vm", "alice", order.StatusRendered, time.Minute, nil), now))
+}
+
+func TestCheck_MaxTotal(t *testing.T) {
+	global := false
+	cfg := &Config{Quotas: []Quota{{
+		Name: "storage", Template: "volumeclaim", PerUser: &global,
+		Period: time.Hour, Parameter: "storage", MaxTotal: "100Gi",
+	}}}
+	require.NoError(t, cfg.Validate())
+
+	orders := []*order.Order{
+		testOrder("volumeclaim", "alice", order.StatusRendered, 30*time.Minute, map[string]interface{}{"storage": "50Gi"}),
+		testOrder("volumeclaim", "bob", order.StatusPending, 10*time.Minute, map[string]interface{}{"storage": "40Gi"}),
+	}
+	now := time.Now()
+
+	assert.NoError(t, Check(cfg.Quotas, orders, Request{Template: "volumeclaim", User: "carol",
+		Parameters: map[string]interface{}{"storage": "10Gi"}}, now))
+
+	err := Check(cfg.Quotas, orders, Request{Template: "volumeclaim", User: "carol",
+		Parameters: map[string]interface{}{"storage": "20Gi"}}, now)
+	var exceeded *ExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, "110Gi", exceeded.Usage.Total)
+	assert.Equal(t, "100Gi", exceeded.Usage.MaxTotal)
+	assert.InDelta(t, (30 * time.Minute).Seconds(), exceeded.RetryAfter.Seconds(), 5)
+
+	// A single order larger than the quota never fits
+	err = Check(cfg.Quotas, orders, Request{Template: "volumeclaim",
+		Parameters: map[string]interface{}{"storage": "1Ti"}}, now)
+	require.True(t, errors.As(err, &exceeded))
+	assert.Zero(t, exceeded.RetryAfter)
+
+	err = Check(cfg.Quotas, orders, Request{Template: "volumeclaim",
+		Parameters: map[string]interface{}{"storage": "lots"}}, now)
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &exceeded))
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := map[string]float64{
+		"10":   10,
+		"1.5k": 1500,
+		"2Gi":  2 << 30,
+		"500M": 500e6,
+	}
+	for in, want := range tests {
+		got, err := ParseQuantity(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"10Gb", "-10Gi", "NaN", "Inf", "-Inf", "1e308Pi"} {
+		_, err := ParseQuantity(in)
+		assert.Error(t, err, in)
+	}
+	assert.Equal(t, "1.5Gi", FormatQuantity(1.5*(1<<30), "100Gi"))
+}
+
+func TestCheck_InvalidQuantity(t *testing.T) {
+	global := false
+	cfg := &Config{Quotas: []Quota{{
+		Name: "storage", Template: "volumeclaim", PerUser: &global,
+		Period: time.Hour, Parameter: "storage", MaxTotal: "100Gi",
+	}}}
+	require.NoError(t, cfg.Validate())
+	orders := []*order.Order{
+		testOrder("volumeclaim", "alice", order.StatusRendered, time.Minute, map[string]interface{}{"storage": "100Gi"}),
+	}
+
+	// Negative and non-finite sizes cannot lower the usage below maxTotal
+	for _, v := range []interface{}{-50.0, -1, "-50Gi", math.NaN(), math.Inf(-1), "NaN"} {
+		err := Check(cfg.Quotas, orders, Request{Template: "volumeclaim", User: "bob", Parameters: map[string]interface{}{"storage": v}}, time.Now())
+		require.Error(t, err, "%v", v)
+		var exceeded *ExceededError
+		assert.False(t, errors.As(err, &exceeded), "%v", v)
+		assert.Contains(t, err.Error(), "invalid quantity")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(RateLimit{RequestsPerSecond: 1, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	ok, _ := l.Allow("a")
+	assert.True(t, ok)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+	ok, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	// Buckets are per client
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+rateLimit:
+  requestsPerSecond: 0.5
+  burst: 5
+quotas:
+  - name: vm-per-day
+    template: vsphere-vm
+    period: 24h
+    maxOrders: 10
+  - name: storage
+    template: volumeclaim
+    perUser: false
+    period: 168h
+    parameter: storage
+    maxTotal: 2Ti
+`), 0644))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, cfg.RateLimit.RequestsPerSecond)
+	require.Len(t, cfg.Quotas, 2)
+	assert.True(t, cfg.Quotas[0].perUser())
+	assert.False(t, cfg.Quotas[1].perUser())
+	assert.Equal(t, float64(2<<40), cfg.Quotas[1].maxTotal)
+
+	require.NoError(t, os.WriteFile(path, []byte("quotas:\n  - name: broken\n    period: 1h\n"), 0644))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+}
+
+func TestExceededError_Limit(t *testing.T) {
+	cfg := &Config{Quotas: []Quota{{
+		Name: "storage", Template: "volumeclaim", Period: time.Hour,
+		MaxOrders: 5, Parameter: "storage", MaxTotal: "100Gi",
+	}}}
+	require.NoError(t, cfg.Validate())
+	orders := []*order.Order{
+		testOrder("volumeclaim", "alice", order.StatusRendered, time.Minute, map[string]interface{}{"storage": "90Gi"}),
+	}
+
+	// The order count fits, the total does not
+	err := Check(cfg.Quotas, orders, Request{Template: "volumeclaim", User: "alice",
+		Parameters: map[string]interface{}{"storage": "20Gi"}}, time.Now())
+	var exceeded *ExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, LimitMaxTotal, exceeded.Limit)
+	assert.Equal(t, "quota storage exceeded: 110Gi storage of 100Gi within 1h0m0s", err.Error())
+
+	cfg.Quotas[0].MaxOrders = 1
+	err = Check(cfg.Quotas, orders, Request{Template: "volumeclaim", User: "alice",
+		Parameters: map[string]interface{}{"storage": "1Gi"}}, time.Now())
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, LimitMaxOrders, exceeded.Limit)
+	assert.Equal(t, "quota storage exceeded: 2 of 1 orders within 1h0m0s", err.Error())
+}
+
+func TestCheck_BundleMembers(t *testing.T) {
+	cfg := &Config{Quotas: []Quota{
+		{Name: "vms", Template: "vsphere-vm", Period: time.Hour, MaxOrders: 3},
+		{Name: "stacks", Template: "dev-stack", Period: time.Hour, MaxOrders: 1},
+		{Name: "all", Period: time.Hour, MaxOrders: 10},
+	}}
+	require.NoError(t, cfg.Validate())
+
+	members := []order.Member{
+		{Name: "web", Template: "vsphere-vm"},
+		{Name: "db", Template: "vsphere-vm"},
+	}
+	bundle := testOrder("dev-stack", "alice", order.StatusRendered, time.Minute, nil)
+	bundle.Members = members
+	orders := []*order.Order{bundle}
+	now := time.Now()
+
+	// Two VMs of the bundle and the new one fit, a second bundle does not
+	assert.NoError(t, Check(cfg.Quotas, orders, Request{Template: "vsphere-vm", User: "alice"}, now))
+	err := Check(cfg.Quotas, orders, Request{Template: "dev-stack", User: "alice", Members: members}, now)
+	var exceeded *ExceededError
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, "vms", exceeded.Usage.Quota)
+	assert.Equal(t, 4, exceeded.Usage.Orders)
+
+	// Quotas for all templates count the members, not the bundle itself
+	cfg.Quotas = cfg.Quotas[2:]
+	cfg.Quotas[0].MaxOrders = 3
+	err = Check(cfg.Quotas, orders, Request{Template: "dev-stack", User: "alice", Members: members}, now)
+	require.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, 4, exceeded.Usage.Orders)
+	assert.InDelta(t, (59 * time.Minute).Seconds(), exceeded.RetryAfter.Seconds(), 5)
+
+	// Bundles larger than the quota never fit
+	cfg.Quotas[0].MaxOrders = 1
+	err = Check(cfg.Quotas, nil, Request{Template: "dev-stack", User: "alice", Members: members}, now)
+	require.True(t, errors.As(err, &exceeded))
+	assert.Zero(t, exceeded.RetryAfter)
+}
@@ -0,0 +1,85 @@
+package quota
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// idleBucketTTL is how long an unused client bucket is kept
+const idleBucketTTL = 10 * time.Minute
+
+// Limiter is a token bucket rate limiter keyed by client. It is safe for
+// concurrent use.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a limiter from the rate limit configuration
+func NewLimiter(cfg RateLimit) *Limiter {
+	burst := float64(cfg.Burst)
+	if burst <= 0 {
+		burst = math.Ceil(cfg.RequestsPerSecond)
+	}
+	return &Limiter{
+		rate:    cfg.RequestsPerSecond,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token for key. If none is available it returns false and
+// how long the client has to wait for the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// Limit returns the sustained rate and burst size
+func (l *Limiter) Limit() (float64, int) {
+	return l.rate, int(l.burst)
+}
+
+// prune drops buckets of clients that have been idle for a while
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < idleBucketTTL {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleBucketTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
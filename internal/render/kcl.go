@@ -7,13 +7,20 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"strings"
 
+	"gopkg.in/yaml.v3"
 	kcl "kcl-lang.io/kcl-go"
 )
 
+// maskedValue replaces secret values in printed output
+const maskedValue = "********"
+
+// RenderKCL renders a local KCL file. Values of secretKeys are masked in output.
 func RenderKCL(
 	kclFile string,
-	allAnswers map[string]interface{}) string {
+	allAnswers map[string]interface{},
+	secretKeys ...string) string {
 
 	// READ MAIN KCL FILE
 	content, err := os.ReadFile(kclFile)
@@ -22,7 +29,7 @@ func RenderKCL(
 	}
 
 	// OUTPUT ALL ANSWERS + MODIFY
-	for key, value := range allAnswers {
+	for key, value := range maskAnswers(allAnswers, secretKeys) {
 		fmt.Printf("%s=%v\n", key, value)
 	}
 
@@ -37,7 +44,7 @@ func RenderKCL(
 	// Execute KCL
 	result, err := kcl.Run(kclFile, opts...)
 	if err != nil {
-		log.Fatalf("KCL execution failed: %v", err)
+		log.Fatalf("KCL execution failed: %s", redactSecrets(err.Error(), allAnswers, secretKeys))
 	}
 
 	// Output generated YAML
@@ -48,10 +55,11 @@ func RenderKCL(
 func RenderKCLToFile(
 	kclFile string,
 	allAnswers map[string]interface{},
-	destination string) (string, error) {
+	destination string,
+	secretKeys ...string) (string, error) {
 
 	// Get rendered YAML
-	yaml := RenderKCL(kclFile, allAnswers)
+	yaml := RenderKCL(kclFile, allAnswers, secretKeys...)
 
 	// Write to file
 	err := os.WriteFile(destination, []byte(yaml), 0644)
@@ -65,27 +73,40 @@ func RenderKCLToFile(
 	return yaml, nil
 }
 
-// RenderKCLFromOCI renders KCL from an OCI source (e.g., oci://ghcr.io/...)
+// RenderKCLFromOCI renders KCL from an OCI source (e.g., oci://ghcr.io/...).
+// Values of secretKeys are masked in printed output and error messages.
+// Execution failures are fatal; use ExecKCLFromOCI to handle them.
 func RenderKCLFromOCI(
 	ociSource string,
 	tag string,
-	allAnswers map[string]interface{}) string {
+	allAnswers map[string]interface{},
+	secretKeys ...string) string {
 
-	// Build command: kcl run <oci-source> -D key=value ...
-	args := []string{"run", "--quiet"}
+	result, err := ExecKCLFromOCI(ociSource, tag, allAnswers, secretKeys...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return result
+}
 
-	// Add OCI source and tag
-	if tag != "" {
-		args = append(args, ociSource, "--tag", tag)
-	} else {
-		args = append(args, ociSource)
+// ExecKCLFromOCI renders KCL from an OCI source and returns execution
+// failures as errors. Values of secretKeys are masked in printed output and errors.
+func ExecKCLFromOCI(
+	ociSource string,
+	tag string,
+	allAnswers map[string]interface{},
+	secretKeys ...string) (string, error) {
+
+	masked := maskAnswers(allAnswers, secretKeys)
+	for key := range allAnswers {
+		fmt.Printf("%s=%v\n", key, masked[key])
 	}
 
-	// Add parameters as -D flags
-	for key, value := range allAnswers {
-		fmt.Printf("%s=%v\n", key, value)
-		args = append(args, "-D", fmt.Sprintf("%s=%v", key, value))
+	args, cleanup, err := kclRunArgs(ociSource, tag, allAnswers, secretKeys)
+	if err != nil {
+		return "", err
 	}
+	defer cleanup()
 
 	// Execute kcl CLI command
 	cmd := exec.Command("kcl", args...)
@@ -96,11 +117,69 @@ func RenderKCLFromOCI(
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		log.Fatalf("KCL execution from OCI source failed: %v\nStderr: %s", err, stderr.String())
+		return "", fmt.Errorf("KCL execution from OCI source failed: %v\nStderr: %s", err, redactSecrets(stderr.String(), allAnswers, secretKeys))
 	}
 
 	// Output generated YAML
-	return replaceTripleQuotes(stdout.String())
+	return replaceTripleQuotes(stdout.String()), nil
+}
+
+// kclRunArgs builds the arguments of `kcl run <oci-source> -D key=value ...`.
+// Secret values are not passed on the command line, where other local users
+// can read them, but in a settings file (-Y) only the owner can read. The
+// returned cleanup removes it.
+func kclRunArgs(ociSource, tag string, answers map[string]interface{}, secretKeys []string) ([]string, func(), error) {
+	args := []string{"run", "--quiet"}
+
+	// Add OCI source and tag
+	if tag != "" {
+		args = append(args, ociSource, "--tag", tag)
+	} else {
+		args = append(args, ociSource)
+	}
+
+	secrets := make(map[string]bool, len(secretKeys))
+	for _, k := range secretKeys {
+		secrets[k] = true
+	}
+
+	// Add parameters as -D flags, secrets as settings options
+	type option struct {
+		Key   string      `yaml:"key"`
+		Value interface{} `yaml:"value"`
+	}
+	var options []option
+	for key, value := range answers {
+		if secrets[key] {
+			options = append(options, option{Key: key, Value: value})
+			continue
+		}
+		args = append(args, "-D", fmt.Sprintf("%s=%v", key, value))
+	}
+	if len(options) == 0 {
+		return args, func() {}, nil
+	}
+
+	data, err := yaml.Marshal(map[string]interface{}{"kcl_options": options})
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode KCL settings: %w", err)
+	}
+	// CreateTemp creates the file with mode 0600
+	f, err := os.CreateTemp("", "kcl-settings-*.yaml")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create KCL settings: %w", err)
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("write KCL settings: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("write KCL settings: %w", err)
+	}
+	return append(args, "-Y", f.Name()), cleanup, nil
 }
 
 // RenderKCLFromOCIToFile renders KCL from OCI source and writes output to both stdout and file
@@ -108,10 +187,11 @@ func RenderKCLFromOCIToFile(
 	ociSource string,
 	tag string,
 	allAnswers map[string]interface{},
-	destination string) (string, error) {
+	destination string,
+	secretKeys ...string) (string, error) {
 
 	// Get rendered YAML
-	yaml := RenderKCLFromOCI(ociSource, tag, allAnswers)
+	yaml := RenderKCLFromOCI(ociSource, tag, allAnswers, secretKeys...)
 
 	// Write to file
 	err := os.WriteFile(destination, []byte(yaml), 0644)
@@ -125,6 +205,30 @@ func RenderKCLFromOCIToFile(
 	return yaml, nil
 }
 
+// maskAnswers returns a copy of answers with the values of secretKeys masked
+func maskAnswers(answers map[string]interface{}, secretKeys []string) map[string]interface{} {
+	out := make(map[string]interface{}, len(answers))
+	for k, v := range answers {
+		out[k] = v
+	}
+	for _, k := range secretKeys {
+		if _, ok := out[k]; ok {
+			out[k] = maskedValue
+		}
+	}
+	return out
+}
+
+// redactSecrets replaces occurrences of secret values in text
+func redactSecrets(text string, answers map[string]interface{}, secretKeys []string) string {
+	for _, k := range secretKeys {
+		if v := fmt.Sprintf("%v", answers[k]); answers[k] != nil && v != "" {
+			text = strings.ReplaceAll(text, v, maskedValue)
+		}
+	}
+	return text
+}
+
 func convertToOptionStrings(answers map[string]interface{}) []string {
 	var options []string
 
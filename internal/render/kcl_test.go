@@ -3,9 +3,11 @@ package render
 import (
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReplaceTripleQuotes(t *testing.T) {
@@ -251,6 +253,35 @@ func TestRenderKCLFromOCI(t *testing.T) {
 	}
 }
 
+func TestKCLRunArgs(t *testing.T) {
+	answers := map[string]interface{}{"namespace": "default", "password": "hunter2"}
+
+	args, cleanup, err := kclRunArgs("oci://ghcr.io/stuttgart-things/claim-xplane-volumeclaim", "0.1.1", answers, []string{"password"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run", "--quiet", "oci://ghcr.io/stuttgart-things/claim-xplane-volumeclaim", "--tag", "0.1.1", "-D", "namespace=default", "-Y"}, args[:len(args)-1])
+	assert.NotContains(t, strings.Join(args, " "), "hunter2")
+
+	// Secrets are in a settings file only the owner can read
+	settings := args[len(args)-1]
+	info, err := os.Stat(settings)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err := os.ReadFile(settings)
+	require.NoError(t, err)
+	assert.Equal(t, "kcl_options:\n    - key: password\n      value: hunter2\n", string(data))
+
+	cleanup()
+	_, err = os.Stat(settings)
+	assert.True(t, os.IsNotExist(err))
+
+	// Without secrets no settings file is written
+	args, cleanup, err = kclRunArgs("oci://ghcr.io/stuttgart-things/claim-xplane-volumeclaim", "", answers, nil)
+	require.NoError(t, err)
+	defer cleanup()
+	assert.NotContains(t, args, "-Y")
+	assert.Contains(t, args, "password=hunter2")
+}
+
 func TestRenderKCLToFile(t *testing.T) {
 	// Create temporary directory for test KCL file and output
 	tmpDir := t.TempDir()
@@ -0,0 +1,265 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is the subset of an OpenAPI v3 schema that CRDs use to describe
+// their structure and value constraints
+type Schema struct {
+	Type                 string             `yaml:"type"`
+	Format               string             `yaml:"format"`
+	Properties           map[string]*Schema `yaml:"properties"`
+	AdditionalProperties *Additional        `yaml:"additionalProperties"`
+	Required             []string           `yaml:"required"`
+	Items                *Schema            `yaml:"items"`
+	Enum                 []interface{}      `yaml:"enum"`
+	Pattern              string             `yaml:"pattern"`
+	Nullable             bool               `yaml:"nullable"`
+
+	Minimum          *float64 `yaml:"minimum"`
+	Maximum          *float64 `yaml:"maximum"`
+	ExclusiveMinimum bool     `yaml:"exclusiveMinimum"`
+	ExclusiveMaximum bool     `yaml:"exclusiveMaximum"`
+	MinLength        *int     `yaml:"minLength"`
+	MaxLength        *int     `yaml:"maxLength"`
+	MinItems         *int     `yaml:"minItems"`
+	MaxItems         *int     `yaml:"maxItems"`
+
+	PreserveUnknownFields bool `yaml:"x-kubernetes-preserve-unknown-fields"`
+	IntOrString           bool `yaml:"x-kubernetes-int-or-string"`
+	EmbeddedResource      bool `yaml:"x-kubernetes-embedded-resource"`
+
+	pattern *regexp.Regexp
+}
+
+// Additional is additionalProperties: either a boolean or a schema
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+// UnmarshalYAML accepts a boolean or a schema
+func (a *Additional) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&a.Allowed)
+	}
+	a.Allowed = true
+	a.Schema = &Schema{}
+	return node.Decode(a.Schema)
+}
+
+// validate appends violations of value at path
+func (s *Schema) validate(path string, value interface{}, out *[]string) {
+	add := func(format string, args ...interface{}) {
+		*out = append(*out, fieldName(path)+": "+fmt.Sprintf(format, args...))
+	}
+
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			add("must not be null")
+		}
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		add("unsupported value %v, allowed: %v", value, s.Enum)
+	}
+
+	if s.IntOrString {
+		switch value.(type) {
+		case string, int, int64, uint64:
+		default:
+			add("must be an integer or a string")
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			add("must be an object, got %s", typeName(value))
+			return
+		}
+		s.validateObject(path, obj, out)
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			add("must be an array, got %s", typeName(value))
+			return
+		}
+		if s.MinItems != nil && len(list) < *s.MinItems {
+			add("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(list) > *s.MaxItems {
+			add("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range list {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, out)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			add("must be a string, got %s", typeName(value))
+			return
+		}
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			add("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(str) > *s.MaxLength {
+			add("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			add("must match %s", s.Pattern)
+		}
+	case "integer", "number":
+		n, ok := toFloat(value)
+		if !ok {
+			add("must be %s, got %s", article(s.Type), typeName(value))
+			return
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			add("must be an integer")
+		}
+		if s.Minimum != nil && (n < *s.Minimum || (s.ExclusiveMinimum && n == *s.Minimum)) {
+			add("must be greater than or equal to %v", *s.Minimum)
+		}
+		if s.Maximum != nil && (n > *s.Maximum || (s.ExclusiveMaximum && n == *s.Maximum)) {
+			add("must be less than or equal to %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			add("must be a boolean, got %s", typeName(value))
+		}
+	}
+}
+
+func (s *Schema) validateObject(path string, obj map[string]interface{}, out *[]string) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			*out = append(*out, fieldName(join(path, name))+": required value")
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// Resources (the root and embedded ones) carry apiVersion, kind and
+		// metadata, which CRD schemas do not describe
+		if (s.EmbeddedResource || path == "") && (k == "apiVersion" || k == "kind" || k == "metadata") {
+			continue
+		}
+		if prop, ok := s.Properties[k]; ok {
+			prop.validate(join(path, k), obj[k], out)
+			continue
+		}
+		switch {
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+			s.AdditionalProperties.Schema.validate(join(path, k), obj[k], out)
+		case s.PreserveUnknownFields, s.AdditionalProperties != nil && s.AdditionalProperties.Allowed:
+		default:
+			*out = append(*out, fieldName(join(path, k))+": unknown field")
+		}
+	}
+}
+
+// compile prepares patterns of the schema and all nested schemas
+func (s *Schema) compile() error {
+	if s == nil {
+		return nil
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+	for _, p := range s.Properties {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil {
+		if err := s.AdditionalProperties.Schema.compile(); err != nil {
+			return err
+		}
+	}
+	return s.Items.compile()
+}
+
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func fieldName(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) || fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func article(t string) string {
+	if strings.HasPrefix(t, "i") {
+		return "an " + t
+	}
+	return "a " + t
+}
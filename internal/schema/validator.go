@@ -0,0 +1,249 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci"
+	"gopkg.in/yaml.v3"
+)
+
+// Violation is a schema error in a rendered document
+type Violation struct {
+	// Document is the zero-based position in the rendered stream
+	Document   int    `json:"document"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name,omitempty"`
+	Field      string `json:"field"`
+	Message    string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s: %s: %s", v.Kind, v.Name, v.Field, v.Message)
+}
+
+// ValidationError reports all schema violations of a rendered output
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("rendered output violates CRD schemas: %s", strings.Join(msgs, "; "))
+}
+
+// Validator checks documents against the schemas of custom resource
+// definitions. Documents of kinds without a CRD are not checked.
+type Validator struct {
+	schemas map[string]*Schema
+
+	// clusterScoped records the scope of every CRD kind by apiVersion/kind
+	clusterScoped map[string]bool
+}
+
+// crd is the part of a CustomResourceDefinition needed for validation
+type crd struct {
+	Kind string `yaml:"kind"`
+	Spec struct {
+		Group string `yaml:"group"`
+		Scope string `yaml:"scope"`
+		Names struct {
+			Kind string `yaml:"kind"`
+		} `yaml:"names"`
+		Versions []struct {
+			Name   string `yaml:"name"`
+			Schema struct {
+				OpenAPIV3Schema *Schema `yaml:"openAPIV3Schema"`
+			} `yaml:"schema"`
+		} `yaml:"versions"`
+		// Validation is the single schema of apiextensions.k8s.io/v1beta1
+		Validation struct {
+			OpenAPIV3Schema *Schema `yaml:"openAPIV3Schema"`
+		} `yaml:"validation"`
+		Version string `yaml:"version"`
+	} `yaml:"spec"`
+}
+
+// NewValidator creates a validator from YAML streams containing CRDs.
+// Documents of other kinds are ignored.
+func NewValidator(streams ...[]byte) (*Validator, error) {
+	v := &Validator{schemas: make(map[string]*Schema), clusterScoped: make(map[string]bool)}
+	for _, stream := range streams {
+		if err := v.add(stream); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Load creates a validator from a directory of CRD files (searched
+// recursively) or an OCI artifact (oci://...) containing them
+func Load(ctx context.Context, source string) (*Validator, error) {
+	if strings.HasPrefix(source, "oci://") {
+		return LoadOCI(ctx, oci.NewClient(), source)
+	}
+	return LoadDir(source)
+}
+
+// LoadDir loads all CRDs from YAML files below dir
+func LoadDir(dir string) (*Validator, error) {
+	var streams [][]byte
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isYAML(path) {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, data)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load CRDs from %s: %w", dir, err)
+	}
+	return NewValidator(streams...)
+}
+
+// LoadOCI loads all CRDs from the YAML files of an OCI artifact
+func LoadOCI(ctx context.Context, client *oci.Client, source string) (*Validator, error) {
+	ref, err := oci.ParseReference(source)
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := client.Pull(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("load CRDs: %w", err)
+	}
+	files, err := artifact.Files()
+	if err != nil {
+		return nil, fmt.Errorf("load CRDs from %s: %w", ref, err)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if isYAML(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	streams := make([][]byte, len(names))
+	for i, name := range names {
+		streams[i] = files[name]
+	}
+	return NewValidator(streams...)
+}
+
+// Kinds returns the group/version/kind keys with a schema
+func (v *Validator) Kinds() []string {
+	out := make([]string, 0, len(v.schemas))
+	for k := range v.schemas {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ClusterScoped reports whether a CRD declares the kind cluster-scoped. The
+// second value is false if no CRD defines the kind.
+func (v *Validator) ClusterScoped(apiVersion, kind string) (bool, bool) {
+	clusterScoped, ok := v.clusterScoped[apiVersion+"/"+kind]
+	return clusterScoped, ok
+}
+
+// Validate checks each document against the schema of its apiVersion and kind
+func (v *Validator) Validate(docs []manifest.Document) []Violation {
+	var out []Violation
+	for i, d := range docs {
+		s, ok := v.schemas[d.APIVersion()+"/"+d.Kind()]
+		if !ok {
+			continue
+		}
+
+		var msgs []string
+		if _, ok := d.Object["metadata"].(map[string]interface{}); !ok && d.Object["metadata"] != nil {
+			msgs = append(msgs, "metadata: must be an object")
+		}
+		s.validateObject("", d.Object, &msgs)
+		sort.Strings(msgs)
+
+		for _, msg := range msgs {
+			field, message, _ := strings.Cut(msg, ": ")
+			out = append(out, Violation{
+				Document:   i,
+				APIVersion: d.APIVersion(),
+				Kind:       d.Kind(),
+				Name:       d.Name(),
+				Field:      field,
+				Message:    message,
+			})
+		}
+	}
+	return out
+}
+
+// add registers the schemas of all CRDs in a YAML stream
+func (v *Validator) add(stream []byte) error {
+	docs, err := manifest.Split(string(stream))
+	if err != nil {
+		return err
+	}
+	for _, d := range docs {
+		if d.Kind() != "CustomResourceDefinition" {
+			continue
+		}
+		var c crd
+		if err := yaml.Unmarshal([]byte(d.Raw), &c); err != nil {
+			return fmt.Errorf("CRD %s: %w", d.Name(), err)
+		}
+
+		group, kind := c.Spec.Group, c.Spec.Names.Kind
+		clusterScoped := c.Spec.Scope == "Cluster"
+		for _, version := range c.Spec.Versions {
+			v.clusterScoped[group+"/"+version.Name+"/"+kind] = clusterScoped
+			s := version.Schema.OpenAPIV3Schema
+			if s == nil {
+				s = c.Spec.Validation.OpenAPIV3Schema
+			}
+			if err := v.register(group, version.Name, kind, s); err != nil {
+				return fmt.Errorf("CRD %s: %w", d.Name(), err)
+			}
+		}
+		if len(c.Spec.Versions) == 0 && c.Spec.Version != "" {
+			v.clusterScoped[group+"/"+c.Spec.Version+"/"+kind] = clusterScoped
+			if err := v.register(group, c.Spec.Version, kind, c.Spec.Validation.OpenAPIV3Schema); err != nil {
+				return fmt.Errorf("CRD %s: %w", d.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Validator) register(group, version, kind string, s *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if err := s.compile(); err != nil {
+		return err
+	}
+	apiVersion := version
+	if group != "" {
+		apiVersion = group + "/" + version
+	}
+	v.schemas[apiVersion+"/"+kind] = s
+	return nil
+}
+
+func isYAML(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stuttgart-things/claim-machinery-api/internal/manifest"
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci"
+	"github.com/stuttgart-things/claim-machinery-api/internal/oci/ocitest"
+)
+
+func validate(t *testing.T, v *Validator, stream string) []Violation {
+	t.Helper()
+	docs, err := manifest.Split(stream)
+	require.NoError(t, err)
+	return v.Validate(docs)
+}
+
+func TestValidator(t *testing.T) {
+	v, err := LoadDir("testdata")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"resources.stuttgart-things.com/v1alpha1/VolumeClaim"}, v.Kinds())
+	clusterScoped, ok := v.ClusterScoped("resources.stuttgart-things.com/v1alpha1", "VolumeClaim")
+	assert.True(t, ok)
+	assert.False(t, clusterScoped)
+	_, ok = v.ClusterScoped("v1", "Namespace")
+	assert.False(t, ok)
+
+	valid := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data
+spec:
+  storage: 10Gi
+  storageClass: fast
+  replicas: 2
+  accessModes: [ReadWriteOnce]
+  labels: {team: a}
+  compositionRef: {name: anything, extra: true}
+  size: 10
+`
+	assert.Empty(t, validate(t, v, valid))
+
+	violations := validate(t, v, `apiVersion: resources.stuttgart-things.com/v1alpha1
+kind: VolumeClaim
+metadata:
+  name: data
+spec:
+  storage: ten
+  storageClass: slow
+  replicas: 5
+  accessModes: [a, b, c]
+  labels: {team: 1}
+  sise: 10Gi
+`)
+	fields := make(map[string]string)
+	for _, viol := range violations {
+		assert.Equal(t, "VolumeClaim", viol.Kind)
+		assert.Equal(t, "data", viol.Name)
+		fields[viol.Field] = viol.Message
+	}
+	assert.Equal(t, map[string]string{
+		"spec.storage":      "must match ^[0-9]+(Mi|Gi|Ti)$",
+		"spec.storageClass": "unsupported value slow, allowed: [standard fast]",
+		"spec.replicas":     "must be less than or equal to 3",
+		"spec.accessModes":  "must have at most 2 items",
+		"spec.labels.team":  "must be a string, got integer",
+		"spec.sise":         "unknown field",
+	}, fields)
+
+	violations = validate(t, v, "apiVersion: resources.stuttgart-things.com/v1alpha1\nkind: VolumeClaim\nmetadata:\n  name: x\nspec: {}\n")
+	require.Len(t, violations, 1)
+	assert.Equal(t, "spec.storage", violations[0].Field)
+	assert.Equal(t, "required value", violations[0].Message)
+
+	err = &ValidationError{Violations: violations}
+	assert.Contains(t, err.Error(), "VolumeClaim x: spec.storage: required value")
+}
+
+func TestLoadOCI(t *testing.T) {
+	crds, err := os.ReadFile("testdata/xvolumeclaims.yaml")
+	require.NoError(t, err)
+
+	registry := ocitest.NewRegistry(t)
+	registry.Push("platform/crds", "v1", ocitest.Layer{MediaType: "application/yaml", Title: "crds.yaml", Data: crds})
+
+	v, err := LoadOCI(context.Background(), oci.NewClient(), "oci://"+registry.Host()+"/platform/crds:v1")
+	require.NoError(t, err)
+	assert.Len(t, v.Kinds(), 1)
+
+	_, err = LoadOCI(context.Background(), oci.NewClient(), "oci://"+registry.Host()+"/platform/crds:v2")
+	assert.Error(t, err)
+}
@@ -0,0 +1,313 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EventTypePrefix is prepended to order event names to form CloudEvent types
+	EventTypePrefix = "io.claim-machinery."
+
+	// SignatureHeader carries the HMAC-SHA256 of the request body
+	SignatureHeader = "X-Webhook-Signature-256"
+
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = time.Second
+	maxBackoff            = 30 * time.Second
+	defaultTimeout        = 10 * time.Second
+)
+
+// Config is the webhook configuration file
+type Config struct {
+	// DeadLetterLog is a file receiving undeliverable events as JSON lines;
+	// if empty they are written to the process log
+	DeadLetterLog string         `yaml:"deadLetterLog,omitempty"`
+	Subscriptions []Subscription `yaml:"subscriptions"`
+}
+
+// Subscription delivers matching events to one URL
+type Subscription struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+
+	// Events to deliver, e.g. order.rendered or order.* (empty = all)
+	Events []string `yaml:"events,omitempty"`
+
+	// Secret signs the body with HMAC-SHA256 (SignatureHeader)
+	Secret string `yaml:"secret,omitempty"`
+
+	// MaxAttempts including the first delivery (default 5)
+	MaxAttempts int `yaml:"maxAttempts,omitempty"`
+
+	// InitialBackoff before the first retry, doubled per attempt (default 1s)
+	InitialBackoff time.Duration `yaml:"initialBackoff,omitempty"`
+}
+
+// CloudEvent is a CloudEvents 1.0 event in structured JSON mode
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+// LoadConfig reads a webhook configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read webhook config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse webhook config: %w", err)
+	}
+
+	for i, sub := range cfg.Subscriptions {
+		if sub.URL == "" {
+			return nil, fmt.Errorf("webhook subscription %d (%s): url is required", i, sub.Name)
+		}
+	}
+	return &cfg, nil
+}
+
+// Dispatcher delivers events to subscriptions asynchronously with retries
+type Dispatcher struct {
+	cfg    Config
+	source string
+	client *http.Client
+
+	wg     sync.WaitGroup
+	dlqMu  sync.Mutex
+	sleep  func(context.Context, time.Duration) error
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewDispatcher creates a dispatcher; source is the CloudEvents source attribute
+func NewDispatcher(cfg Config, source string) *Dispatcher {
+	return &Dispatcher{
+		cfg:    cfg,
+		source: source,
+		client: &http.Client{Timeout: defaultTimeout},
+		sleep:  sleepContext,
+		closed: make(chan struct{}),
+	}
+}
+
+// Dispatch sends an event to all matching subscriptions in the background.
+// eventType is the short name, e.g. order.rendered.
+func (d *Dispatcher) Dispatch(eventType, subject string, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	event := CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              newEventID(),
+		Source:          d.source,
+		Type:            EventTypePrefix + eventType,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("⚠️  webhook: marshal event %s: %v", event.ID, err)
+		return
+	}
+
+	for _, sub := range d.cfg.Subscriptions {
+		if !matchesEvent(eventType, sub.Events) {
+			continue
+		}
+		d.wg.Add(1)
+		go func(sub Subscription) {
+			defer d.wg.Done()
+			d.deliver(sub, event, body)
+		}(sub)
+	}
+}
+
+// Close waits for in-flight deliveries until ctx is done; pending retries are aborted afterwards.
+// It may be called more than once.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.once.Do(func() { close(d.closed) })
+		return ctx.Err()
+	}
+}
+
+// deliver posts the event with exponential backoff and dead-letters it on failure
+func (d *Dispatcher) deliver(sub Subscription, event CloudEvent, body []byte) {
+	attempts := sub.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultMaxAttempts
+	}
+	backoff := sub.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		retry, err := d.post(ctx, sub, body)
+		if err == nil {
+			return
+		}
+		lastErr = err
+		if !retry || attempt == attempts {
+			break
+		}
+
+		log.Printf("⚠️  webhook %s: attempt %d/%d failed: %v (retrying in %s)", sub.Name, attempt, attempts, err, backoff)
+		if err := d.sleep(ctx, backoff); err != nil {
+			lastErr = err
+			break
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	d.deadLetter(sub, event, lastErr)
+}
+
+// post sends one delivery attempt and reports whether a failure is retryable
+func (d *Dispatcher) post(ctx context.Context, sub Subscription, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	if sub.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(sub.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("unexpected status: %s", resp.Status)
+}
+
+// deadLetter records an undeliverable event
+func (d *Dispatcher) deadLetter(sub Subscription, event CloudEvent, cause error) {
+	entry := map[string]interface{}{
+		"ts":           time.Now().Format(time.RFC3339Nano),
+		"subscription": sub.Name,
+		"url":          sub.URL,
+		"error":        fmt.Sprintf("%v", cause),
+		"event":        event,
+	}
+	line, _ := json.Marshal(entry)
+
+	if d.cfg.DeadLetterLog == "" {
+		log.Printf("❌ webhook dead-letter: %s", line)
+		return
+	}
+
+	d.dlqMu.Lock()
+	defer d.dlqMu.Unlock()
+
+	f, err := os.OpenFile(d.cfg.DeadLetterLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("❌ webhook dead-letter (log unavailable: %v): %s", err, line)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// Sign returns the signature header value for body: sha256=<hex hmac>
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// matchesEvent supports exact names, "*" and prefix wildcards like "order.*"
+func matchesEvent(eventType string, filters []string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if f == "*" || f == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(f, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func newEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("evt-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
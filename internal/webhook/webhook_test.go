@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noSleep(context.Context, time.Duration) error { return nil }
+
+func TestDispatch_SignedCloudEvent(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(Config{Subscriptions: []Subscription{
+		{Name: "audit", URL: srv.URL, Events: []string{"order.*"}, Secret: "s3cret"},
+	}}, "/test")
+	d.Dispatch("order.rendered", "vc-order-1", map[string]string{"template": "volumeclaim"})
+	require.NoError(t, d.Close(context.Background()))
+
+	r := <-received
+	body := <-bodies
+	assert.Equal(t, "application/cloudevents+json", r.Header.Get("Content-Type"))
+	assert.Equal(t, Sign("s3cret", body), r.Header.Get(SignatureHeader))
+
+	var event CloudEvent
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, "1.0", event.SpecVersion)
+	assert.Equal(t, "io.claim-machinery.order.rendered", event.Type)
+	assert.Equal(t, "/test", event.Source)
+	assert.Equal(t, "vc-order-1", event.Subject)
+	assert.NotEmpty(t, event.ID)
+	assert.Equal(t, map[string]interface{}{"template": "volumeclaim"}, event.Data)
+}
+
+func TestDispatch_EventFilter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(Config{Subscriptions: []Subscription{
+		{Name: "failures", URL: srv.URL, Events: []string{"order.failed"}},
+	}}, "/test")
+	d.Dispatch("order.rendered", "a", nil)
+	d.Dispatch("order.failed", "b", nil)
+	require.NoError(t, d.Close(context.Background()))
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestDispatch_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	dlq := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	d := NewDispatcher(Config{DeadLetterLog: dlq, Subscriptions: []Subscription{
+		{Name: "flaky", URL: srv.URL, MaxAttempts: 5},
+	}}, "/test")
+	d.sleep = noSleep
+	d.Dispatch("order.rendered", "a", nil)
+	require.NoError(t, d.Close(context.Background()))
+
+	assert.Equal(t, int32(3), calls.Load())
+	_, err := os.Stat(dlq)
+	assert.True(t, os.IsNotExist(err), "successful delivery must not be dead-lettered")
+}
+
+func TestDispatch_DeadLetter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	dlq := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	d := NewDispatcher(Config{DeadLetterLog: dlq, Subscriptions: []Subscription{
+		{Name: "broken", URL: srv.URL, MaxAttempts: 3},
+	}}, "/test")
+	d.sleep = noSleep
+	d.Dispatch("order.failed", "a", nil)
+	require.NoError(t, d.Close(context.Background()))
+
+	// 4xx responses are not retried
+	assert.Equal(t, int32(1), calls.Load())
+
+	data, err := os.ReadFile(dlq)
+	require.NoError(t, err)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, "broken", entry["subscription"])
+	assert.Contains(t, entry["error"], "400")
+}
+
+func TestDispatcher_CloseTwice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(Config{Subscriptions: []Subscription{{Name: "slow", URL: srv.URL}}}, "/test")
+	d.sleep = noSleep
+	d.Dispatch("order.rendered", "a", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, d.Close(ctx), context.Canceled)
+	assert.ErrorIs(t, d.Close(ctx), context.Canceled)
+
+	// The aborted delivery finishes
+	require.NoError(t, d.Close(context.Background()))
+}
+
+func TestMatchesEvent(t *testing.T) {
+	assert.True(t, matchesEvent("order.rendered", nil))
+	assert.True(t, matchesEvent("order.rendered", []string{"*"}))
+	assert.True(t, matchesEvent("order.rendered", []string{"order.*"}))
+	assert.True(t, matchesEvent("order.rendered", []string{"order.failed", "order.rendered"}))
+	assert.False(t, matchesEvent("order.rendered", []string{"order.failed"}))
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+deadLetterLog: /tmp/dlq.jsonl
+subscriptions:
+  - name: slack
+    url: https://hooks.example.com/slack
+    events: [order.failed]
+    secret: abc
+    maxAttempts: 3
+    initialBackoff: 2s
+`), 0644))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Subscriptions, 1)
+	assert.Equal(t, "/tmp/dlq.jsonl", cfg.DeadLetterLog)
+	assert.Equal(t, 2*time.Second, cfg.Subscriptions[0].InitialBackoff)
+	assert.Equal(t, []string{"order.failed"}, cfg.Subscriptions[0].Events)
+
+	require.NoError(t, os.WriteFile(path, []byte("subscriptions:\n  - name: nourl\n"), 0644))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+}
@@ -8,22 +8,52 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/stuttgart-things/claim-machinery-api/internal/api"
 	"github.com/stuttgart-things/claim-machinery-api/internal/app"
 	"github.com/stuttgart-things/claim-machinery-api/internal/claimtemplate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/kube"
+	"github.com/stuttgart-things/claim-machinery-api/internal/mutate"
+	"github.com/stuttgart-things/claim-machinery-api/internal/order"
+	"github.com/stuttgart-things/claim-machinery-api/internal/policy"
+	"github.com/stuttgart-things/claim-machinery-api/internal/quota"
+	"github.com/stuttgart-things/claim-machinery-api/internal/schema"
+	"github.com/stuttgart-things/claim-machinery-api/internal/webhook"
 )
 
 func main() {
-	fmt.Println("🚀 Claim Machinery API starting")
-
 	// Flags (override env)
 	templatesDirFlag := flag.String("templates-dir", "", "Path to templates directory")
+	templatesIncludeFlag := flag.String("templates-include", "", "Comma-separated globs of template files to load (default: all YAML files)")
+	templatesExcludeFlag := flag.String("templates-exclude", "", "Comma-separated globs of template files and directories to skip")
 	profilePathFlag := flag.String("template-profile-path", "", "Path to template profile YAML")
+	webhooksConfigFlag := flag.String("webhooks-config", "", "Path to webhook subscriptions YAML")
+	orderStoreDirFlag := flag.String("order-store-dir", "", "Directory for persisted orders (default: in-memory)")
+	quotasConfigFlag := flag.String("quotas-config", "", "Path to rate limit and quota YAML")
+	orderNamingFlag := flag.String("order-name-strategy", "", "Order naming strategy: ulid (default), uuid or timestamp")
+	mutationsConfigFlag := flag.String("mutations-config", "", "Path to mutation YAML (labels, annotations and namespace for rendered output)")
+	policiesConfigFlag := flag.String("policies-config", "", "Path to policy YAML (CEL or Rego checks of rendered output)")
+	schemaSourceFlag := flag.String("schema-source", "", "CRD directory or oci:// artifact to validate rendered output against")
+	gitSyncIntervalFlag := flag.Duration("git-sync-interval", 0, "Interval to check profile Git sources for new commits (default: 5m, negative disables)")
+	kubeTemplatesFlag := flag.Bool("kube-templates", false, "Watch ClaimTemplate custom resources of all namespaces")
+	printCRDFlag := flag.Bool("print-crd", false, "Print the ClaimTemplate CRD manifest and exit")
 	flag.Parse()
 
+	if *printCRDFlag {
+		crd, err := kube.CRD()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(crd))
+		return
+	}
+
+	fmt.Println("🚀 Claim Machinery API starting")
+
 	// Load templates directory (flag > env > default)
 	templatesDir := *templatesDirFlag
 	if templatesDir == "" {
@@ -37,63 +67,131 @@ func main() {
 		)
 	}
 
+	// Select files of the templates directory (flag > env)
+	var dirOpts app.DirOptions
+	templatesInclude := *templatesIncludeFlag
+	if templatesInclude == "" {
+		templatesInclude = os.Getenv("TEMPLATES_INCLUDE")
+	}
+	if templatesInclude != "" {
+		dirOpts.Include = splitGlobs(templatesInclude)
+	}
+	templatesExclude := *templatesExcludeFlag
+	if templatesExclude == "" {
+		templatesExclude = os.Getenv("TEMPLATES_EXCLUDE")
+	}
+	if templatesExclude != "" {
+		dirOpts.Exclude = splitGlobs(templatesExclude)
+	}
+
 	// Optionally load additional templates from YAML profile
 	profilePath := *profilePathFlag
 	if profilePath == "" {
 		profilePath = os.Getenv("TEMPLATE_PROFILE_PATH")
 	}
 
-	var server *api.Server
-	var err error
-	if profilePath == "" {
-		// Load from directory only
-		dirTemplates, err1 := app.LoadAllTemplates(templatesDir)
-		if err1 != nil {
-			log.Fatalf("failed to load templates from dir: %v", err1)
-		}
-		fmt.Printf("📂 Using templates directory: %s\n", templatesDir)
-		fmt.Printf("🧾 Loaded %d templates from directory\n", len(dirTemplates))
-		for _, t := range dirTemplates {
-			fmt.Printf("   • %s\n", t.Metadata.Name)
-		}
-		server, err = api.NewServerWithTemplates(dirTemplates)
-	} else {
-		// Combine directory templates with profile templates
-		dirTemplates, err1 := app.LoadAllTemplates(templatesDir)
-		if err1 != nil {
-			log.Fatalf("failed to load templates from dir: %v", err1)
+	templates, err := loadTemplates(templatesDir, dirOpts, profilePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Optionally persist orders and their audit trail
+	var opts []api.Option
+	if *orderNamingFlag != "" {
+		opts = append(opts, api.WithOrderNaming(*orderNamingFlag))
+	}
+	orderStoreDir := *orderStoreDirFlag
+	if orderStoreDir == "" {
+		orderStoreDir = os.Getenv("ORDER_STORE_DIR")
+	}
+	if orderStoreDir != "" {
+		store, err := order.NewStore(orderStoreDir)
+		if err != nil {
+			log.Fatal(err)
 		}
-		profileTemplates, sources, err2 := app.LoadTemplatesFromProfile(profilePath)
-		if err2 != nil {
-			log.Fatalf("failed to load templates from profile: %v", err2)
+		fmt.Printf("🗄️  Persisting orders in %s\n", orderStoreDir)
+		opts = append(opts, api.WithOrderStore(store))
+	}
+
+	// Optionally rate limit clients and enforce order quotas
+	quotasConfig := *quotasConfigFlag
+	if quotasConfig == "" {
+		quotasConfig = os.Getenv("QUOTAS_CONFIG")
+	}
+	if quotasConfig != "" {
+		cfg, err := quota.LoadConfig(quotasConfig)
+		if err != nil {
+			log.Fatal(err)
 		}
+		fmt.Printf("🚦 Loaded %d quotas from %s\n", len(cfg.Quotas), quotasConfig)
+		opts = append(opts, api.WithQuotas(cfg))
+	}
 
-		// Merge, de-duplicate by metadata.name (profile overrides directory on conflict)
-		merged := make(map[string]*claimtemplate.ClaimTemplate)
-		for _, t := range dirTemplates {
-			merged[t.Metadata.Name] = t
+	// Optionally add labels, annotations and namespaces to rendered output
+	mutationsConfig := *mutationsConfigFlag
+	if mutationsConfig == "" {
+		mutationsConfig = os.Getenv("MUTATIONS_CONFIG")
+	}
+	var mutator *mutate.Mutator
+	if mutationsConfig != "" {
+		cfg, err := mutate.LoadConfig(mutationsConfig)
+		if err != nil {
+			log.Fatal(err)
 		}
-		for _, t := range profileTemplates {
-			merged[t.Metadata.Name] = t
+		fmt.Printf("🏷️  Loaded %d mutations from %s\n", len(cfg.Mutations), mutationsConfig)
+		mutator = mutate.NewMutator(cfg)
+		opts = append(opts, api.WithMutations(mutator))
+	}
+
+	// Optionally validate rendered output against CRD schemas
+	schemaSource := *schemaSourceFlag
+	if schemaSource == "" {
+		schemaSource = os.Getenv("SCHEMA_SOURCE")
+	}
+	if schemaSource != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		validator, err := schema.Load(ctx, schemaSource)
+		cancel()
+		if err != nil {
+			log.Fatal(err)
 		}
-		final := make([]*claimtemplate.ClaimTemplate, 0, len(merged))
-		for _, t := range merged {
-			final = append(final, t)
+		fmt.Printf("📐 Loaded %d CRD schemas from %s\n", len(validator.Kinds()), schemaSource)
+		opts = append(opts, api.WithSchemaValidator(validator))
+		if mutator != nil {
+			// CRDs know which kinds are cluster-scoped
+			mutator.SetScope(validator.ClusterScoped)
 		}
+	}
 
-		// Log loaded sources for visibility
-		fmt.Printf("📂 Using templates directory: %s\n", templatesDir)
-		fmt.Printf("🧾 Loaded %d templates from profile %s\n", len(profileTemplates), profilePath)
-		for _, s := range sources {
-			fmt.Printf("   • source: %s\n", s)
-		}
-		fmt.Printf("🧾 Templates in use (%d):\n", len(final))
-		for _, t := range final {
-			fmt.Printf("   • %s\n", t.Metadata.Name)
+	// Optionally check rendered output against policies
+	policiesConfig := *policiesConfigFlag
+	if policiesConfig == "" {
+		policiesConfig = os.Getenv("POLICIES_CONFIG")
+	}
+	if policiesConfig != "" {
+		cfg, err := policy.LoadConfig(policiesConfig)
+		if err != nil {
+			log.Fatal(err)
 		}
+		fmt.Printf("🛡️  Loaded %d policies from %s\n", len(cfg.Policies), policiesConfig)
+		opts = append(opts, api.WithPolicies(policy.NewEngine(cfg)))
+	}
 
-		server, err = api.NewServerWithTemplates(final)
+	// Optionally notify webhook subscribers about orders
+	webhooksConfig := *webhooksConfigFlag
+	if webhooksConfig == "" {
+		webhooksConfig = os.Getenv("WEBHOOKS_CONFIG")
 	}
+	if webhooksConfig != "" {
+		cfg, err := webhook.LoadConfig(webhooksConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("🔔 Loaded %d webhook subscriptions from %s\n", len(cfg.Subscriptions), webhooksConfig)
+		opts = append(opts, api.WithWebhooks(webhook.NewDispatcher(*cfg, "/claim-machinery-api")))
+	}
+
+	server, err := api.NewServerWithTemplates(templates, opts...)
 	if err != nil {
 		log.Fatalf("failed to create server: %v", err)
 	}
@@ -112,8 +210,110 @@ func main() {
 	fmt.Println("\n📋 Available endpoints:")
 	fmt.Println("  GET  /health                                    - Health check")
 	fmt.Println("  GET  /api/v1/claim-templates                    - List templates")
+	fmt.Println("  GET  /api/v1/claim-templates/watch              - Stream catalog/order events (SSE)")
 	fmt.Println("  GET  /api/v1/claim-templates/{name}             - Get template details")
 	fmt.Println("  POST /api/v1/claim-templates/{name}/order       - Render template")
+	fmt.Println("  GET  /api/v1/claim-templates/{name}/versions    - List template versions")
+	fmt.Println("  GET  /api/v1/claim-templates/{name}/versions/{v} - Get template version")
+	fmt.Println("  POST /api/v1/claim-templates/{name}/versions/{v}/order - Render template version")
+	fmt.Println("  GET  /api/v1/orders                             - List orders")
+	fmt.Println("  GET  /api/v1/orders/{id}                        - Get order with audit trail")
+	fmt.Println("  PUT  /api/v1/orders/{id}                        - Update and re-render order")
+	fmt.Println("  DELETE /api/v1/orders/{id}                      - Decommission order")
+	fmt.Println("  POST /api/v1/orders:batch                       - Order several claims at once")
+	fmt.Println("  GET  /api/v1/approvals                          - List orders pending approval")
+	fmt.Println("  POST /api/v1/orders/{id}/approve                - Approve and render order")
+	fmt.Println("  POST /api/v1/orders/{id}/reject                 - Reject order")
+	fmt.Println("  POST /api/v1/orders/{id}/diff                   - Diff order against new parameters")
+
+	// Served templates are the loaded files plus, if watched, the
+	// ClaimTemplate resources of the cluster
+	var (
+		templatesMu   sync.Mutex
+		fileTemplates = templates
+		watcher       *kube.Watcher
+	)
+	applyTemplates := func() {
+		templatesMu.Lock()
+		defer templatesMu.Unlock()
+		var resources []*claimtemplate.ClaimTemplate
+		if watcher != nil {
+			resources = watcher.Templates()
+		}
+		for _, c := range server.ReloadTemplates(app.ServedTemplates(fileTemplates, resources)) {
+			fmt.Printf("   • %s %s@%s\n", c.Type, c.Name, c.Version)
+		}
+	}
+
+	// Optionally watch ClaimTemplate custom resources
+	if *kubeTemplatesFlag || os.Getenv("KUBE_TEMPLATES") == "1" || os.Getenv("KUBE_TEMPLATES") == "true" {
+		cfg, err := kube.DefaultConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+		client, err := kube.NewClient(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		watcher = kube.NewWatcher(client, func([]*claimtemplate.ClaimTemplate) { applyTemplates() })
+		fmt.Printf("☸️  Watching ClaimTemplate resources on %s\n", client.Server())
+		go func() {
+			_ = watcher.Run(context.Background())
+		}()
+	}
+
+	// Reload templates on SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			fmt.Println("\n🔄 Reloading templates (SIGHUP)")
+			templates, err := loadTemplates(templatesDir, dirOpts, profilePath)
+			if err != nil {
+				log.Printf("❌ Reload failed, keeping current templates: %v", err)
+				continue
+			}
+			templatesMu.Lock()
+			fileTemplates = templates
+			templatesMu.Unlock()
+			applyTemplates()
+		}
+	}()
+
+	// Reload templates when profile Git sources move to new commits
+	if profilePath != "" {
+		gitSources, err := app.ProfileGitSources(profilePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gitSyncInterval := *gitSyncIntervalFlag
+		if gitSyncInterval == 0 {
+			gitSyncInterval = 5 * time.Minute
+			if v := os.Getenv("GIT_SYNC_INTERVAL"); v != "" {
+				if gitSyncInterval, err = time.ParseDuration(v); err != nil {
+					log.Fatalf("invalid GIT_SYNC_INTERVAL: %v", err)
+				}
+			}
+		}
+		if len(gitSources) > 0 && gitSyncInterval > 0 {
+			fmt.Printf("🌿 Syncing %d Git sources every %s\n", len(gitSources), gitSyncInterval)
+			go func() {
+				for range time.Tick(gitSyncInterval) {
+					ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+					changed, err := app.GitSourcesChanged(ctx, app.GitCacheDir(), gitSources)
+					cancel()
+					if err != nil {
+						log.Printf("⚠️  Git sync check failed: %v", err)
+						continue
+					}
+					if changed {
+						fmt.Println("\n🌿 Git sources changed")
+						reloadChan <- syscall.SIGHUP
+					}
+				}
+			}()
+		}
+	}
 
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
@@ -132,3 +332,55 @@ func main() {
 
 	fmt.Println("✓ Server stopped gracefully")
 }
+
+// loadTemplates loads templates from the directory and, if set, the profile.
+// Profile templates override directory templates with the same name and version.
+func loadTemplates(templatesDir string, dirOpts app.DirOptions, profilePath string) ([]*claimtemplate.ClaimTemplate, error) {
+	dirTemplates, err := app.LoadAllTemplates(templatesDir, dirOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load templates from dir: %w", err)
+	}
+	fmt.Printf("📂 Using templates directory: %s\n", templatesDir)
+
+	if profilePath == "" {
+		// Load from directory only
+		fmt.Printf("🧾 Loaded %d templates from directory\n", len(dirTemplates))
+		for _, t := range dirTemplates {
+			fmt.Printf("   • %s\n", t.Metadata.Name)
+		}
+		return dirTemplates, nil
+	}
+
+	// Combine directory templates with profile templates
+	profileTemplates, sources, err := app.LoadTemplatesFromProfile(profilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load templates from profile: %w", err)
+	}
+
+	// Merge, de-duplicate by metadata.name and metadata.version
+	// (profile overrides directory on conflict)
+	final := app.MergeTemplates(dirTemplates, profileTemplates)
+
+	// Log loaded sources for visibility
+	fmt.Printf("🧾 Loaded %d templates from profile %s\n", len(profileTemplates), profilePath)
+	for _, s := range sources {
+		fmt.Printf("   • source: %s\n", s)
+	}
+	fmt.Printf("🧾 Templates in use (%d):\n", len(final))
+	for _, t := range final {
+		fmt.Printf("   • %s@%s\n", t.Metadata.Name, t.VersionKey())
+	}
+
+	return final, nil
+}
+
+// splitGlobs splits a comma-separated list of glob patterns
+func splitGlobs(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -190,7 +190,7 @@ func main() {
 		stringParams[k] = fmt.Sprintf("%v", v)
 	}
 
-	result := render.RenderKCLFromOCI(tmpl.Spec.Source, tmpl.Spec.Tag, stringParams)
+	result := render.RenderKCLFromOCI(tmpl.Spec.Source, tmpl.Spec.Tag, stringParams, tmpl.SecretParameterNames()...)
 
 	fmt.Println(successStyle.Render("\n✅ Rendered successfully!"))
 	fmt.Println(yamlStyle.Render(result))